@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/mesh"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/exporter-toolkit/web"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	modeExporter   = "exporter"
+	modeAggregator = "aggregator"
+)
+
+// runAggregator collects results of all exporter pods and serves the latency matrix and aggregated metrics.
+func runAggregator(ctx context.Context, cancel context.CancelFunc, clientSet kubernetes.Interface, namespace string,
+	webConfig *web.FlagConfig, metricsPath string, logger log.Logger) {
+	if clientSet == nil {
+		_ = level.Error(logger).Log("msg", "Aggregator mode requires access to Kubernetes API")
+		os.Exit(1)
+	}
+	aggregatorCfg, err := mesh.AggregatorConfigFromEnv()
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Incorrect aggregator configuration", "err", err)
+		os.Exit(1)
+	}
+	peersCfg, err := mesh.PeersConfigFromEnv(namespace)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Incorrect peers configuration", "err", err)
+		os.Exit(1)
+	}
+	aggregator := mesh.NewAggregator(aggregatorCfg, mesh.NewPeers(peersCfg, clientSet, logger), logger)
+	prometheus.MustRegister(aggregator)
+	_ = level.Info(logger).Log("msg", fmt.Sprintf("Aggregating results of exporters selected by %s every %s", peersCfg.Selector, aggregatorCfg.Interval))
+	go aggregator.Run(ctx)
+
+	http.Handle(metricsPath, utils.AddHSTSHeader(promhttp.Handler()))
+	http.Handle(mesh.MatrixPath, utils.AddHSTSHeader(aggregator))
+	http.Handle("/-/ready", utils.AddHSTSHeader(readinessChecker()))
+	http.Handle("/-/healthy", utils.AddHSTSHeader(healthChecker()))
+
+	srvBaseCtx := context.WithValue(context.Background(), collector.ContextKey, "http")
+	srv := &http.Server{
+		BaseContext: func(_ net.Listener) context.Context {
+			return srvBaseCtx
+		},
+		WriteTimeout: time.Second * 15,
+		ReadTimeout:  time.Second * 15,
+		IdleTimeout:  time.Second * 60,
+	}
+	sd := &shutdown{
+		srv:     srv,
+		logger:  logger,
+		ctx:     context.WithValue(context.Background(), collector.ContextKey, "shutdown"),
+		timeout: 30 * time.Second,
+	}
+	go sd.listen()
+	_ = level.Info(logger).Log("msg", "Starting aggregator server")
+	exit := web.ListenAndServe(srv, webConfig, logger)
+
+	cancel()
+	if !errors.Is(exit, http.ErrServerClosed) {
+		_ = level.Error(logger).Log("msg", "Failed to start application", "err", exit)
+	}
+	_ = level.Info(logger).Log("msg", "Server is shut down")
+}
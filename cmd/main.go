@@ -12,7 +12,14 @@ import (
 	"time"
 
 	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/events"
+	"github.com/Netcracker/network-latency-exporter/pkg/mesh"
 	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/responder"
+	"github.com/Netcracker/network-latency-exporter/pkg/sink"
+	"github.com/Netcracker/network-latency-exporter/pkg/throughput"
+	"github.com/Netcracker/network-latency-exporter/pkg/twamp"
 	"github.com/Netcracker/network-latency-exporter/pkg/utils"
 
 	"github.com/alecthomas/kingpin/v2"
@@ -28,7 +35,6 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/watch"
 	"k8s.io/client-go/kubernetes"
-	ctrl "sigs.k8s.io/controller-runtime"
 )
 
 func init() {
@@ -43,6 +49,8 @@ func main() {
 		protocolsStr = utils.GetEnvWithDefaultValue("CHECK_TARGET", "ICMP")
 		probeTimeout = utils.GetEnvWithDefaultValue("REQUEST_TIMEOUT", "3")
 		latencyTypes = utils.GetEnvWithDefaultValue("LATENCY_TYPES", "node_collector")
+		dnsQuery     = utils.GetEnvWithDefaultValue("DNS_QUERY", "kubernetes.default.svc.cluster.local")
+		dnsResolvers = utils.GetEnvWithDefaultValue("DNS_RESOLVERS", "")
 		metricsPath  = kingpin.Flag(
 			"web.telemetry-path",
 			"Path under which to expose metrics.",
@@ -51,6 +59,18 @@ func main() {
 			"web.max-requests",
 			"Maximum number of parallel scrape requests. Use 0 to disable.",
 		).Default("40").Int()
+		mode = kingpin.Flag(
+			"mode",
+			"Run mode: \"exporter\" probes network from the current node, \"aggregator\" collects results of all exporters.",
+		).Default(modeExporter).Enum(modeExporter, modeAggregator)
+		kubeconfig = kingpin.Flag(
+			"kubeconfig",
+			"Path to kubeconfig file to run outside of the cluster. If empty, KUBECONFIG, ~/.kube/config and in-cluster configuration are used.",
+		).Default("").String()
+		kubeContext = kingpin.Flag(
+			"context",
+			"Name of kubeconfig context to use. If empty, the current context is used.",
+		).Default("").String()
 	)
 
 	promLogConfig := &promlog.Config{}
@@ -72,36 +92,75 @@ func main() {
 	baseCtx, cancel := context.WithCancel(context.Background())
 	ctx := context.WithValue(baseCtx, collector.ContextKey, "main")
 
-	rCfg, _ := ctrl.GetConfig()
-	var clientSet *kubernetes.Clientset
-	if rCfg != nil {
+	var clientSet kubernetes.Interface
+	rCfg, err := utils.GetRestConfig(*kubeconfig, *kubeContext)
+	if err != nil {
+		_ = level.Warn(logger).Log("msg", "There is no connection to Kubernetes, features which use Kubernetes API are disabled", "err", err)
+		rCfg = nil
+	} else {
 		clientSet = kubernetes.NewForConfigOrDie(rCfg)
 	}
 
-	var checkTargets []*metrics.CheckTarget
-	for _, p := range strings.Split(strings.TrimSpace(protocolsStr), ",") {
-		protocolAndPort := strings.Split(p, ":")
-		checkTarget := &metrics.CheckTarget{}
-		if protocolAsFlag, ok := collector.ProtocolToMtrFlag[protocolAndPort[0]]; ok {
-			checkTarget.Protocol = protocolAndPort[0]
-			checkTarget.MtrKey = protocolAsFlag
-		} else {
-			_ = level.Warn(logger).Log("msg", fmt.Sprintf("Skip incorrect or unsupported protocol %s", p))
-			continue
-		}
-		if len(protocolAndPort) == 2 {
-			checkTarget.Port = protocolAndPort[1]
-		} else {
-			checkTarget.Port = "1"
-		}
-		checkTargets = append(checkTargets, checkTarget)
+	if *mode == modeAggregator {
+		runAggregator(ctx, cancel, clientSet, namespace, webConfig, *metricsPath, logger)
+		return
+	}
+
+	responderCfg, err := responder.ConfigFromEnv()
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Incorrect responder configuration", "err", err)
+		os.Exit(1)
+	}
+	if responderCfg.Enabled {
+		r, err := responder.Listen(":"+responderCfg.Port, logger)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Can't start responder", "err", err)
+			os.Exit(1)
+		}
+		go r.Run(ctx)
 	}
 
-	targets := collector.Discover(logger)
+	twampCfg, err := twamp.ConfigFromEnv()
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Incorrect TWAMP configuration", "err", err)
+		os.Exit(1)
+	}
+	if twampCfg.Enabled {
+		// the reflector answers sessions of peer exporters, sessions to peers are executed by the node collector
+		reflector, err := twamp.Listen(":"+twampCfg.Port, twampCfg.ErrorEstimate(), logger)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Can't start TWAMP-light reflector", "err", err)
+			os.Exit(1)
+		}
+		go reflector.Run(ctx)
+	}
+
+	throughputCfg, err := throughput.ConfigFromEnv()
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "Incorrect throughput tests configuration", "err", err)
+		os.Exit(1)
+	}
+	if throughputCfg.Enabled {
+		server, err := throughput.Listen(":"+throughputCfg.Port, throughputCfg.MaxDuration, logger)
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Can't start throughput test server", "err", err)
+			os.Exit(1)
+		}
+		go server.Run(ctx)
+	}
+
+	checkTargets, err := collector.ParseCheckList(protocolsStr, dnsQuery, dnsResolvers)
+	if err != nil {
+		_ = level.Error(logger).Log("msg", "CHECK_TARGET has incorrect value", "err", err)
+		os.Exit(1)
+	}
+
+	targets := collector.Discover(clientSet, logger)
 	if targets != nil {
 		targets = utils.ValidateTargets(logger, targets)
 		latencies := strings.Split(latencyTypes, ",")
 		cfgCont := collector.NewConfigContainer(latencies, namespace, logger)
+		cfgCont.ClientSet = clientSet
 		if err := cfgCont.Initialize(ctx, packetsSent, packetSize, probeTimeout, checkTargets, *targets, *metricsPath); err != nil {
 			_ = level.Error(logger).Log("msg", "Initialization failed", "err", err)
 			os.Exit(1)
@@ -128,25 +187,111 @@ func main() {
 				}
 			}
 		}
+		if utils.GetEnvWithDefaultValue("EVENTS_ENABLE", "false") == "true" {
+			if clientSet == nil {
+				_ = level.Warn(logger).Log("msg", "Kubernetes Events are disabled, because there is no connection to Kubernetes")
+			} else {
+				recorder, err := events.NewRecorderFromEnv(clientSet, utils.GetEnvWithDefaultValue("NODE_NAME", "localhost"), logger)
+				if err != nil {
+					_ = level.Error(logger).Log("msg", "Invalid Kubernetes Events configuration", "err", err)
+					os.Exit(1)
+				}
+				defer recorder.Shutdown()
+				collector.RegisterTransitionHandler(recorder)
+			}
+		}
+
 		exporter := collector.New(ctx, collector.NewMetrics(), enabledCollectors, logger)
 		cfgCont.Exporter = exporter
 
-		watcher, err := clientSet.CoreV1().Nodes().Watch(context.TODO(), metav1.ListOptions{})
+		if utils.GetEnvWithDefaultValue("PROBES_CRD_ENABLE", "false") == "true" {
+			if rCfg == nil {
+				_ = level.Warn(logger).Log("msg", "NetworkLatencyProbe resources are ignored, because there is no connection to Kubernetes")
+			} else {
+				defaults := model.ProbeGroup{PacketsSent: packetsSent, PacketSize: packetSize, ProbeTimeout: probeTimeout}
+				if err := startProbeController(ctx, rCfg, cfgCont, defaults, logger); err != nil {
+					_ = level.Error(logger).Log("msg", "Can't start NetworkLatencyProbe controller", "err", err)
+					os.Exit(1)
+				}
+			}
+		}
+
+		if clientSet != nil {
+			watcher, err := clientSet.CoreV1().Nodes().Watch(context.TODO(), metav1.ListOptions{})
+			if err != nil {
+				_ = level.Error(logger).Log("msg", err.Error())
+				syscall.Exit(1)
+			}
+
+			defer func(watcher watch.Interface) {
+				watcher.Stop()
+			}(watcher)
+
+			nw := &nodeWatcher{
+				ctx:       ctx,
+				logger:    logger,
+				clientSet: clientSet,
+			}
+
+			go nw.watch(logger, watcher, cfgCont, ctx)
+		}
+
+		discoveryInterval, err := time.ParseDuration(utils.GetEnvWithDefaultValue("DISCOVERY_INTERVAL", "5m"))
 		if err != nil {
-			_ = level.Error(logger).Log("msg", err.Error())
-			syscall.Exit(1)
+			_ = level.Error(logger).Log("msg", "DISCOVERY_INTERVAL has incorrect value", "err", err)
+			os.Exit(1)
+		}
+		if discoveryInterval > 0 {
+			go refreshTargets(ctx, discoveryInterval, clientSet, cfgCont, logger)
 		}
 
-		defer func(watcher watch.Interface) {
-			watcher.Stop()
-		}(watcher)
+		controlPlaneCfg, err := collector.ControlPlaneConfigFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid control plane probes configuration", "err", err)
+			os.Exit(1)
+		}
+		if controlPlaneCfg.Enabled {
+			if clientSet == nil {
+				_ = level.Warn(logger).Log("msg", "Control plane probes are disabled, because there is no connection to Kubernetes")
+			} else {
+				defaults := model.ProbeGroup{PacketsSent: packetsSent, PacketSize: packetSize, ProbeTimeout: probeTimeout}
+				go refreshGroups(ctx, discoveryInterval, "control plane", func() ([]model.ProbeGroup, error) {
+					return collector.DiscoverControlPlane(ctx, clientSet, controlPlaneCfg, defaults, logger)
+				}, cfgCont.SetControlPlaneGroups, logger)
+			}
+		}
 
-		nw := &nodeWatcher{
-			ctx:    ctx,
-			logger: logger,
+		networks, err := collector.NetworksFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid PROBE_NETWORKS configuration", "err", err)
+			os.Exit(1)
+		}
+		if len(networks) > 0 {
+			if clientSet == nil {
+				_ = level.Warn(logger).Log("msg", "Additional networks are not probed, because there is no connection to Kubernetes")
+			} else {
+				defaults := model.ProbeGroup{PacketsSent: packetsSent, PacketSize: packetSize, ProbeTimeout: probeTimeout, CheckTargets: checkTargets}
+				go refreshGroups(ctx, discoveryInterval, "additional networks", func() ([]model.ProbeGroup, error) {
+					return collector.DiscoverNetworks(clientSet, networks, defaults, logger)
+				}, cfgCont.SetNetworkGroups, logger)
+			}
 		}
 
-		go nw.watch(logger, watcher, cfgCont, ctx)
+		multusCfg, err := collector.MultusConfigFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid Multus networks configuration", "err", err)
+			os.Exit(1)
+		}
+		if multusCfg.Enabled {
+			if clientSet == nil {
+				_ = level.Warn(logger).Log("msg", "Multus networks are not probed, because there is no connection to Kubernetes")
+			} else {
+				defaults := model.ProbeGroup{PacketsSent: packetsSent, PacketSize: packetSize, ProbeTimeout: probeTimeout, CheckTargets: checkTargets}
+				go refreshGroups(ctx, discoveryInterval, "Multus networks", func() ([]model.ProbeGroup, error) {
+					return collector.DiscoverMultus(ctx, clientSet, multusCfg, defaults, logger)
+				}, cfgCont.SetAttachmentGroups, logger)
+			}
+		}
 
 		// register exporter only once
 		err = prometheus.Register(exporter)
@@ -158,8 +303,109 @@ func main() {
 			prometheus.MustRegister(exporter)
 		}
 
+		probeInterval, err := time.ParseDuration(utils.GetEnvWithDefaultValue("PROBE_INTERVAL", "30s"))
+		if err != nil || probeInterval <= 0 {
+			_ = level.Error(logger).Log("msg", fmt.Sprintf("PROBE_INTERVAL has incorrect value %s", utils.GetEnvWithDefaultValue("PROBE_INTERVAL", "30s")))
+			os.Exit(1)
+		}
 		metricHandlerFunc := collector.MetricHandler(exporter, *maxRequests, logger)
 		http.Handle(*metricsPath, utils.AddHSTSHeader(promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, metricHandlerFunc)))
+		influxCfg, err := sink.InfluxConfigFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid InfluxDB configuration", "err", err)
+			os.Exit(1)
+		}
+		if influxCfg.URL != "" || influxCfg.PullEnable {
+			influxSink, err := sink.NewInfluxSink(influxCfg, logger)
+			if err != nil {
+				_ = level.Error(logger).Log("msg", "Can't create InfluxDB sink", "err", err)
+				os.Exit(1)
+			}
+			collector.RegisterResultHandler(influxSink)
+			go influxSink.Run(ctx)
+			if influxCfg.PullEnable {
+				http.Handle("/influx", utils.AddHSTSHeader(influxSink))
+			}
+		}
+
+		remoteWriteCfg, err := sink.RemoteWriteConfigFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid remote write configuration", "err", err)
+			os.Exit(1)
+		}
+		if remoteWriteCfg.URL != "" {
+			_ = level.Info(logger).Log("msg", fmt.Sprintf("Metrics are pushed via remote write to %s every %v", remoteWriteCfg.URL, remoteWriteCfg.Interval))
+			go sink.NewRemoteWriter(remoteWriteCfg, prometheus.DefaultGatherer, logger).Run(ctx)
+		}
+
+		otlpCfg, err := sink.OTLPConfigFromEnv()
+		if err != nil {
+			_ = level.Error(logger).Log("msg", "Invalid OTLP configuration", "err", err)
+			os.Exit(1)
+		}
+		nodeName := utils.GetEnvWithDefaultValue("NODE_NAME", "localhost")
+		if otlpCfg.Endpoint != "" {
+			otlpExporter, err := sink.NewOTLPExporter(otlpCfg, prometheus.DefaultGatherer, nodeName, namespace, logger)
+			if err != nil {
+				_ = level.Error(logger).Log("msg", "Can't create OTLP exporter", "err", err)
+				os.Exit(1)
+			}
+			_ = level.Info(logger).Log("msg", fmt.Sprintf("Metrics are exported with %v every %v", otlpExporter, otlpCfg.Interval))
+			go otlpExporter.Run(ctx)
+		}
+
+		resultsStore := mesh.NewStore(nodeName)
+		collector.RegisterResultHandler(resultsStore)
+		http.Handle(mesh.ResultsPath, utils.AddHSTSHeader(resultsStore))
+
+		if throughputCfg.Enabled {
+			// only peer exporters run throughput servers
+			tester := throughput.NewTester(throughputCfg, nodeName, func() []metrics.PingHost {
+				return collector.PeerTargets(cfgCont.Targets())
+			}, logger)
+			prometheus.MustRegister(tester)
+			go tester.Run(ctx)
+			if throughputCfg.Token != "" {
+				http.Handle(throughput.Path, utils.AddHSTSHeader(tester))
+			}
+		}
+
+		if utils.GetEnvWithDefaultValue("REPORTS_ENABLE", "false") == "true" {
+			if rCfg == nil {
+				_ = level.Warn(logger).Log("msg", "NetworkLatencyReport resources are disabled, because there is no connection to Kubernetes")
+			} else if err := startReportPublisher(ctx, rCfg, nodeName, logger); err != nil {
+				_ = level.Error(logger).Log("msg", "Can't start NetworkLatencyReport publisher", "err", err)
+				os.Exit(1)
+			}
+		}
+
+		if utils.GetEnvWithDefaultValue("NODE_CONDITIONS_ENABLE", "false") == "true" {
+			if clientSet == nil {
+				_ = level.Warn(logger).Log("msg", "Node conditions are disabled, because there is no connection to Kubernetes")
+			} else {
+				conditionCfg, err := mesh.ConditionConfigFromEnv()
+				if err != nil {
+					_ = level.Error(logger).Log("msg", "Invalid node conditions configuration", "err", err)
+					os.Exit(1)
+				}
+				peersCfg, err := mesh.PeersConfigFromEnv(namespace)
+				if err != nil {
+					_ = level.Error(logger).Log("msg", "Invalid peers configuration", "err", err)
+					os.Exit(1)
+				}
+				peers := mesh.NewPeers(peersCfg, clientSet, logger)
+				go mesh.NewConditionUpdater(conditionCfg, clientSet, peers, namespace, nodeName, logger).Run(ctx)
+			}
+		}
+
+		// Pushed metrics are gathered independently of scrapes, so probes are executed in background
+		// and all gathers return results of the last probe round. Otherwise probes are executed on scrape.
+		// Probes are started after all result handlers are registered, so they receive the first results
+		if influxCfg.URL != "" || remoteWriteCfg.URL != "" || otlpCfg.Endpoint != "" {
+			_ = level.Info(logger).Log("msg", fmt.Sprintf("Probes are executed every %v", probeInterval))
+			go exporter.Run(ctx, probeInterval)
+		}
+
 		http.Handle("/-/ready", utils.AddHSTSHeader(readinessChecker()))
 		http.Handle("/-/healthy", utils.AddHSTSHeader(healthChecker()))
 
@@ -3,25 +3,29 @@ package main
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
 	"github.com/Netcracker/network-latency-exporter/pkg/utils"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/kubernetes"
 )
 
 type nodeWatcher struct {
-	ctx    context.Context
-	logger log.Logger
+	ctx       context.Context
+	logger    log.Logger
+	clientSet kubernetes.Interface
 }
 
 func (fw *nodeWatcher) watch(logger log.Logger, watcher watch.Interface, cfgCont *collector.Container, ctx context.Context) {
 	for event := range watcher.ResultChan() {
 		_ = level.Info(logger).Log("msg", fmt.Sprintf("Event occurred: %v on node %v", event.Type, event.Object.(*v1.Node).Name))
 		if event.Type == watch.Added || event.Type == watch.Modified || event.Type == watch.Deleted {
-			targets := collector.Discover(logger)
+			targets := collector.Discover(fw.clientSet, logger)
 			if targets != nil {
 				targets = utils.ValidateTargets(logger, targets)
 				cfgCont.UpdateTargets(ctx, *targets)
@@ -29,3 +33,50 @@ func (fw *nodeWatcher) watch(logger log.Logger, watcher watch.Interface, cfgCont
 		}
 	}
 }
+
+// refreshTargets periodically rediscovers targets, because providers other than nodes
+// (EndpointSlices, DNS, file) are not watched. Targets are updated only if they have changed.
+func refreshTargets(ctx context.Context, interval time.Duration, clientSet kubernetes.Interface, cfgCont *collector.Container, logger log.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			targets := collector.Discover(clientSet, logger)
+			if targets == nil {
+				continue
+			}
+			targets = utils.ValidateTargets(logger, targets)
+			cfgCont.UpdateTargets(ctx, *targets)
+		}
+	}
+}
+
+// refreshGroups discovers probe groups and applies them every interval, or only once if interval is 0.
+func refreshGroups(ctx context.Context, interval time.Duration, name string, discover func() ([]model.ProbeGroup, error),
+	apply func(context.Context, []model.ProbeGroup), logger log.Logger) {
+	var ticker <-chan time.Time
+	if interval > 0 {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		ticker = t.C
+	}
+	for {
+		groups, err := discover()
+		if err != nil {
+			_ = level.Warn(logger).Log("msg", fmt.Sprintf("Can't discover %s", name), "err", err)
+		} else {
+			apply(ctx, groups)
+		}
+		if ticker == nil {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+		}
+	}
+}
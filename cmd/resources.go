@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/api/v1alpha1"
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/controller"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/report"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newScheme returns scheme with Kubernetes and network-latency-exporter resources.
+func newScheme() (*runtime.Scheme, error) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	if err := v1alpha1.AddToScheme(scheme); err != nil {
+		return nil, err
+	}
+	return scheme, nil
+}
+
+// startProbeController starts manager which applies NetworkLatencyProbe resources to the node collector.
+func startProbeController(ctx context.Context, cfg *rest.Config, cfgCont *collector.Container, defaults model.ProbeGroup, logger log.Logger) error {
+	resync, err := time.ParseDuration(utils.GetEnvWithDefaultValue("PROBES_RESYNC_INTERVAL", "1m"))
+	if err != nil {
+		return errors.Wrap(err, "PROBES_RESYNC_INTERVAL has incorrect value")
+	}
+
+	scheme, err := newScheme()
+	if err != nil {
+		return err
+	}
+	ctrl.SetLogger(utils.NewLogr(logger))
+	mgr, err := ctrl.NewManager(cfg, ctrl.Options{
+		Scheme: scheme,
+		// Metrics and probes are served by exporter itself
+		MetricsBindAddress:     "0",
+		HealthProbeBindAddress: "0",
+	})
+	if err != nil {
+		return errors.Wrap(err, "Can't create controller manager")
+	}
+	reconciler := &controller.ProbeReconciler{
+		Client:    mgr.GetClient(),
+		Reader:    mgr.GetAPIReader(),
+		Container: cfgCont,
+		NodeName:  utils.GetEnvWithDefaultValue("NODE_NAME", "localhost"),
+		Defaults:  defaults,
+		Resync:    resync,
+		Logger:    logger,
+	}
+	if err = reconciler.SetupWithManager(mgr); err != nil {
+		return errors.Wrap(err, "Can't create NetworkLatencyProbe controller")
+	}
+	go func() {
+		if err := mgr.Start(ctx); err != nil {
+			_ = level.Error(logger).Log("msg", "Controller manager is stopped", "err", err)
+		}
+	}()
+	return nil
+}
+
+// startReportPublisher starts publishing probe results of the node as NetworkLatencyReport resource.
+func startReportPublisher(ctx context.Context, cfg *rest.Config, nodeName string, logger log.Logger) error {
+	reportCfg, err := report.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	scheme, err := newScheme()
+	if err != nil {
+		return err
+	}
+	c, err := client.New(cfg, client.Options{Scheme: scheme})
+	if err != nil {
+		return errors.Wrap(err, "Can't create Kubernetes client")
+	}
+	publisher := report.NewPublisher(reportCfg, c, nodeName, logger)
+	collector.RegisterResultHandler(publisher)
+	go publisher.Run(ctx)
+	return nil
+}
@@ -0,0 +1,99 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NetworkLatencyProbeSpec describes which targets are probed from which nodes and how.
+type NetworkLatencyProbeSpec struct {
+	// Sources selects nodes which exporters execute the probe. Empty selector selects all nodes.
+	Sources *metav1.LabelSelector `json:"sources,omitempty"`
+	// Targets to probe
+	Targets ProbeTargets `json:"targets"`
+	// Protocols in format PROTOCOL[:PORTS][@DSCP][?OPTIONS], e.g. ICMP, TCP:22;443, SCTP:3868 or UDP:53@EF. ICMP is used if empty.
+	Protocols []string `json:"protocols,omitempty"`
+	// PacketsNum is a number of packets sent to each target during probe
+	PacketsNum int `json:"packetsNum,omitempty"`
+	// PacketSize is a size of packet in bytes
+	PacketSize int `json:"packetSize,omitempty"`
+	// Timeout is a timeout of a single packet in seconds
+	Timeout int `json:"timeout,omitempty"`
+	// Interval between packets sent to target, e.g. 500ms
+	Interval *metav1.Duration `json:"interval,omitempty"`
+}
+
+// ProbeTargets selects targets of probe. Targets from all selectors are combined.
+type ProbeTargets struct {
+	// Nodes selects cluster nodes by labels, probed by internal IP address
+	Nodes *metav1.LabelSelector `json:"nodes,omitempty"`
+	// Pods selects running pods, probed by pod IP address
+	Pods []ObjectSelector `json:"pods,omitempty"`
+	// Services selects services, probed by cluster IP address
+	Services []ObjectSelector `json:"services,omitempty"`
+	// Hosts is a list of static hosts
+	Hosts []StaticHost `json:"hosts,omitempty"`
+}
+
+// ObjectSelector selects namespaced objects by labels.
+type ObjectSelector struct {
+	// Namespace of objects, all namespaces if empty. Namespace or selector must be set.
+	Namespace string `json:"namespace,omitempty"`
+	// Selector of objects, all objects if empty. Namespace or selector must be set.
+	Selector *metav1.LabelSelector `json:"selector,omitempty"`
+}
+
+// StaticHost is a target outside of Kubernetes.
+type StaticHost struct {
+	// Name of host, IP address is used if empty
+	Name string `json:"name,omitempty"`
+	// IPAddress of host
+	IPAddress string `json:"ipAddress"`
+}
+
+// NetworkLatencyProbeStatus reports how the probe is applied by exporters.
+type NetworkLatencyProbeStatus struct {
+	// Nodes contains status reported by exporter on each source node
+	Nodes []ProbeNodeStatus `json:"nodes,omitempty"`
+}
+
+// ProbeNodeStatus is a status of the probe on a single source node.
+type ProbeNodeStatus struct {
+	// Node is a name of source node
+	Node string `json:"node"`
+	// ObservedGeneration is a generation of the probe applied by exporter
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Ready is true if the probe is applied without errors
+	Ready bool `json:"ready"`
+	// Targets is a number of probed targets
+	Targets int `json:"targets"`
+	// Message describes errors of the probe configuration
+	Message string `json:"message,omitempty"`
+	// LastUpdateTime is a time when the status was changed
+	LastUpdateTime metav1.Time `json:"lastUpdateTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:scope=Cluster,shortName=nlprobe
+
+// NetworkLatencyProbe is a declarative configuration of network latency probes.
+type NetworkLatencyProbe struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   NetworkLatencyProbeSpec   `json:"spec,omitempty"`
+	Status NetworkLatencyProbeStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NetworkLatencyProbeList contains a list of NetworkLatencyProbe.
+type NetworkLatencyProbeList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkLatencyProbe `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkLatencyProbe{}, &NetworkLatencyProbeList{})
+}
@@ -0,0 +1,78 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Health values of report results
+const (
+	HealthOk       = "Ok"
+	HealthDegraded = "Degraded"
+	HealthDown     = "Down"
+)
+
+// ReportSummary counts destinations of the report by health.
+type ReportSummary struct {
+	// Results is a total number of probe results, including truncated ones
+	Results int `json:"results"`
+	// Ok is a number of healthy results
+	Ok int `json:"ok"`
+	// Degraded is a number of results with violated thresholds
+	Degraded int `json:"degraded"`
+	// Down is a number of results with unreachable destination
+	Down int `json:"down"`
+}
+
+// ReportResult is the latest result of probe from the source node to a single destination with a single protocol.
+type ReportResult struct {
+	Destination   string `json:"destination"`
+	DestinationIP string `json:"destinationIp"`
+	Protocol      string `json:"protocol"`
+	Port          string `json:"port,omitempty"`
+	// Probe is a name of NetworkLatencyProbe, empty for probes configured with parameters
+	Probe string `json:"probe,omitempty"`
+	// Health is Ok, Degraded or Down
+	Health string `json:"health"`
+	// RttMean is an average round-trip time
+	RttMean metav1.Duration `json:"rttMean"`
+	// RttMax is a worst round-trip time
+	RttMax metav1.Duration `json:"rttMax"`
+	// Loss is a percent of lost packets
+	Loss string `json:"loss"`
+	// HopsNum is a number of hops in packet path
+	HopsNum int `json:"hopsNum,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:scope=Cluster,shortName=nlreport
+
+// NetworkLatencyReport contains the latest probe results of exporter on a single source node.
+// The name of the report is equal to the name of the source node.
+type NetworkLatencyReport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// Source is a name of the source node
+	Source string `json:"source"`
+	// Timestamp is a time when results were collected
+	Timestamp metav1.Time `json:"timestamp"`
+	// Summary counts all results by health
+	Summary ReportSummary `json:"summary"`
+	// Results are sorted from the worst health. Results over the limit are truncated.
+	Results []ReportResult `json:"results,omitempty"`
+	// Truncated is a number of results which are not included to the report because of size limit
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// NetworkLatencyReportList contains a list of NetworkLatencyReport.
+type NetworkLatencyReportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkLatencyReport `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NetworkLatencyReport{}, &NetworkLatencyReportList{})
+}
@@ -0,0 +1,306 @@
+package collector
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/pkg/errors"
+)
+
+// defaultMtrPort is used when port isn't set for protocol checked with mtr
+const defaultMtrPort = "1"
+
+// maxPortRange limits the number of ports in a range, because each port is probed with a separate mtr process
+const maxPortRange = 256
+
+// dscpClasses maps names of standard traffic classes to DSCP values.
+var dscpClasses = map[string]int{"EF": 46, "VA": 44}
+
+func init() {
+	for i := 0; i <= 7; i++ {
+		dscpClasses[fmt.Sprintf("CS%d", i)] = i << 3
+	}
+	for class := 1; class <= 4; class++ {
+		for drop := 1; drop <= 3; drop++ {
+			dscpClasses[fmt.Sprintf("AF%d%d", class, drop)] = class<<3 | drop<<1
+		}
+	}
+}
+
+// ParseDSCP parses traffic class as a name, e.g. EF or AF41, or as a number from 0 to 63
+// and returns the DSCP value.
+func ParseDSCP(s string) (int, error) {
+	if value, ok := dscpClasses[strings.ToUpper(s)]; ok {
+		return value, nil
+	}
+	value, err := strconv.Atoi(s)
+	if err != nil || value < 0 || value > 63 {
+		return 0, errors.Errorf("Incorrect DSCP %s, expected a class name like EF, AF41, CS5 or a number from 0 to 63", s)
+	}
+	return value, nil
+}
+
+// ParseCheckList parses comma-separated list of checks, see ParseCheckTargets for the format of a check.
+// DNS checks query dnsQuery with dnsResolvers and support only ports. Returns an error if any check is incorrect
+// or the same check is specified more than once.
+func ParseCheckList(value string, dnsQuery string, dnsResolvers string) ([]*metrics.CheckTarget, error) {
+	var checks checkSet
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		var parsed []*metrics.CheckTarget
+		check, ports, err := parseCheck(entry, true)
+		if err == nil && check.Protocol == DNSProtocol {
+			parsed, err = dnsChecks(check, ports, dnsQuery, dnsResolvers)
+		} else if err == nil {
+			parsed = expandPorts(check, ports, defaultMtrPort)
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "Incorrect check %s", entry)
+		}
+		if err = checks.add(parsed); err != nil {
+			return nil, err
+		}
+	}
+	if len(checks.checks) == 0 {
+		return nil, errors.New("No checks are specified")
+	}
+	return checks.checks, nil
+}
+
+// ParseMtrCheckList parses checks executed with mtr, see ParseCheckTargets for the format of a check.
+// Returns an error if any check is incorrect or the same check is specified more than once.
+func ParseMtrCheckList(values []string) ([]*metrics.CheckTarget, error) {
+	var checks checkSet
+	for _, value := range values {
+		parsed, err := ParseCheckTargets(value)
+		if err != nil {
+			return nil, err
+		}
+		if err = checks.add(parsed); err != nil {
+			return nil, err
+		}
+	}
+	return checks.checks, nil
+}
+
+// checkSet collects checks and rejects checks which are specified more than once.
+type checkSet struct {
+	checks []*metrics.CheckTarget
+	seen   map[string]bool
+}
+
+func (s *checkSet) add(checks []*metrics.CheckTarget) error {
+	if s.seen == nil {
+		s.seen = make(map[string]bool)
+	}
+	for _, c := range checks {
+		key := c.Protocol + ":" + c.Port + "@" + c.DSCP
+		if s.seen[key] {
+			return errors.Errorf("Check %s is specified more than once", strings.TrimSuffix(key, "@"))
+		}
+		s.seen[key] = true
+	}
+	s.checks = append(s.checks, checks...)
+	return nil
+}
+
+// dnsChecks returns a DNS check per port of the parsed check.
+func dnsChecks(check *metrics.CheckTarget, ports []string, dnsQuery string, dnsResolvers string) ([]*metrics.CheckTarget, error) {
+	if check.DSCP != "" || check.PacketsSent != "" || check.PacketSize != "" || check.ProbeTimeout != "" {
+		return nil, errors.New("DNS checks support only ports")
+	}
+	resolvers, err := ParseDNSResolvers(dnsResolvers)
+	if err != nil {
+		return nil, err
+	}
+	check.DNSQuery = dnsQuery
+	check.DNSResolvers = resolvers
+	return expandPorts(check, ports, DefaultDNSPort), nil
+}
+
+// ParseCheckTargets parses check executed with mtr in format PROTOCOL[:PORTS][@DSCP][?OPTIONS] and returns
+// a check per port, e.g. ICMP, TCP:22, SCTP:3868, TCP:80;443;8000-8002 or UDP:5060@EF?packets=20&size=128&timeout=5.
+// PORTS is a list of ports and port ranges separated by ';'. Packets of checks with DSCP are marked
+// with the traffic class. OPTIONS override packets, size and timeout of the probe group for the check.
+func ParseCheckTargets(s string) ([]*metrics.CheckTarget, error) {
+	check, ports, err := parseCheck(s, false)
+	if err != nil {
+		return nil, err
+	}
+	return expandPorts(check, ports, defaultMtrPort), nil
+}
+
+// expandPorts returns a copy of the check per port, defaultPort is used if ports are empty.
+func expandPorts(check *metrics.CheckTarget, ports []string, defaultPort string) []*metrics.CheckTarget {
+	if len(ports) == 0 {
+		ports = []string{defaultPort}
+		check.DefaultPort = true
+	}
+	checks := make([]*metrics.CheckTarget, 0, len(ports))
+	for _, port := range ports {
+		c := *check
+		c.Port = port
+		checks = append(checks, &c)
+	}
+	return checks
+}
+
+// ParseCheckTarget parses check executed with mtr with a single port, see ParseCheckTargets for the format.
+func ParseCheckTarget(s string) (*metrics.CheckTarget, error) {
+	checks, err := ParseCheckTargets(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(checks) > 1 {
+		return nil, errors.Errorf("Check target %s must have a single port", s)
+	}
+	return checks[0], nil
+}
+
+// parseCheck parses check without expanding ports, ports are empty if not specified.
+// DNS checks are accepted only if dns is true.
+func parseCheck(s string, dns bool) (*metrics.CheckTarget, []string, error) {
+	rest, options, hasOptions := strings.Cut(strings.TrimSpace(s), "?")
+	rest, dscp, marked := strings.Cut(rest, "@")
+	protocol, ports, hasPorts := strings.Cut(rest, ":")
+	check := &metrics.CheckTarget{Protocol: strings.ToUpper(strings.TrimSpace(protocol))}
+	if !dns || check.Protocol != DNSProtocol {
+		protocolAsFlag, ok := ProtocolToMtrFlag[check.Protocol]
+		if !ok {
+			return nil, nil, errors.Errorf("Unsupported protocol %s, supported protocols are %s", protocol, supportedProtocols(dns))
+		}
+		check.MtrKey = protocolAsFlag
+	}
+
+	var portList []string
+	if hasPorts {
+		if check.Protocol == "ICMP" {
+			return nil, nil, errors.New("ICMP checks don't have ports")
+		}
+		var err error
+		if portList, err = parsePorts(ports); err != nil {
+			return nil, nil, err
+		}
+	}
+	if marked {
+		value, err := ParseDSCP(dscp)
+		if err != nil {
+			return nil, nil, err
+		}
+		check.DSCP = strings.ToUpper(dscp)
+		check.ToS = value << 2 // DSCP is the upper 6 bits of the ToS byte
+	}
+	if hasOptions {
+		if err := parseCheckOptions(check, options); err != nil {
+			return nil, nil, err
+		}
+	}
+	return check, portList, nil
+}
+
+// parsePorts parses ports and port ranges separated by ';', e.g. 80;443;8000-8002.
+func parsePorts(s string) ([]string, error) {
+	var ports []string
+	seen := make(map[int]bool)
+	for _, p := range strings.Split(s, ";") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(p), "-")
+		first, err := parsePort(from)
+		if err != nil {
+			return nil, err
+		}
+		last := first
+		if isRange {
+			if last, err = parsePort(to); err != nil {
+				return nil, err
+			}
+			if last < first {
+				return nil, errors.Errorf("Incorrect port range %s, the first port is greater than the last one", p)
+			}
+			if last-first+1 > maxPortRange {
+				return nil, errors.Errorf("Port range %s is too large, maximum is %d ports", p, maxPortRange)
+			}
+		}
+		for port := first; port <= last; port++ {
+			if seen[port] {
+				return nil, errors.Errorf("Port %d is specified more than once", port)
+			}
+			seen[port] = true
+			ports = append(ports, strconv.Itoa(port))
+		}
+	}
+	return ports, nil
+}
+
+func parsePort(s string) (int, error) {
+	port, err := strconv.Atoi(s)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, errors.Errorf("Incorrect port %q, expected a number from 1 to 65535", s)
+	}
+	return port, nil
+}
+
+// parseCheckOptions parses options separated by '&', e.g. packets=20&size=128&timeout=5.
+func parseCheckOptions(check *metrics.CheckTarget, s string) error {
+	for _, option := range strings.Split(s, "&") {
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return errors.Errorf("Incorrect check option %q, expected format is name=value", option)
+		}
+		if n, err := strconv.Atoi(value); err != nil || n <= 0 {
+			return errors.Errorf("Check option %s has incorrect value %q, expected a positive number", key, value)
+		}
+		switch key {
+		case "packets":
+			check.PacketsSent = value
+		case "size":
+			check.PacketSize = value
+		case "timeout":
+			check.ProbeTimeout = value
+		default:
+			return errors.Errorf("Unknown check option %s, supported options are packets, size, timeout", key)
+		}
+	}
+	return nil
+}
+
+// supportedProtocols returns comma-separated list of protocols which can be checked, including DNS if dns is true.
+func supportedProtocols(dns bool) string {
+	var protocols []string
+	if dns {
+		protocols = append(protocols, DNSProtocol)
+	}
+	for p := range ProtocolToMtrFlag {
+		protocols = append(protocols, p)
+	}
+	sort.Strings(protocols)
+	return strings.Join(protocols, ", ")
+}
+
+// targetChecks returns checks executed against the target. UDP and TCP checks without port target the responder
+// on peerPort if the target is a node discovered by the nodes provider, because it runs a peer exporter.
+// Empty peerPort means that responders are disabled. Checks which become equal are executed once.
+func targetChecks(checks []*metrics.CheckTarget, target metrics.PingHost, peerPort string) []*metrics.CheckTarget {
+	peer := peerPort != "" && isPeer(target)
+	result := make([]*metrics.CheckTarget, 0, len(checks))
+	seen := make(map[string]bool)
+	for _, c := range checks {
+		if peer && c.DefaultPort && (c.Protocol == "UDP" || c.Protocol == "TCP") {
+			check := *c
+			check.Port = peerPort
+			c = &check
+		}
+		key := c.Protocol + ":" + c.Port + "@" + c.DSCP
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, c)
+	}
+	return result
+}
@@ -0,0 +1,155 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCheckTarget(t *testing.T) {
+	ct, err := ParseCheckTarget("TCP:22")
+	require.NoError(t, err)
+	assert.Equal(t, "TCP", ct.Protocol)
+	assert.Equal(t, "--tcp", ct.MtrKey)
+	assert.Equal(t, "22", ct.Port)
+
+	ct, err = ParseCheckTarget("ICMP")
+	require.NoError(t, err)
+	assert.Equal(t, defaultMtrPort, ct.Port)
+
+	_, err = ParseCheckTarget("HTTP:80")
+	assert.Error(t, err)
+	_, err = ParseCheckTarget("TCP:22:23")
+	assert.Error(t, err)
+
+	ct, err = ParseCheckTarget("UDP:5060@ef")
+	require.NoError(t, err)
+	assert.Equal(t, "5060", ct.Port)
+	assert.Equal(t, "EF", ct.DSCP)
+	assert.Equal(t, 184, ct.ToS)
+
+	ct, err = ParseCheckTarget("ICMP@10")
+	require.NoError(t, err)
+	assert.Equal(t, "10", ct.DSCP)
+	assert.Equal(t, 40, ct.ToS)
+
+	_, err = ParseCheckTarget("ICMP@64")
+	assert.Error(t, err)
+	_, err = ParseCheckTarget("ICMP@AF5")
+	assert.Error(t, err)
+}
+
+func TestParseCheckTargets(t *testing.T) {
+	checks, err := ParseCheckTargets("sctp:3868")
+	require.NoError(t, err)
+	require.Len(t, checks, 1)
+	assert.Equal(t, "SCTP", checks[0].Protocol)
+	assert.Equal(t, "--sctp", checks[0].MtrKey)
+	assert.Equal(t, "3868", checks[0].Port)
+
+	checks, err = ParseCheckTargets("TCP:80;443;8000-8002@AF41?packets=20&size=128&timeout=5")
+	require.NoError(t, err)
+	require.Len(t, checks, 5)
+	var ports []string
+	for _, c := range checks {
+		ports = append(ports, c.Port)
+		assert.Equal(t, "AF41", c.DSCP)
+		assert.Equal(t, "20", c.PacketsSent)
+		assert.Equal(t, "128", c.PacketSize)
+		assert.Equal(t, "5", c.ProbeTimeout)
+	}
+	assert.Equal(t, []string{"80", "443", "8000", "8001", "8002"}, ports)
+
+	for _, s := range []string{
+		"ICMP:1", "TCP:0", "TCP:65536", "TCP:http", "TCP:90-80", "TCP:1-1000", "TCP:80;80",
+		"TCP:80?count=1", "TCP:80?packets=0", "TCP:80?packets", "DNS",
+	} {
+		_, err = ParseCheckTargets(s)
+		assert.Error(t, err, s)
+	}
+	_, err = ParseCheckTarget("TCP:80;443")
+	assert.Error(t, err)
+
+	_, err = ParseCheckTargets("DNS")
+	assert.EqualError(t, err, "Unsupported protocol DNS, supported protocols are ICMP, SCTP, TCP, UDP")
+}
+
+func TestParseMtrCheckList(t *testing.T) {
+	checks, err := ParseMtrCheckList([]string{"ICMP", "TCP:80;443", "TCP:80@EF"})
+	require.NoError(t, err)
+	assert.Len(t, checks, 4)
+
+	_, err = ParseMtrCheckList([]string{"TCP:80;443", "TCP:79-81"})
+	assert.EqualError(t, err, "Check TCP:80 is specified more than once")
+	_, err = ParseMtrCheckList([]string{"ICMP", "DNS"})
+	assert.Error(t, err)
+}
+
+func TestParseCheckList(t *testing.T) {
+	checks, err := ParseCheckList("ICMP, TCP:22;80, DNS:53;5353,", "kubernetes.default", "")
+	require.NoError(t, err)
+	require.Len(t, checks, 5)
+	assert.Equal(t, "ICMP", checks[0].Protocol)
+	assert.Equal(t, "80", checks[2].Port)
+	assert.Equal(t, DNSProtocol, checks[3].Protocol)
+	assert.Equal(t, "53", checks[3].Port)
+	assert.Equal(t, "kubernetes.default", checks[4].DNSQuery)
+	assert.Equal(t, "5353", checks[4].Port)
+
+	checks, err = ParseCheckList("ICMP,ICMP@EF", "", "")
+	require.NoError(t, err)
+	assert.Len(t, checks, 2)
+
+	for _, s := range []string{"", "ICMP,HTTP", "TCP:22,TCP:20-22", "DNS@EF"} {
+		_, err = ParseCheckList(s, "", "")
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTargetChecks(t *testing.T) {
+	checks, err := ParseCheckList("ICMP,UDP,TCP,TCP:9274,SCTP,UDP:5060", "", "")
+	require.NoError(t, err)
+	ports := func(checks []*metrics.CheckTarget) []string {
+		var result []string
+		for _, c := range checks {
+			result = append(result, c.Protocol+":"+c.Port)
+		}
+		return result
+	}
+	peer := metrics.PingHost{Name: "node-2", IPAddress: "10.0.0.2", Labels: map[string]string{LabelProvider: NodesProvider}}
+	host := metrics.PingHost{Name: "db", IPAddress: "10.0.1.1", Labels: map[string]string{LabelProvider: FileProvider}}
+
+	// only UDP and TCP checks without port target responders of peer exporters, equal checks are executed once
+	assert.Equal(t, []string{"ICMP:1", "UDP:9274", "TCP:9274", "SCTP:1", "UDP:5060"}, ports(targetChecks(checks, peer, "9274")))
+	assert.Equal(t, []string{"ICMP:1", "UDP:1", "TCP:1", "TCP:9274", "SCTP:1", "UDP:5060"}, ports(targetChecks(checks, host, "9274")))
+	assert.Equal(t, []string{"ICMP:1", "UDP:1", "TCP:1", "TCP:9274", "SCTP:1", "UDP:5060"}, ports(targetChecks(checks, peer, "")))
+	// parsed checks are not changed
+	assert.Equal(t, "1", checks[1].Port)
+}
+
+func TestCheckGroup(t *testing.T) {
+	g := model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3"}
+	assert.Equal(t, g, checkGroup(g, &metrics.CheckTarget{Protocol: "ICMP"}))
+	g = checkGroup(g, &metrics.CheckTarget{Protocol: "ICMP", PacketsSent: "20", ProbeTimeout: "5"})
+	assert.Equal(t, model.ProbeGroup{PacketsSent: "20", PacketSize: "64", ProbeTimeout: "5"}, g)
+}
+
+func TestParseDSCP(t *testing.T) {
+	for name, value := range map[string]int{"CS0": 0, "cs5": 40, "AF11": 10, "AF41": 34, "AF43": 38, "EF": 46, "63": 63} {
+		dscp, err := ParseDSCP(name)
+		require.NoError(t, err, name)
+		assert.Equal(t, value, dscp, name)
+	}
+}
+
+func TestMtrArgsWithInterval(t *testing.T) {
+	g := model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3"}
+	assert.NotContains(t, mtrArgs(g), "-i")
+
+	g.PacketInterval = "0.5"
+	args := mtrArgs(g)
+	assert.Equal(t, []string{"-i", "0.5"}, args[len(args)-2:])
+}
@@ -2,6 +2,8 @@ package collector
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 	"sync"
 
 	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
@@ -9,6 +11,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/pkg/errors"
+	"k8s.io/client-go/kubernetes"
 )
 
 type ExporterConfig struct {
@@ -19,7 +22,9 @@ type ExporterConfig struct {
 
 type Container struct {
 	*ExporterConfig
-	Exporter         *Exporter
+	Exporter *Exporter
+	// ClientSet is a connection to Kubernetes passed to collectors, it is nil if there is no connection
+	ClientSet        kubernetes.Interface
 	CollectorConfigs map[string]interface{}
 	once             sync.Once
 	logger           log.Logger
@@ -37,24 +42,113 @@ func NewConfigContainer(latencyTypes []string, namespace string, logger log.Logg
 	return configHolder
 }
 
+// UpdateTargets replaces targets of collectors and applies them to running collectors if they have changed.
 func (c *Container) UpdateTargets(ctx context.Context, targets metrics.PingHostList) {
+	c.Mutex.Lock()
+	changed := false
 	for _, latency := range c.ExporterConfig.LatencyTypes {
 		switch latency {
 		case string(NodeType):
-			nConfig := c.CollectorConfigs[latency]
-			nc := nConfig.(model.NodeCollector)
-			nc.Targets = targets
-			c.CollectorConfigs[latency] = nc
+			nc := c.CollectorConfigs[latency].(model.NodeCollector)
+			if !reflect.DeepEqual(nc.Targets, targets) {
+				nc.Targets = targets
+				c.CollectorConfigs[latency] = nc
+				changed = true
+			}
 		case string(PodType):
-			pConfig := c.CollectorConfigs[latency]
-			pc := pConfig.(model.PodCollector)
-			pc.Targets = targets
-			c.CollectorConfigs[latency] = pc
-		default:
-			return
+			pc := c.CollectorConfigs[latency].(model.PodCollector)
+			if !reflect.DeepEqual(pc.Targets, targets) {
+				pc.Targets = targets
+				c.CollectorConfigs[latency] = pc
+				changed = true
+			}
+		}
+	}
+	c.Mutex.Unlock()
+	if !changed {
+		return
+	}
+	_ = level.Info(c.logger).Log("msg", "Updated targets")
+	c.apply(ctx)
+}
+
+// SetProbeGroups replaces probe groups of the node collector, e.g. defined by NetworkLatencyProbe resources,
+// and applies them to running collectors.
+func (c *Container) SetProbeGroups(ctx context.Context, groups []model.ProbeGroup) {
+	c.Mutex.Lock()
+	if nConfig, ok := c.CollectorConfigs[string(NodeType)]; ok {
+		nc := nConfig.(model.NodeCollector)
+		nc.Groups = groups
+		c.CollectorConfigs[string(NodeType)] = nc
+	}
+	c.Mutex.Unlock()
+	c.apply(ctx)
+}
+
+// SetControlPlaneGroups replaces control plane probe groups of the node collector and applies them
+// to running collectors if they have changed.
+func (c *Container) SetControlPlaneGroups(ctx context.Context, groups []model.ProbeGroup) {
+	c.updateNodeGroups(ctx, groups, func(nc *model.NodeCollector) *[]model.ProbeGroup { return &nc.ControlPlane })
+}
+
+// SetNetworkGroups replaces probe groups of additional networks of the node collector and applies them
+// to running collectors if they have changed.
+func (c *Container) SetNetworkGroups(ctx context.Context, groups []model.ProbeGroup) {
+	c.updateNodeGroups(ctx, groups, func(nc *model.NodeCollector) *[]model.ProbeGroup { return &nc.Networks })
+}
+
+// SetAttachmentGroups replaces probe groups of Multus network attachments of the node collector and applies them
+// to running collectors if they have changed.
+func (c *Container) SetAttachmentGroups(ctx context.Context, groups []model.ProbeGroup) {
+	c.updateNodeGroups(ctx, groups, func(nc *model.NodeCollector) *[]model.ProbeGroup { return &nc.Attachments })
+}
+
+// updateNodeGroups replaces probe groups of the node collector returned by field.
+func (c *Container) updateNodeGroups(ctx context.Context, groups []model.ProbeGroup, field func(nc *model.NodeCollector) *[]model.ProbeGroup) {
+	c.Mutex.Lock()
+	nConfig, ok := c.CollectorConfigs[string(NodeType)]
+	if !ok {
+		c.Mutex.Unlock()
+		return
+	}
+	nc := nConfig.(model.NodeCollector)
+	if reflect.DeepEqual(*field(&nc), groups) {
+		c.Mutex.Unlock()
+		return
+	}
+	*field(&nc) = groups
+	c.CollectorConfigs[string(NodeType)] = nc
+	c.Mutex.Unlock()
+	c.apply(ctx)
+}
+
+// Targets returns the current targets of the node collector.
+func (c *Container) Targets() []metrics.PingHost {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
+	if nc, ok := c.CollectorConfigs[string(NodeType)].(model.NodeCollector); ok {
+		return nc.Targets.Targets
+	}
+	return nil
+}
+
+// apply re-initializes collectors with the current configuration.
+// Exporter lock prevents scrapes from running with partially applied configuration.
+func (c *Container) apply(ctx context.Context) {
+	if c.Exporter == nil {
+		return
+	}
+	c.Exporter.Mutex.Lock()
+	defer c.Exporter.Mutex.Unlock()
+	for _, coll := range c.Exporter.Collectors {
+		cfg := c.GetConfig(ctx, coll.Type())
+		if cfg == nil {
+			continue
+		}
+		if err := coll.Initialize(ctx, cfg); err != nil {
+			_ = level.Error(c.logger).Log("msg", fmt.Sprintf("Can't apply configuration to collector: %s", coll.Name()), "err", err)
 		}
 	}
-	_ = level.Info(c.Exporter.logger).Log("msg", "Updated targets")
 }
 
 func (c *Container) Initialize(ctx context.Context, packetsSent string, packetSize string, probeTimeout string, checkTargets []*metrics.CheckTarget, targets metrics.PingHostList, metricsPath string) (err error) {
@@ -82,6 +176,8 @@ func (c *Container) SetConfig(ctx context.Context, packetsSent string, packetSiz
 			nodeConfig.CheckTargets = checkTargets
 			nodeConfig.Targets = targets
 			nodeConfig.MetricsPath = metricsPath
+			nodeConfig.ClientSet = c.ClientSet
+			nodeConfig.CurrentTargets = c.Targets
 			c.CollectorConfigs[latency] = nodeConfig
 		case string(PodType):
 			var podConfig model.PodCollector
@@ -89,6 +185,7 @@ func (c *Container) SetConfig(ctx context.Context, packetsSent string, packetSiz
 			podConfig.PacketSize = packetSize
 			podConfig.ProbeTimeout = probeTimeout
 			podConfig.CheckTargets = checkTargets
+			podConfig.ClientSet = c.ClientSet
 			podConfig.Targets = targets
 			podConfig.MetricsPath = metricsPath
 			c.CollectorConfigs[latency] = podConfig
@@ -100,6 +197,8 @@ func (c *Container) SetConfig(ctx context.Context, packetsSent string, packetSiz
 }
 
 func (c *Container) GetConfig(ctx context.Context, configType Type) interface{} {
+	c.Mutex.RLock()
+	defer c.Mutex.RUnlock()
 	if cfg, found := c.CollectorConfigs[configType.String()]; found {
 		return cfg
 	}
@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestUpdateTargets checks that collectors are re-initialized only if targets have changed.
+func TestUpdateTargets(t *testing.T) {
+	ctx := context.Background()
+	fake := &fakeCollector{}
+	cfgCont := NewConfigContainer([]string{string(NodeType)}, "monitoring", log.NewNopLogger())
+	cfgCont.Exporter = New(ctx, NewMetrics(), []Collector{fake}, log.NewNopLogger())
+	targets := metrics.PingHostList{Targets: []metrics.PingHost{{Name: "node1", IPAddress: "10.0.0.1"}}}
+	assert.NoError(t, cfgCont.SetConfig(ctx, "10", "64", "3", nil, targets, "/metrics"))
+
+	cfgCont.UpdateTargets(ctx, targets)
+	assert.Zero(t, fake.initializations)
+
+	targets = metrics.PingHostList{Targets: append(targets.Targets, metrics.PingHost{Name: "node2", IPAddress: "10.0.0.2"})}
+	cfgCont.UpdateTargets(ctx, targets)
+	assert.Equal(t, 1, fake.initializations)
+	assert.Equal(t, targets, cfgCont.GetConfig(ctx, NodeType).(model.NodeCollector).Targets)
+
+	cfgCont.UpdateTargets(ctx, targets)
+	assert.Equal(t, 1, fake.initializations)
+}
+
+// TestCurrentTargets checks that background probers see updated targets without reading nodeConfig.
+func TestCurrentTargets(t *testing.T) {
+	ctx := context.Background()
+	cfgCont := NewConfigContainer([]string{string(NodeType)}, "monitoring", log.NewNopLogger())
+	targets := metrics.PingHostList{Targets: []metrics.PingHost{{Name: "node1", IPAddress: "10.0.0.1"}}}
+	assert.NoError(t, cfgCont.SetConfig(ctx, "10", "64", "3", nil, targets, "/metrics"))
+	current := currentTargets(cfgCont.GetConfig(ctx, NodeType).(model.NodeCollector))
+
+	updated := metrics.PingHostList{Targets: []metrics.PingHost{{Name: "node2", IPAddress: "10.0.0.2"}}}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		cfgCont.UpdateTargets(ctx, updated)
+	}()
+	_ = current()
+	<-done
+	assert.Equal(t, updated.Targets, current())
+
+	static := currentTargets(model.NodeCollector{Targets: targets})
+	assert.Equal(t, targets.Targets, static())
+}
@@ -0,0 +1,210 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Names of control plane probe groups, used as the `probe` label value
+	APIServerProbe = "apiserver"
+	KubeletProbe   = "kubelet"
+	EtcdProbe      = "etcd"
+
+	defaultAPIServerPort = 6443
+	defaultKubeletPort   = 10250
+)
+
+// ControlPlaneConfig describes which control plane components are probed.
+type ControlPlaneConfig struct {
+	Enabled bool
+	// Components is a list of probed components: apiserver, kubelet, etcd
+	Components []string
+	// EtcdNamespace and EtcdSelector are used to find etcd pods, e.g. static pods created by kubeadm
+	EtcdNamespace string
+	EtcdSelector  string
+	EtcdPort      int
+}
+
+// ControlPlaneConfigFromEnv reads control plane probe settings from environment.
+func ControlPlaneConfigFromEnv() (ControlPlaneConfig, error) {
+	cfg := ControlPlaneConfig{
+		Enabled:       utils.GetEnvWithDefaultValue("CONTROL_PLANE_ENABLE", "false") == "true",
+		EtcdNamespace: utils.GetEnvWithDefaultValue("CONTROL_PLANE_ETCD_NAMESPACE", metav1.NamespaceSystem),
+		EtcdSelector:  utils.GetEnvWithDefaultValue("CONTROL_PLANE_ETCD_SELECTOR", "component=etcd"),
+	}
+	for _, c := range strings.Split(utils.GetEnvWithDefaultValue("CONTROL_PLANE_COMPONENTS", "apiserver,kubelet,etcd"), ",") {
+		c = strings.TrimSpace(c)
+		switch c {
+		case "":
+			continue
+		case APIServerProbe, KubeletProbe, EtcdProbe:
+			cfg.Components = append(cfg.Components, c)
+		default:
+			return cfg, errors.Errorf("CONTROL_PLANE_COMPONENTS has unknown component %s", c)
+		}
+	}
+	port, err := strconv.Atoi(utils.GetEnvWithDefaultValue("CONTROL_PLANE_ETCD_PORT", "2379"))
+	if err != nil || port <= 0 || port > 65535 {
+		return cfg, errors.Errorf("CONTROL_PLANE_ETCD_PORT has incorrect value %s", utils.GetEnvWithDefaultValue("CONTROL_PLANE_ETCD_PORT", "2379"))
+	}
+	cfg.EtcdPort = port
+	return cfg, nil
+}
+
+// DiscoverControlPlane returns probe groups with control plane targets:
+// API server endpoints from the `kubernetes` EndpointSlice, kubelets of other nodes and etcd members
+// if etcd pods can be found. Each group is probed with TCP on ports of the component.
+// Groups use packets, size and timeout of the defaults group.
+func DiscoverControlPlane(ctx context.Context, clientSet kubernetes.Interface, cfg ControlPlaneConfig, defaults model.ProbeGroup, logger log.Logger) ([]model.ProbeGroup, error) {
+	nodes, err := getClusterNodes(clientSet)
+	if err != nil {
+		return nil, err
+	}
+	// Control plane components usually listen on node addresses, so targets are named by nodes if possible
+	nodeNames := make(map[string]string)
+	for _, n := range nodes {
+		for _, a := range n.Status.Addresses {
+			if a.Type == corev1.NodeInternalIP {
+				nodeNames[a.Address] = n.Name
+			}
+		}
+	}
+
+	var groups []model.ProbeGroup
+	for _, component := range cfg.Components {
+		var targets map[int][]metrics.PingHost
+		var err error
+		switch component {
+		case APIServerProbe:
+			targets, err = apiServerTargets(ctx, clientSet, nodeNames)
+		case KubeletProbe:
+			targets = kubeletTargets(nodes)
+		case EtcdProbe:
+			targets, err = etcdTargets(ctx, clientSet, cfg)
+			if err == nil && len(targets) == 0 {
+				_ = level.Debug(logger).Log("msg", fmt.Sprintf("No etcd pods are found with selector %s in namespace %s", cfg.EtcdSelector, cfg.EtcdNamespace))
+			}
+		}
+		if err != nil {
+			// A component which can't be discovered must not stop probing of other components
+			_ = level.Warn(logger).Log("msg", fmt.Sprintf("Can't discover control plane component %s", component), "err", err)
+			continue
+		}
+		groups = append(groups, controlPlaneGroups(component, targets, defaults)...)
+	}
+	return groups, nil
+}
+
+// controlPlaneGroups returns a probe group per port, because checks are applied to all targets of a group.
+func controlPlaneGroups(component string, targets map[int][]metrics.PingHost, defaults model.ProbeGroup) []model.ProbeGroup {
+	ports := make([]int, 0, len(targets))
+	for port := range targets {
+		ports = append(ports, port)
+	}
+	sort.Ints(ports)
+	var groups []model.ProbeGroup
+	for _, port := range ports {
+		check, _ := ParseCheckTarget(fmt.Sprintf("TCP:%d", port))
+		hosts := targets[port]
+		sort.Slice(hosts, func(i, j int) bool { return hosts[i].IPAddress < hosts[j].IPAddress })
+		groups = append(groups, model.ProbeGroup{
+			Name:           component,
+			PacketsSent:    defaults.PacketsSent,
+			PacketSize:     defaults.PacketSize,
+			ProbeTimeout:   defaults.ProbeTimeout,
+			PacketInterval: defaults.PacketInterval,
+			CheckTargets:   []*metrics.CheckTarget{check},
+			Targets:        metrics.PingHostList{Targets: hosts},
+			Kind:           metrics.DestinationControlPlane,
+		})
+	}
+	return groups
+}
+
+func controlPlaneTarget(address string, name string) metrics.PingHost {
+	if name == "" {
+		name = address
+	}
+	return metrics.PingHost{IPAddress: address, Name: name}
+}
+
+// apiServerTargets returns endpoints of the `kubernetes` Service in the default namespace by port.
+func apiServerTargets(ctx context.Context, clientSet kubernetes.Interface, nodeNames map[string]string) (map[int][]metrics.PingHost, error) {
+	slices, err := clientSet.DiscoveryV1().EndpointSlices(metav1.NamespaceDefault).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=kubernetes", discoveryv1.LabelServiceName),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't list EndpointSlices of the kubernetes Service")
+	}
+	targets := make(map[int][]metrics.PingHost)
+	for _, s := range slices.Items {
+		port := defaultAPIServerPort
+		for _, p := range s.Ports {
+			if p.Port != nil && (p.Name == nil || *p.Name == "https") {
+				port = int(*p.Port)
+			}
+		}
+		for _, e := range s.Endpoints {
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, address := range e.Addresses {
+				name := nodeNames[address]
+				if e.NodeName != nil {
+					name = *e.NodeName
+				}
+				targets[port] = append(targets[port], controlPlaneTarget(address, name))
+			}
+		}
+	}
+	return targets, nil
+}
+
+// kubeletTargets returns kubelets of nodes except the current one by port.
+func kubeletTargets(nodes []corev1.Node) map[int][]metrics.PingHost {
+	targets := make(map[int][]metrics.PingHost)
+	for _, n := range nodes {
+		target, ok := NodeTarget(n)
+		if !ok {
+			continue
+		}
+		port := int(n.Status.DaemonEndpoints.KubeletEndpoint.Port)
+		if port == 0 {
+			port = defaultKubeletPort
+		}
+		targets[port] = append(targets[port], controlPlaneTarget(target.IPAddress, n.Name))
+	}
+	return targets
+}
+
+// etcdTargets returns running etcd pods found by selector. Returns no targets if etcd is external
+// or managed by the cloud provider, so it can't be discovered.
+func etcdTargets(ctx context.Context, clientSet kubernetes.Interface, cfg ControlPlaneConfig) (map[int][]metrics.PingHost, error) {
+	pods, err := clientSet.CoreV1().Pods(cfg.EtcdNamespace).List(ctx, metav1.ListOptions{LabelSelector: cfg.EtcdSelector})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't list etcd pods")
+	}
+	targets := make(map[int][]metrics.PingHost)
+	for _, p := range pods.Items {
+		if p.Status.PodIP == "" || p.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		targets[cfg.EtcdPort] = append(targets[cfg.EtcdPort], controlPlaneTarget(p.Status.PodIP, p.Spec.NodeName))
+	}
+	return targets, nil
+}
@@ -0,0 +1,86 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiscoverControlPlane(t *testing.T) {
+	t.Setenv("NODE_NAME", "worker-1")
+	node := func(name string, ip string, kubeletPort int32) *corev1.Node {
+		n := &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: ip},
+			}},
+		}
+		n.Status.DaemonEndpoints.KubeletEndpoint.Port = kubeletPort
+		return n
+	}
+	https, port := "https", int32(6443)
+	clientSet := fake.NewSimpleClientset(
+		node("master-1", "10.0.0.1", 10250),
+		node("worker-1", "10.0.0.2", 10250),
+		node("worker-2", "10.0.0.3", 0),
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{Name: "kubernetes", Namespace: metav1.NamespaceDefault,
+				Labels: map[string]string{discoveryv1.LabelServiceName: "kubernetes"}},
+			Ports:     []discoveryv1.EndpointPort{{Name: &https, Port: &port}},
+			Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.0.0.1", "10.0.0.100"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "etcd-master-1", Namespace: metav1.NamespaceSystem,
+				Labels: map[string]string{"component": "etcd"}},
+			Spec:   corev1.PodSpec{NodeName: "master-1"},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+		},
+	)
+	cfg, err := ControlPlaneConfigFromEnv()
+	require.NoError(t, err)
+	defaults := model.ProbeGroup{PacketsSent: "5", PacketSize: "64", ProbeTimeout: "1"}
+
+	groups, err := DiscoverControlPlane(context.Background(), clientSet, cfg, defaults, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, groups, 3)
+
+	assert.Equal(t, APIServerProbe, groups[0].Name)
+	assert.Equal(t, metrics.DestinationControlPlane, groups[0].Kind)
+	assert.Equal(t, "6443", groups[0].CheckTargets[0].Port)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "master-1"}, {IPAddress: "10.0.0.100", Name: "10.0.0.100"}}, groups[0].Targets.Targets)
+
+	assert.Equal(t, KubeletProbe, groups[1].Name)
+	assert.Equal(t, "TCP", groups[1].CheckTargets[0].Protocol)
+	assert.Equal(t, "10250", groups[1].CheckTargets[0].Port)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "master-1"}, {IPAddress: "10.0.0.3", Name: "worker-2"}}, groups[1].Targets.Targets)
+
+	assert.Equal(t, EtcdProbe, groups[2].Name)
+	assert.Equal(t, "2379", groups[2].CheckTargets[0].Port)
+	assert.Equal(t, "5", groups[2].PacketsSent)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "master-1"}}, groups[2].Targets.Targets)
+
+	t.Setenv("CONTROL_PLANE_COMPONENTS", "scheduler")
+	_, err = ControlPlaneConfigFromEnv()
+	assert.Error(t, err)
+}
+
+func TestProbeGroupsWithControlPlane(t *testing.T) {
+	controlPlane := []model.ProbeGroup{{Name: KubeletProbe}}
+	groups := probeGroups(model.NodeCollector{PacketsSent: "10", ControlPlane: controlPlane})
+	require.Len(t, groups, 2)
+	assert.Equal(t, "", groups[0].Name)
+	assert.Equal(t, KubeletProbe, groups[1].Name)
+
+	groups = probeGroups(model.NodeCollector{Groups: []model.ProbeGroup{{Name: "custom"}}, ControlPlane: controlPlane})
+	assert.Equal(t, []model.ProbeGroup{{Name: "custom"}, {Name: KubeletProbe}}, groups)
+}
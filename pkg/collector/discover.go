@@ -8,16 +8,36 @@ import (
 	"github.com/Netcracker/network-latency-exporter/pkg/utils"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
 )
 
+// NodeTarget returns node as ping target with internal IP address.
+// Returns false if node has no internal IP address or it is the current node.
+func NodeTarget(n corev1.Node) (metrics.PingHost, bool) {
+	nodeAddress := ""
+	nodeName := ""
+	for _, a := range n.Status.Addresses {
+		if a.Type == corev1.NodeInternalIP {
+			nodeAddress = a.Address
+		}
+		if a.Type == corev1.NodeHostName {
+			nodeName = a.Address
+		}
+	}
+	// Skip current node
+	if nodeAddress == "" || nodeName == utils.GetEnvWithDefaultValue("NODE_NAME", "localhost") {
+		return metrics.PingHost{}, false
+	}
+	return metrics.PingHost{IPAddress: nodeAddress, Name: nodeName}, true
+}
+
 // getClusterNodes returns list of cluster nodes.
-func getClusterNodes() ([]corev1.Node, error) {
-	// Creates the in-cluster client
-	clientSet, err := utils.GetClientset()
-	if err != nil {
-		return nil, err
+func getClusterNodes(clientSet kubernetes.Interface) ([]corev1.Node, error) {
+	if clientSet == nil {
+		return nil, errors.New("There is no connection to Kubernetes")
 	}
 
 	// Reads nodes list
@@ -29,37 +49,24 @@ func getClusterNodes() ([]corev1.Node, error) {
 	return nodes.Items, nil
 }
 
-func Discover(logger log.Logger) *metrics.PingHostList {
+// Discover returns ping targets discovered by providers configured with DISCOVERY_PROVIDERS,
+// by default cluster nodes except the current one.
+// Returns nil if discovery is disabled or all providers fail.
+func Discover(clientSet kubernetes.Interface, logger log.Logger) *metrics.PingHostList {
 	if utils.GetEnvWithDefaultValue("DISCOVER_ENABLE", "true") == "true" {
-		_ = level.Debug(logger).Log("msg", "Discovering cluster nodes as ping targets")
-		rawNodes, err := getClusterNodes()
+		provider, err := ProvidersFromEnv(clientSet, logger)
 		if err != nil {
-			_ = level.Debug(logger).Log("msg", fmt.Sprintf("Error getting cluster nodes: %v", err))
+			_ = level.Error(logger).Log("msg", "Invalid discovery configuration", "err", err)
 			return nil
 		}
-
-		// Parse nodes as targets
-		targets := &metrics.PingHostList{}
-
-		for _, n := range rawNodes {
-			nodeAddress := ""
-			nodeName := ""
-			for _, a := range n.Status.Addresses {
-				if a.Type == corev1.NodeInternalIP {
-					nodeAddress = a.Address
-				}
-				if a.Type == corev1.NodeHostName {
-					nodeName = a.Address
-				}
-			}
-
-			if nodeAddress != "" {
-				// Skip current node
-				if nodeName != utils.GetEnvWithDefaultValue("NODE_NAME", "localhost") {
-					_ = level.Debug(logger).Log("msg", fmt.Sprintf("Discovered node: {ipAddress: %s, name: %s}", nodeAddress, nodeName))
-					targets.Targets = append(targets.Targets, metrics.PingHost{IPAddress: nodeAddress, Name: nodeName})
-				}
-			}
+		_ = level.Debug(logger).Log("msg", fmt.Sprintf("Discovering ping targets with providers: %s", provider.Name()))
+		targets, err := provider.Discover(context.TODO())
+		if err != nil {
+			_ = level.Debug(logger).Log("msg", fmt.Sprintf("Error discovering targets: %v", err))
+			return nil
+		}
+		for _, target := range targets.Targets {
+			_ = level.Debug(logger).Log("msg", fmt.Sprintf("Discovered target: {ipAddress: %s, name: %s, labels: %v}", target.IPAddress, target.Name, target.Labels))
 		}
 		return targets
 	} else {
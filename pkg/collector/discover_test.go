@@ -7,8 +7,12 @@ import (
 
 	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
 	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
 	"github.com/prometheus/common/promlog"
 	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 var (
@@ -63,6 +67,27 @@ func TestGetByIpAddress(t *testing.T) {
 	assert.Nil(t, GetByIpAddress(data, "1.2.3.9"))
 }
 
+// TestDiscover checks that nodes are discovered with the injected client and the current node is skipped.
+func TestDiscover(t *testing.T) {
+	t.Setenv("NODE_NAME", "node1")
+	node := func(name string, ip string) *corev1.Node {
+		return &corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+				{Type: corev1.NodeHostName, Address: name},
+				{Type: corev1.NodeInternalIP, Address: ip},
+			}},
+		}
+	}
+	clientSet := fake.NewSimpleClientset(node("node1", "10.0.0.1"), node("node2", "10.0.0.2"))
+
+	targets := Discover(clientSet, log.NewNopLogger())
+	assert.Equal(t, &metrics.PingHostList{Targets: []metrics.PingHost{
+		{IPAddress: "10.0.0.2", Name: "node2", Labels: map[string]string{LabelProvider: NodesProvider}},
+	}}, targets)
+	assert.Nil(t, Discover(nil, log.NewNopLogger()))
+}
+
 // GetByIpAddress finds a PingHost by provided ipAddress from PingHostList.
 // Return found item or nil.
 func GetByIpAddress(l *metrics.PingHostList, addr string) *metrics.PingHost {
@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"bufio"
+	"context"
+	"math/rand"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/pkg/errors"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+const (
+	DNSProtocol    = "DNS"
+	DefaultDNSPort = "53"
+	resolvConfPath = "/etc/resolv.conf"
+	// maxDNSPacketSize is a maximum size of DNS message over UDP without EDNS0
+	maxDNSPacketSize = 512
+)
+
+// ParseDNSResolvers parses comma-separated list of resolvers in format `name=ip` or `ip`.
+// If the list is empty, nameservers from /etc/resolv.conf are used.
+func ParseDNSResolvers(str string) ([]metrics.DNSResolver, error) {
+	var resolvers []metrics.DNSResolver
+	for _, r := range strings.Split(strings.TrimSpace(str), ",") {
+		r = strings.TrimSpace(r)
+		if r == "" {
+			continue
+		}
+		resolver := metrics.DNSResolver{}
+		if nameAndAddress := strings.SplitN(r, "=", 2); len(nameAndAddress) == 2 {
+			resolver.Name = strings.TrimSpace(nameAndAddress[0])
+			resolver.Address = strings.TrimSpace(nameAndAddress[1])
+		} else {
+			resolver.Name = r
+			resolver.Address = r
+		}
+		if net.ParseIP(resolver.Address) == nil {
+			return nil, errors.Errorf("Invalid DNS resolver address: %s", r)
+		}
+		resolvers = append(resolvers, resolver)
+	}
+	if len(resolvers) == 0 {
+		return readResolvConf(resolvConfPath)
+	}
+	return resolvers, nil
+}
+
+// readResolvConf returns nameservers listed in resolv.conf file.
+func readResolvConf(path string) ([]metrics.DNSResolver, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
+
+	var resolvers []metrics.DNSResolver
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" && net.ParseIP(fields[1]) != nil {
+			resolvers = append(resolvers, metrics.DNSResolver{Name: fields[1], Address: fields[1]})
+		}
+	}
+	if len(resolvers) == 0 {
+		return nil, errors.Errorf("No nameservers found in %s", path)
+	}
+	return resolvers, scanner.Err()
+}
+
+// probeDNS sends a single A query for the name to the resolver over UDP and measures resolution time.
+func probeDNS(ctx context.Context, resolver metrics.DNSResolver, port string, query string, timeout time.Duration) *metrics.DNSMetric {
+	result := &metrics.DNSMetric{
+		Resolver: resolver,
+		Port:     port,
+		Query:    query,
+		Status:   metrics.StatusUnreachable,
+		Rcode:    -1,
+	}
+
+	fqdn := query
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return result
+	}
+
+	id := uint16(rand.Intn(1 << 16))
+	builder := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	_ = builder.StartQuestions()
+	_ = builder.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET})
+	request, err := builder.Finish()
+	if err != nil {
+		return result
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	conn, err := (&net.Dialer{}).DialContext(ctxTimeout, "udp", net.JoinHostPort(resolver.Address, port))
+	if err != nil {
+		return result
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctxTimeout.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+	if _, err = conn.Write(request); err != nil {
+		return result
+	}
+
+	buf := make([]byte, maxDNSPacketSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			var netErr net.Error
+			if errors.As(err, &netErr) && netErr.Timeout() {
+				result.Timeout = true
+			}
+			return result
+		}
+		var parser dnsmessage.Parser
+		header, err := parser.Start(buf[:n])
+		if err != nil || header.ID != id || !header.Response {
+			// Ignore malformed or unrelated answers and wait for the next one
+			continue
+		}
+		result.ResolutionTime = float64(time.Since(start).Microseconds()) / 1000.0
+		result.Rcode = int(header.RCode)
+		result.Status = metrics.StatusOk
+		return result
+	}
+}
@@ -0,0 +1,201 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestParseDNSResolvers(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected []metrics.DNSResolver
+		wantErr  bool
+	}{
+		{
+			name:     "address",
+			value:    "10.96.0.10",
+			expected: []metrics.DNSResolver{{Name: "10.96.0.10", Address: "10.96.0.10"}},
+		},
+		{
+			name:  "named addresses",
+			value: " cluster=10.96.0.10, node-local = 169.254.20.10 ,",
+			expected: []metrics.DNSResolver{
+				{Name: "cluster", Address: "10.96.0.10"},
+				{Name: "node-local", Address: "169.254.20.10"},
+			},
+		},
+		{
+			name:     "IPv6 address",
+			value:    "upstream=fd00::10",
+			expected: []metrics.DNSResolver{{Name: "upstream", Address: "fd00::10"}},
+		},
+		{name: "hostname", value: "dns.example.com", wantErr: true},
+		{name: "invalid named address", value: "cluster=10.96.0", wantErr: true},
+		{name: "one of addresses is invalid", value: "10.96.0.10,cluster=", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolvers, err := ParseDNSResolvers(tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resolvers)
+		})
+	}
+}
+
+func TestReadResolvConf(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []metrics.DNSResolver
+		wantErr  bool
+	}{
+		{
+			name:    "nameservers",
+			content: "search default.svc.cluster.local\nnameserver 10.96.0.10\nnameserver invalid\nnameserver fd00::10\noptions ndots:5\n",
+			expected: []metrics.DNSResolver{
+				{Name: "10.96.0.10", Address: "10.96.0.10"},
+				{Name: "fd00::10", Address: "fd00::10"},
+			},
+		},
+		{name: "no nameservers", content: "search cluster.local\n", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "resolv.conf")
+			require.NoError(t, os.WriteFile(path, []byte(tt.content), 0o600))
+			resolvers, err := readResolvConf(path)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, resolvers)
+		})
+	}
+	_, err := readResolvConf(filepath.Join(t.TempDir(), "missing"))
+	assert.Error(t, err)
+}
+
+// startDNSServer starts UDP server which answers each query with answer built from the query header,
+// answer returns nil to leave the query without answer.
+func startDNSServer(t *testing.T, answer func(query dnsmessage.Header) []*dnsmessage.Header) string {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	go func() {
+		buf := make([]byte, maxDNSPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var parser dnsmessage.Parser
+			header, err := parser.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			for _, h := range answer(header) {
+				builder := dnsmessage.NewBuilder(nil, *h)
+				response, err := builder.Finish()
+				if err != nil {
+					continue
+				}
+				_, _ = conn.WriteTo(response, addr)
+			}
+		}
+	}()
+	_, port, _ := net.SplitHostPort(conn.LocalAddr().String())
+	return port
+}
+
+func TestProbeDNS(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   func(query dnsmessage.Header) []*dnsmessage.Header
+		query    string
+		status   int
+		rcode    int
+		timeout  bool
+		resolved bool
+	}{
+		{
+			name: "success",
+			answer: func(q dnsmessage.Header) []*dnsmessage.Header {
+				return []*dnsmessage.Header{{ID: q.ID, Response: true, RCode: dnsmessage.RCodeSuccess}}
+			},
+			query:    "kubernetes.default.svc.cluster.local",
+			status:   metrics.StatusOk,
+			rcode:    0,
+			resolved: true,
+		},
+		{
+			name: "name error",
+			answer: func(q dnsmessage.Header) []*dnsmessage.Header {
+				return []*dnsmessage.Header{{ID: q.ID, Response: true, RCode: dnsmessage.RCodeNameError}}
+			},
+			query:    "missing.example.com.",
+			status:   metrics.StatusOk,
+			rcode:    3,
+			resolved: true,
+		},
+		{
+			name: "unrelated answer is ignored",
+			answer: func(q dnsmessage.Header) []*dnsmessage.Header {
+				return []*dnsmessage.Header{
+					{ID: q.ID + 1, Response: true, RCode: dnsmessage.RCodeSuccess},
+					{ID: q.ID, Response: false},
+					{ID: q.ID, Response: true, RCode: dnsmessage.RCodeServerFailure},
+				}
+			},
+			query:    "example.com",
+			status:   metrics.StatusOk,
+			rcode:    2,
+			resolved: true,
+		},
+		{
+			name:    "timeout",
+			answer:  func(q dnsmessage.Header) []*dnsmessage.Header { return nil },
+			query:   "example.com",
+			status:  metrics.StatusUnreachable,
+			rcode:   -1,
+			timeout: true,
+		},
+		{
+			name:   "invalid name",
+			answer: func(q dnsmessage.Header) []*dnsmessage.Header { return nil },
+			query:  string(make([]byte, 300)),
+			status: metrics.StatusUnreachable,
+			rcode:  -1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			port := startDNSServer(t, tt.answer)
+			resolver := metrics.DNSResolver{Name: "local", Address: "127.0.0.1"}
+			result := probeDNS(context.Background(), resolver, port, tt.query, 200*time.Millisecond)
+			assert.Equal(t, resolver, result.Resolver)
+			assert.Equal(t, port, result.Port)
+			assert.Equal(t, tt.query, result.Query)
+			assert.Equal(t, tt.status, result.Status)
+			assert.Equal(t, tt.rcode, result.Rcode)
+			assert.Equal(t, tt.timeout, result.Timeout)
+			if !tt.resolved {
+				assert.Zero(t, result.ResolutionTime)
+			}
+		})
+	}
+}
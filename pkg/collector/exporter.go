@@ -62,12 +62,19 @@ var (
 var _ prometheus.Collector = (*Exporter)(nil)
 
 // Exporter collects version metrics. It implements prometheus.Collector.
+// By default Collect executes probes on each scrape. Once Run is started, probes are executed
+// only by Run and Collect serves metrics of the last probe round, so remote write and OTLP export
+// don't run probes on each gather.
 type Exporter struct {
 	ctx        context.Context
 	logger     log.Logger
 	Collectors []Collector
 	metrics    Metrics
+	// Mutex serializes applying of configuration to collectors
 	Mutex      sync.RWMutex
+	background bool
+	cache      []prometheus.Metric
+	cacheMutex sync.RWMutex
 }
 
 // New returns a new exporter.
@@ -99,9 +106,6 @@ func MetricHandler(exporter *Exporter, maxRequests int, logger log.Logger) http.
 			}
 		}
 
-		exporter.Mutex.RLock()
-		defer exporter.Mutex.RUnlock()
-
 		// Delegate http serving to Prometheus client library, which will call collector.Collect.
 		handler := promhttp.HandlerFor(prometheus.DefaultGatherer, promhttp.HandlerOpts{
 			ErrorLog:            stdlog.New(log.NewStdlibAdapter(level.Debug(logger)), "prom_log: ", 0),
@@ -120,19 +124,67 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	e.metrics.ScrapeErrors.Describe(ch)
 }
 
-// Collect implements prometheus.Collector.
+// Collect implements prometheus.Collector. It executes probes or sends metrics of the last probe round
+// if probes are executed by Run.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.scrape(e.ctx, ch)
+	e.cacheMutex.RLock()
+	background, cache := e.background, e.cache
+	e.cacheMutex.RUnlock()
+	if background {
+		for _, m := range cache {
+			ch <- m
+		}
+	} else {
+		e.scrape(e.ctx, ch)
+	}
 	ch <- e.metrics.TotalScrapes
 	ch <- e.metrics.Error
 	e.metrics.ScrapeErrors.Collect(ch)
 }
 
+// Run executes probes of all collectors every interval and caches their metrics until context is done.
+// Scrapes don't execute probes after Run is started.
+func (e *Exporter) Run(ctx context.Context, interval time.Duration) {
+	e.cacheMutex.Lock()
+	e.background = true
+	e.cacheMutex.Unlock()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		e.Refresh(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Refresh executes probes of all collectors once and replaces cached metrics.
+func (e *Exporter) Refresh(ctx context.Context) {
+	ch := make(chan prometheus.Metric)
+	done := make(chan struct{})
+	var results []prometheus.Metric
+	go func() {
+		defer close(done)
+		for m := range ch {
+			results = append(results, m)
+		}
+	}()
+
+	e.scrape(ctx, ch)
+	close(ch)
+	<-done
+
+	e.cacheMutex.Lock()
+	e.cache = results
+	e.cacheMutex.Unlock()
+}
+
 func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	e.metrics.TotalScrapes.Inc()
 	e.metrics.Error.Set(0)
 
-	var err error
 	var wg sync.WaitGroup
 	defer wg.Wait()
 	for _, scraper := range e.Collectors {
@@ -141,7 +193,7 @@ func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 			defer wg.Done()
 			label := collectorPrefix + scraper.Name()
 			sTime := time.Now()
-			if err = scraper.Scrape(ctx, &e.metrics, ch); err != nil {
+			if err := scraper.Scrape(ctx, &e.metrics, ch); err != nil {
 				_ = level.Error(e.logger).Log("msg", fmt.Sprintf("Error from: %s", scraper.Name()), "err", err)
 				e.metrics.ScrapeErrors.WithLabelValues(label).Inc()
 				e.metrics.Error.Set(1)
@@ -0,0 +1,85 @@
+package collector
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fakeProbeDesc = prometheus.NewDesc("network_latency_fake", "Number of probe rounds", nil, nil)
+
+// fakeCollector counts initializations and scrapes instead of running probes.
+type fakeCollector struct {
+	initializations int
+	scrapes         int
+}
+
+func (c *fakeCollector) Name() string { return "fake" }
+
+func (c *fakeCollector) Type() Type { return NodeType }
+
+func (c *fakeCollector) Initialize(ctx context.Context, config interface{}) error {
+	c.initializations++
+	return nil
+}
+
+func (c *fakeCollector) Scrape(ctx context.Context, metrics *Metrics, ch chan<- prometheus.Metric) error {
+	c.scrapes++
+	ch <- prometheus.MustNewConstMetric(fakeProbeDesc, prometheus.GaugeValue, float64(c.scrapes))
+	return nil
+}
+
+func (c *fakeCollector) Close() {}
+
+// gatherFake gathers metrics and returns value of the fake metric.
+func gatherFake(t *testing.T, registry *prometheus.Registry) float64 {
+	families, err := registry.Gather()
+	require.NoError(t, err)
+	for _, f := range families {
+		if f.GetName() == "network_latency_fake" {
+			return f.GetMetric()[0].GetGauge().GetValue()
+		}
+	}
+	return 0
+}
+
+// TestExporterProbesOnScrape checks that each gather runs probes unless Run is started.
+func TestExporterProbesOnScrape(t *testing.T) {
+	fake := &fakeCollector{}
+	exporter := New(context.Background(), NewMetrics(), []Collector{fake}, log.NewNopLogger())
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(exporter))
+
+	assert.Equal(t, 1.0, gatherFake(t, registry))
+	assert.Equal(t, 2.0, gatherFake(t, registry))
+	assert.Equal(t, 2, fake.scrapes)
+}
+
+// TestExporterServesCachedMetrics checks that gathers don't run probes and return the last probe round
+// once Run is started.
+func TestExporterServesCachedMetrics(t *testing.T) {
+	fake := &fakeCollector{}
+	exporter := New(context.Background(), NewMetrics(), []Collector{fake}, log.NewNopLogger())
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(exporter))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// Run executes a single probe round with cancelled context
+	exporter.Run(ctx, time.Hour)
+	assert.Equal(t, 1, fake.scrapes)
+
+	// Gathers for /metrics, remote write and OTLP export
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, 1.0, gatherFake(t, registry))
+	}
+	assert.Equal(t, 1, fake.scrapes)
+
+	exporter.Refresh(context.Background())
+	assert.Equal(t, 2.0, gatherFake(t, registry))
+	assert.Equal(t, 2, fake.scrapes)
+}
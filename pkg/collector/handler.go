@@ -0,0 +1,59 @@
+package collector
+
+import (
+	"context"
+	"sync"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+)
+
+var (
+	resultHandlers     []ResultHandler
+	transitionHandlers []TransitionHandler
+	handlersMutex      sync.RWMutex
+)
+
+// ResultHandler receives probe results after each scrape, e.g. to send them to external storage.
+type ResultHandler interface {
+	// HandleResults is called with results of all probes executed during scrape.
+	// Implementations must not block for a long time and must not modify results.
+	HandleResults(ctx context.Context, source string, results []*metrics.NetworkLatencyMetric)
+}
+
+// TransitionHandler receives changes of dampened pair health, e.g. to report them to Kubernetes.
+type TransitionHandler interface {
+	// HandleTransition is called for each pair which health has been changed during scrape.
+	HandleTransition(ctx context.Context, transition StateTransition)
+}
+
+// RegisterResultHandler adds handler which is notified about probe results.
+func RegisterResultHandler(handler ResultHandler) {
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+	resultHandlers = append(resultHandlers, handler)
+}
+
+func notifyResultHandlers(ctx context.Context, source string, results []*metrics.NetworkLatencyMetric) {
+	handlersMutex.RLock()
+	defer handlersMutex.RUnlock()
+	for _, h := range resultHandlers {
+		h.HandleResults(ctx, source, results)
+	}
+}
+
+// RegisterTransitionHandler adds handler which is notified about pair health transitions.
+func RegisterTransitionHandler(handler TransitionHandler) {
+	handlersMutex.Lock()
+	defer handlersMutex.Unlock()
+	transitionHandlers = append(transitionHandlers, handler)
+}
+
+func notifyTransitionHandlers(ctx context.Context, transitions []StateTransition) {
+	handlersMutex.RLock()
+	defer handlersMutex.RUnlock()
+	for _, t := range transitions {
+		for _, h := range transitionHandlers {
+			h.HandleTransition(ctx, t)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkStatusAnnotation is set by Multus on pods and describes all attached networks.
+const NetworkStatusAnnotation = "k8s.v1.cni.cncf.io/network-status"
+
+// networkStatus is an element of the network status annotation.
+type networkStatus struct {
+	// Name of the network attachment, e.g. "default/sriov-net"
+	Name      string   `json:"name"`
+	Interface string   `json:"interface"`
+	IPs       []string `json:"ips"`
+	// Default is true for the cluster network
+	Default bool `json:"default"`
+}
+
+// MultusConfig describes which pods are probed over secondary networks attached by Multus.
+type MultusConfig struct {
+	Enabled bool
+	// Namespace and Selector select pods which secondary addresses are probed, all namespaces if Namespace is empty
+	Namespace string
+	Selector  string
+	// Networks limits probed network attachments, all attachments of the exporter pod are probed if empty
+	Networks []string
+	// PodName and PodNamespace identify the exporter pod, which attachments are used as sources
+	PodName      string
+	PodNamespace string
+}
+
+// MultusConfigFromEnv reads Multus discovery settings from environment.
+func MultusConfigFromEnv() (MultusConfig, error) {
+	cfg := MultusConfig{
+		Enabled:      utils.GetEnvWithDefaultValue("MULTUS_ENABLE", "false") == "true",
+		Namespace:    utils.GetEnvWithDefaultValue("MULTUS_NAMESPACE", utils.GetNamespace()),
+		Selector:     utils.GetEnvWithDefaultValue("MULTUS_SELECTOR", "app.kubernetes.io/name=network-latency-exporter"),
+		PodName:      utils.GetEnvWithDefaultValue("POD_NAME", ""),
+		PodNamespace: utils.GetNamespace(),
+	}
+	for _, n := range strings.Split(utils.GetEnvWithDefaultValue("MULTUS_NETWORKS", ""), ",") {
+		if n = strings.TrimSpace(n); n != "" {
+			cfg.Networks = append(cfg.Networks, n)
+		}
+	}
+	if cfg.Enabled && cfg.PodName == "" {
+		return cfg, errors.New("POD_NAME must be set to discover Multus networks")
+	}
+	return cfg, nil
+}
+
+// parseNetworkStatus returns secondary networks from the network status annotation of the pod.
+func parseNetworkStatus(pod corev1.Pod) ([]networkStatus, error) {
+	value, ok := pod.Annotations[NetworkStatusAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var statuses []networkStatus
+	if err := json.Unmarshal([]byte(value), &statuses); err != nil {
+		return nil, errors.Wrapf(err, "Can't parse %s annotation of pod %s/%s", NetworkStatusAnnotation, pod.Namespace, pod.Name)
+	}
+	var secondary []networkStatus
+	for _, s := range statuses {
+		if !s.Default && s.Name != "" {
+			secondary = append(secondary, s)
+		}
+	}
+	return secondary, nil
+}
+
+// DiscoverMultus returns a probe group per secondary network of the exporter pod with addresses of selected pods
+// in the network. Packets are sent from the interface of the exporter pod attached to the network.
+func DiscoverMultus(ctx context.Context, clientSet kubernetes.Interface, cfg MultusConfig, defaults model.ProbeGroup, logger log.Logger) ([]model.ProbeGroup, error) {
+	if clientSet == nil {
+		return nil, errors.New("There is no connection to Kubernetes")
+	}
+	self, err := clientSet.CoreV1().Pods(cfg.PodNamespace).Get(ctx, cfg.PodName, metav1.GetOptions{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Can't get exporter pod %s/%s", cfg.PodNamespace, cfg.PodName)
+	}
+	local, err := parseNetworkStatus(*self)
+	if err != nil {
+		return nil, err
+	}
+	allowed := make(map[string]bool, len(cfg.Networks))
+	for _, n := range cfg.Networks {
+		allowed[n] = true
+	}
+	interfaces := make(map[string]string)
+	for _, s := range local {
+		if len(allowed) == 0 || allowed[s.Name] {
+			interfaces[s.Name] = s.Interface
+		}
+	}
+	if len(interfaces) == 0 {
+		_ = level.Debug(logger).Log("msg", "Exporter pod has no secondary networks attached by Multus")
+		return nil, nil
+	}
+
+	pods, err := clientSet.CoreV1().Pods(cfg.Namespace).List(ctx, metav1.ListOptions{LabelSelector: cfg.Selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't list pods with Multus networks")
+	}
+	targets := make(map[string][]metrics.PingHost)
+	for _, p := range pods.Items {
+		if (p.Namespace == self.Namespace && p.Name == self.Name) || p.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		statuses, err := parseNetworkStatus(p)
+		if err != nil {
+			_ = level.Warn(logger).Log("msg", fmt.Sprintf("Skip pod %s/%s", p.Namespace, p.Name), "err", err)
+			continue
+		}
+		for _, s := range statuses {
+			if _, ok := interfaces[s.Name]; !ok {
+				continue
+			}
+			for _, ip := range s.IPs {
+				targets[s.Name] = append(targets[s.Name], metrics.PingHost{IPAddress: ip, Name: p.Name})
+			}
+		}
+	}
+
+	names := make([]string, 0, len(interfaces))
+	for name := range interfaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var groups []model.ProbeGroup
+	for _, name := range names {
+		hosts := targets[name]
+		sort.Slice(hosts, func(i, j int) bool { return hosts[i].IPAddress < hosts[j].IPAddress })
+		group := defaults
+		group.Network = name
+		group.Interface = interfaces[name]
+		group.Targets = metrics.PingHostList{Targets: hosts}
+		// Targets are named by pods, so they are not used to evaluate node reachability
+		group.Kind = metrics.DestinationPod
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
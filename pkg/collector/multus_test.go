@@ -0,0 +1,73 @@
+package collector
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestDiscoverMultus(t *testing.T) {
+	pod := func(name string, phase corev1.PodPhase, status string) *corev1.Pod {
+		p := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Namespace:   "monitoring",
+			Labels:      map[string]string{"app": "exporter"},
+			Annotations: map[string]string{},
+		}}
+		if status != "" {
+			p.Annotations[NetworkStatusAnnotation] = status
+		}
+		p.Status.Phase = phase
+		return p
+	}
+	clientSet := fake.NewSimpleClientset(
+		pod("exporter-a", corev1.PodRunning, `[
+			{"name": "cluster", "interface": "eth0", "ips": ["10.0.0.1"], "default": true},
+			{"name": "monitoring/sriov-a", "interface": "net1", "ips": ["192.168.1.1"]},
+			{"name": "monitoring/sriov-b", "interface": "net2", "ips": ["192.168.2.1"]}]`),
+		pod("exporter-b", corev1.PodRunning, `[
+			{"name": "cluster", "interface": "eth0", "ips": ["10.0.0.2"], "default": true},
+			{"name": "monitoring/sriov-a", "interface": "net1", "ips": ["192.168.1.2"]},
+			{"name": "monitoring/sriov-b", "interface": "net2", "ips": ["192.168.2.2"]}]`),
+		pod("exporter-c", corev1.PodPending, `[{"name": "monitoring/sriov-a", "interface": "net1", "ips": ["192.168.1.3"]}]`),
+		pod("exporter-d", corev1.PodRunning, `not json`),
+	)
+	cfg := MultusConfig{
+		Enabled:      true,
+		Namespace:    "monitoring",
+		Selector:     "app=exporter",
+		PodName:      "exporter-a",
+		PodNamespace: "monitoring",
+	}
+	defaults := model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3"}
+
+	groups, err := DiscoverMultus(context.Background(), clientSet, cfg, defaults, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "monitoring/sriov-a", groups[0].Network)
+	assert.Equal(t, "net1", groups[0].Interface)
+	assert.Equal(t, "10", groups[0].PacketsSent)
+	assert.Equal(t, metrics.DestinationPod, groups[0].Kind)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "192.168.1.2", Name: "exporter-b"}}, groups[0].Targets.Targets)
+	assert.Equal(t, "monitoring/sriov-b", groups[1].Network)
+	assert.Equal(t, "net2", groups[1].Interface)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "192.168.2.2", Name: "exporter-b"}}, groups[1].Targets.Targets)
+
+	cfg.Networks = []string{"monitoring/sriov-b"}
+	groups, err = DiscoverMultus(context.Background(), clientSet, cfg, defaults, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, groups, 1)
+	assert.Equal(t, "monitoring/sriov-b", groups[0].Network)
+
+	cfg.PodName = "missing"
+	_, err = DiscoverMultus(context.Background(), clientSet, cfg, defaults, log.NewNopLogger())
+	assert.Error(t, err)
+}
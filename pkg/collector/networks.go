@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// NetworkAddressAnnotationPrefix is a prefix of node annotations with node addresses in additional networks,
+// e.g. "networklatency.qubership.org/address-storage: 10.1.0.5" for the "storage" network.
+const NetworkAddressAnnotationPrefix = "networklatency.qubership.org/address-"
+
+// Network is an additional node network probed from a dedicated interface instead of the default route.
+type Network struct {
+	// Name of the network, used in the node annotation with peer address
+	Name string
+	// Interface is a name of the local interface which packets are sent from
+	Interface string
+	// Subnet of the network, the source address is the local address in the subnet.
+	// Used if interface names differ between nodes.
+	Subnet *net.IPNet
+}
+
+// interfaceAddrs returns IP addresses of local interfaces by interface name, replaced in tests.
+var interfaceAddrs = func() (map[string][]net.IP, error) {
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]net.IP, len(interfaces))
+	for _, i := range interfaces {
+		addrs, err := i.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			if ipNet, ok := a.(*net.IPNet); ok {
+				result[i.Name] = append(result[i.Name], ipNet.IP)
+			}
+		}
+	}
+	return result, nil
+}
+
+// ParseNetworks parses comma-separated list of networks in format "name=interface" or "name=subnet",
+// e.g. "storage=eth1,tenant=10.2.0.0/16".
+func ParseNetworks(value string) ([]Network, error) {
+	var networks []Network
+	seen := make(map[string]bool)
+	for _, n := range strings.Split(value, ",") {
+		n = strings.TrimSpace(n)
+		if n == "" {
+			continue
+		}
+		parts := strings.SplitN(n, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, errors.Errorf("Network %q must be specified as name=interface or name=subnet", n)
+		}
+		if seen[parts[0]] {
+			return nil, errors.Errorf("Network %s is specified more than once", parts[0])
+		}
+		seen[parts[0]] = true
+		network := Network{Name: parts[0]}
+		if strings.Contains(parts[1], "/") {
+			_, subnet, err := net.ParseCIDR(parts[1])
+			if err != nil {
+				return nil, errors.Wrapf(err, "Network %s has incorrect subnet", parts[0])
+			}
+			network.Subnet = subnet
+		} else {
+			network.Interface = parts[1]
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// source returns the local interface and, if network is defined by subnet, the source address.
+func (n Network) source() (string, string, error) {
+	if n.Subnet == nil {
+		return n.Interface, "", nil
+	}
+	addrs, err := interfaceAddrs()
+	if err != nil {
+		return "", "", errors.Wrap(err, "Can't list local interfaces")
+	}
+	names := make([]string, 0, len(addrs))
+	for name := range addrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, ip := range addrs[name] {
+			if n.Subnet.Contains(ip) {
+				return name, ip.String(), nil
+			}
+		}
+	}
+	return "", "", errors.Errorf("There is no local address in subnet %s", n.Subnet)
+}
+
+// peerAddress returns the node address in the network from the node annotation
+// or from node addresses if network is defined by subnet.
+func (n Network) peerAddress(node corev1.Node) string {
+	if address := node.Annotations[NetworkAddressAnnotationPrefix+n.Name]; address != "" {
+		return address
+	}
+	if n.Subnet != nil {
+		for _, a := range node.Status.Addresses {
+			if ip := net.ParseIP(a.Address); ip != nil && n.Subnet.Contains(ip) {
+				return a.Address
+			}
+		}
+	}
+	return ""
+}
+
+// DiscoverNetworks returns a probe group per network with addresses of other nodes in the network
+// and settings of the defaults group. Networks without local source are skipped.
+func DiscoverNetworks(clientSet kubernetes.Interface, networks []Network, defaults model.ProbeGroup, logger log.Logger) ([]model.ProbeGroup, error) {
+	nodes, err := getClusterNodes(clientSet)
+	if err != nil {
+		return nil, err
+	}
+	var groups []model.ProbeGroup
+	for _, n := range networks {
+		iface, address, err := n.source()
+		if err != nil {
+			_ = level.Warn(logger).Log("msg", fmt.Sprintf("Skip network %s", n.Name), "err", err)
+			continue
+		}
+		group := defaults
+		group.Network = n.Name
+		group.Interface = iface
+		group.SourceAddress = address
+		group.Targets = metrics.PingHostList{}
+		for _, node := range nodes {
+			// Skip the current node
+			if _, ok := NodeTarget(node); !ok {
+				continue
+			}
+			peer := n.peerAddress(node)
+			if peer == "" {
+				_ = level.Debug(logger).Log("msg", fmt.Sprintf("Node %s has no address in network %s", node.Name, n.Name))
+				continue
+			}
+			group.Targets.Targets = append(group.Targets.Targets, metrics.PingHost{IPAddress: peer, Name: node.Name})
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// NetworksFromEnv reads additional networks from PROBE_NETWORKS.
+func NetworksFromEnv() ([]Network, error) {
+	return ParseNetworks(utils.GetEnvWithDefaultValue("PROBE_NETWORKS", ""))
+}
@@ -0,0 +1,74 @@
+package collector
+
+import (
+	"net"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestParseNetworks(t *testing.T) {
+	networks, err := ParseNetworks("storage=eth1, tenant=10.2.0.0/16")
+	require.NoError(t, err)
+	require.Len(t, networks, 2)
+	assert.Equal(t, Network{Name: "storage", Interface: "eth1"}, networks[0])
+	assert.Equal(t, "tenant", networks[1].Name)
+	assert.Equal(t, "10.2.0.0/16", networks[1].Subnet.String())
+
+	for _, value := range []string{"storage", "storage=", "tenant=10.2.0.0/33", "a=eth1,a=eth2"} {
+		_, err = ParseNetworks(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestDiscoverNetworks(t *testing.T) {
+	t.Setenv("NODE_NAME", "node1")
+	defer func(f func() (map[string][]net.IP, error)) { interfaceAddrs = f }(interfaceAddrs)
+	interfaceAddrs = func() (map[string][]net.IP, error) {
+		return map[string][]net.IP{
+			"eth0": {net.ParseIP("10.0.0.1")},
+			"eth2": {net.ParseIP("10.2.0.1")},
+		}, nil
+	}
+	node := func(name string, annotations map[string]string, addresses ...string) *corev1.Node {
+		n := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Annotations: annotations}}
+		n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeHostName, Address: name})
+		for _, a := range addresses {
+			n.Status.Addresses = append(n.Status.Addresses, corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: a})
+		}
+		return n
+	}
+	clientSet := fake.NewSimpleClientset(
+		node("node1", map[string]string{NetworkAddressAnnotationPrefix + "storage": "10.1.0.1"}, "10.0.0.1", "10.2.0.1"),
+		node("node2", map[string]string{NetworkAddressAnnotationPrefix + "storage": "10.1.0.2"}, "10.0.0.2", "10.2.0.2"),
+		node("node3", nil, "10.0.0.3"),
+	)
+	networks, err := ParseNetworks("storage=eth1,tenant=10.2.0.0/16,missing=10.9.0.0/16")
+	require.NoError(t, err)
+	defaults := model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3"}
+
+	groups, err := DiscoverNetworks(clientSet, networks, defaults, log.NewNopLogger())
+	require.NoError(t, err)
+	require.Len(t, groups, 2)
+	assert.Equal(t, "eth1", groups[0].Interface)
+	assert.Equal(t, "", groups[0].SourceAddress)
+	assert.Equal(t, "10", groups[0].PacketsSent)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.1.0.2", Name: "node2"}}, groups[0].Targets.Targets)
+	assert.Equal(t, "eth2", groups[1].Interface)
+	assert.Equal(t, "10.2.0.1", groups[1].SourceAddress)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.2.0.2", Name: "node2"}}, groups[1].Targets.Targets)
+}
+
+func TestMtrArgsSource(t *testing.T) {
+	g := model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3", Interface: "eth1"}
+	assert.Equal(t, []string{"-I", "eth1"}, mtrArgs(g)[len(mtrArgs(g))-2:])
+	g.SourceAddress = "10.1.0.1"
+	assert.Equal(t, []string{"-a", "10.1.0.1"}, mtrArgs(g)[len(mtrArgs(g))-2:])
+}
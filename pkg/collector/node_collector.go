@@ -13,6 +13,7 @@ import (
 
 	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
 	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/responder"
 	"github.com/Netcracker/network-latency-exporter/pkg/utils"
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
@@ -25,6 +26,7 @@ var (
 		"UDP":  "--udp",
 		"ICMP": "",
 		"TCP":  "--tcp",
+		"SCTP": "--sctp",
 	}
 	nodeConfig model.NodeCollector
 	help       = map[string]string{
@@ -37,6 +39,16 @@ var (
 		"_rtt_stddev": "Standard deviation of packets mean RTT",
 		"_hops_num":   "Number of hops in packet path",
 	}
+	latencyLabels         = []string{"source", "destination", "destinationIp", "packets", "protocol", "port", "probe", "interface", "network", "dscp"}
+	healthDesc            = prometheus.NewDesc("network_latency_health", "Health of network latency: 0 if healthy, 1 if degraded, 2 if down", latencyLabels, nil)
+	thresholdDesc         = prometheus.NewDesc("network_latency_threshold_violation", "1 if the threshold is violated, 0 otherwise", append(append([]string{}, latencyLabels...), "group", "threshold"), nil)
+	statusChangesDesc     = prometheus.NewDesc("network_latency_status_changes_total", "Total number of health transitions", latencyLabels, nil)
+	statusSinceDesc       = prometheus.NewDesc("network_latency_status_since_seconds", "Time in seconds since the last health transition", latencyLabels, nil)
+	dnsLabels             = []string{"source", "resolver", "resolverIp", "port", "query"}
+	dnsResolutionTimeDesc = prometheus.NewDesc("network_latency_dns_resolution_time", "DNS resolution time in milliseconds", dnsLabels, nil)
+	dnsRcodeDesc          = prometheus.NewDesc("network_latency_dns_rcode", "Response code of the last DNS resolution, -1 if there is no response", dnsLabels, nil)
+	dnsStatusDesc         = prometheus.NewDesc("network_latency_dns_status", "Status of DNS resolver", dnsLabels, nil)
+	dnsTimeoutsDesc       = prometheus.NewDesc("network_latency_dns_timeouts_total", "Total number of DNS queries which have been timed out", dnsLabels, nil)
 )
 
 type NodeCollector struct {
@@ -48,6 +60,17 @@ type NodeCollector struct {
 	ProbeTimeout string
 	CheckTargets []*metrics.CheckTarget
 	Targets      metrics.PingHostList
+	dnsTimeouts  map[string]float64
+	pmtu         *pmtuProber
+	twamp        *twampProber
+	services     *serviceProber
+	thresholds   *Thresholds
+	states       *StateTracker
+	// peerPort is a port of responders of peer exporters, empty if responders are disabled
+	peerPort string
+	mutex    sync.Mutex
+	// configMutex guards nodeConfig and settings above, which are replaced when configuration is applied
+	configMutex sync.RWMutex
 }
 
 func init() {
@@ -64,12 +87,16 @@ func newNodeCollector(logger log.Logger) (Collector, error) {
 			prometheus.BuildFQName(namespace, "", NodeType.String()),
 			"Network latency metrics for nodes",
 			nil, nil),
-		ValueType: prometheus.GaugeValue,
-		Logger:    logger,
+		ValueType:   prometheus.GaugeValue,
+		Logger:      logger,
+		dnsTimeouts: make(map[string]float64),
 	}, nil
 }
 
 func (nodeCollector *NodeCollector) Initialize(ctx context.Context, config interface{}) error {
+	nodeCollector.configMutex.Lock()
+	defer nodeCollector.configMutex.Unlock()
+
 	cfg := reflect.ValueOf(config)
 	switch cfg.Kind() {
 	case reflect.Struct:
@@ -77,105 +104,195 @@ func (nodeCollector *NodeCollector) Initialize(ctx context.Context, config inter
 	default:
 		return errors.Errorf("Unsupported type: %v", cfg.Type())
 	}
+
+	if path := utils.GetEnvWithDefaultValue("THRESHOLDS_CONFIG", ""); path != "" {
+		thresholds, err := LoadThresholds(path)
+		if err != nil {
+			return err
+		}
+		nodeCollector.thresholds = thresholds
+	}
+
+	responderCfg, err := responder.ConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	nodeCollector.peerPort = ""
+	if responderCfg.Enabled {
+		nodeCollector.peerPort = responderCfg.Port
+	}
+
+	if nodeCollector.states == nil {
+		states, err := NewStateTracker()
+		if err != nil {
+			return err
+		}
+		nodeCollector.states = states
+	}
+
+	pmtu, err := newPMTUProber(nodeCollector.Logger)
+	if err != nil {
+		return err
+	}
+	if pmtu != nil && nodeCollector.pmtu == nil {
+		nodeCollector.pmtu = pmtu
+		go pmtu.run(ctx, currentTargets(nodeConfig))
+	}
+
+	twamp, err := newTWAMPProber(nodeCollector.Logger)
+	if err != nil {
+		return err
+	}
+	if twamp != nil && nodeCollector.twamp == nil {
+		nodeCollector.twamp = twamp
+		go twamp.run(ctx, currentTargets(nodeConfig))
+	}
+
+	services, err := newServiceProber(nodeConfig.ClientSet, nodeCollector.Logger)
+	if errors.Is(err, errNoServiceClientSet) {
+		_ = level.Warn(nodeCollector.Logger).Log("msg", err.Error())
+	} else if err != nil {
+		return err
+	}
+	if services != nil && nodeCollector.services == nil {
+		nodeCollector.services = services
+		go services.run(ctx, currentTargets(nodeConfig))
+	}
 	return nil
 }
 
 func (nodeCollector *NodeCollector) Scrape(ctx context.Context, mets *Metrics, ch chan<- prometheus.Metric) error {
+	// configuration is copied, so it can be applied while probes are running
+	nodeCollector.configMutex.RLock()
+	cfg, thresholds, peerPort := nodeConfig, nodeCollector.thresholds, nodeCollector.peerPort
+	pmtu, twamp, services := nodeCollector.pmtu, nodeCollector.twamp, nodeCollector.services
+	nodeCollector.configMutex.RUnlock()
 
 	var m []*metrics.NetworkLatencyMetric
-	// Command line args to run MTR
-	mtrArgs := []string{
-		"-G", // timeout for probe
-		nodeConfig.ProbeTimeout,
-		"-Z", // how long keep probe socket open
-		nodeConfig.ProbeTimeout,
-		"-n",     // print destination as IP address
-		"--json", // output format
-		"-s",     // packet size in bytes
-		nodeConfig.PacketSize,
-		"-c", // packets count to sent
-		nodeConfig.PacketsSent,
+
+	// DNS checks are executed against resolvers instead of discovered targets
+	var dnsChecks []*metrics.CheckTarget
+	dnsQueries := 0
+	for _, p := range cfg.CheckTargets {
+		if p.Protocol == DNSProtocol {
+			dnsChecks = append(dnsChecks, p)
+			dnsQueries += len(p.DNSResolvers)
+		}
+	}
+	groups := probeGroups(cfg)
+	probes := 0
+	for _, g := range groups {
+		for _, t := range g.Targets.Targets {
+			probes += len(targetChecks(mtrChecks(g.CheckTargets), t, peerPort))
+		}
 	}
 
 	// Prepare multi-threaded execution
 	var wg sync.WaitGroup
-	wg.Add(len(nodeConfig.Targets.Targets) * len(nodeConfig.CheckTargets)) // how many gorutines need to wait before ending
-	var execErr error                                                      // to propagate error from a separated thread to the main thread
-
-	//MTR takes approx 1 second for each packet sent
-	packets, er := strconv.Atoi(nodeConfig.PacketsSent)
-	if er != nil {
-		_ = level.Error(nodeCollector.Logger).Log("msg", fmt.Sprintf("Packets Sent has incorrect value %v", nodeConfig.PacketsSent))
-	}
+	wg.Add(probes + dnsQueries) // how many gorutines need to wait before ending
+	var execErr error           // to propagate error from a separated thread to the main thread
+	var resultsMutex sync.Mutex
+	var dnsResults []*metrics.DNSMetric
 
 	mtrTimeout := utils.GetEnvWithDefaultValue("MTR_TIMEOUT", "10")
 	extraTimeout, err := strconv.Atoi(mtrTimeout)
 	if err != nil {
 		_ = level.Error(nodeCollector.Logger).Log("msg", fmt.Sprintf("Error while converting timeout value %v", err))
 	}
-	timeout := (time.Duration(packets + extraTimeout)) * time.Second
 
-	// Collect metrics
-	for _, tgt := range nodeConfig.Targets.Targets {
-		// Execute mtr for each protocol in separate gorutine
-		for _, protocol := range nodeConfig.CheckTargets {
-			_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("Execute protocol %v on target %v", protocol, tgt.Name))
-			go func(t metrics.PingHost, p *metrics.CheckTarget) {
+	probeTimeout, err := strconv.Atoi(cfg.ProbeTimeout)
+	if err != nil {
+		_ = level.Error(nodeCollector.Logger).Log("msg", fmt.Sprintf("Probe timeout has incorrect value %v", cfg.ProbeTimeout))
+	}
+
+	// Collect DNS metrics
+	for _, check := range dnsChecks {
+		for _, resolver := range check.DNSResolvers {
+			go func(r metrics.DNSResolver, p *metrics.CheckTarget) {
 				defer wg.Done()
-				// Prepare arguments for mtr
-				args := make([]string, len(mtrArgs))
-				copy(args, mtrArgs)
-				args = append(args, p.MtrKey)
-				args = append(args, "-P")
-				args = append(args, p.Port)
-				args = append(args, t.IPAddress)
-
-				start := time.Now()
-				_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("Execute mtr %v", args))
-
-				//Build context timeout with 10 seconds extra
-				ctxTimeout, cancel := context.WithTimeout(context.Background(), timeout)
-				defer cancel()
-
-				// Execute mtr
-				output, err := exec.CommandContext(ctxTimeout, "mtr", args...).Output()
-				if err != nil {
-					_ = level.Error(nodeCollector.Logger).Log("msg", "failed to run mtr process: "+err.Error())
-					execErr = err
-				}
-				if ctxTimeout.Err() == context.DeadlineExceeded {
-					_ = level.Error(nodeCollector.Logger).Log("msg", "Process timeout")
-					execErr = ctxTimeout.Err()
-				}
-
-				// Parse output
-				mtrOutput := &metrics.MtrOutput{}
-				err = json.Unmarshal(output, mtrOutput)
-				if err != nil {
-					_ = level.Error(nodeCollector.Logger).Log("msg", "Error while unmarshalling mtr output"+err.Error())
-					execErr = err
-				}
-				end := time.Now()
-				_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("MTR output: %v. Finished in %v", mtrOutput, end.Sub(start)))
-
-				// Transform to metric.
-				// Read data from hop with host equals to target address.
-				// If there is no such hop mark target as unreachable and set zero values.
-				metric := metrics.NewNetworkLatencyMetric(t.Name, t.IPAddress, strings.ToUpper(p.Protocol), p.Port, nodeConfig.PacketsSent)
-				metric.Fields.HopsNum = len(mtrOutput.Report.Hops)
-				for _, hop := range mtrOutput.Report.Hops {
-					if hop.Host == t.IPAddress {
-						metric.Fields.Status = metrics.StatusOk // host has been reached
-						// Fill measures
-						metric.Fields.TotalReceived = metric.Fields.TotalSent - int(float64(metric.Fields.TotalSent)*(hop.Loss/100.0))
-						metric.Fields.RttMean = hop.RttMean
-						metric.Fields.RttMin = hop.RttMin
-						metric.Fields.RttMax = hop.RttMax
-						metric.Fields.RttDeviation = hop.RttDeviation
+				_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("Resolve %v with resolver %v", p.DNSQuery, r.Address))
+				result := probeDNS(ctx, r, p.Port, p.DNSQuery, time.Duration(probeTimeout)*time.Second)
+				resultsMutex.Lock()
+				dnsResults = append(dnsResults, result)
+				resultsMutex.Unlock()
+			}(resolver, check)
+		}
+	}
+
+	// Collect metrics
+	for _, group := range groups {
+		for _, tgt := range group.Targets.Targets {
+			// Execute mtr for each protocol in separate gorutine
+			for _, protocol := range targetChecks(mtrChecks(group.CheckTargets), tgt, peerPort) {
+				_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("Execute protocol %v on target %v", protocol, tgt.Name))
+				go func(t metrics.PingHost, p *metrics.CheckTarget, g model.ProbeGroup) {
+					defer wg.Done()
+					// Prepare arguments for mtr
+					args := mtrArgs(g)
+					timeout := nodeCollector.mtrProcessTimeout(g, extraTimeout)
+					args = append(args, p.MtrKey)
+					if p.DSCP != "" {
+						args = append(args, "--tos", strconv.Itoa(p.ToS)) // mark packets with the traffic class
+					}
+					args = append(args, "-P")
+					args = append(args, p.Port)
+					args = append(args, t.IPAddress)
+
+					start := time.Now()
+					_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("Execute mtr %v", args))
+
+					//Build context timeout with 10 seconds extra
+					ctxTimeout, cancel := context.WithTimeout(context.Background(), timeout)
+					defer cancel()
+
+					// Execute mtr
+					output, err := exec.CommandContext(ctxTimeout, "mtr", args...).Output()
+					if err != nil {
+						_ = level.Error(nodeCollector.Logger).Log("msg", "failed to run mtr process: "+err.Error())
+						execErr = err
+					}
+					if ctxTimeout.Err() == context.DeadlineExceeded {
+						_ = level.Error(nodeCollector.Logger).Log("msg", "Process timeout")
+						execErr = ctxTimeout.Err()
+					}
+
+					// Parse output
+					mtrOutput := &metrics.MtrOutput{}
+					err = json.Unmarshal(output, mtrOutput)
+					if err != nil {
+						_ = level.Error(nodeCollector.Logger).Log("msg", "Error while unmarshalling mtr output"+err.Error())
+						execErr = err
 					}
-				}
-				m = append(m, metric)
-			}(tgt, protocol)
+					end := time.Now()
+					_ = level.Debug(nodeCollector.Logger).Log("msg", fmt.Sprintf("MTR output: %v. Finished in %v", mtrOutput, end.Sub(start)))
+
+					// Transform to metric.
+					// Read data from hop with host equals to target address.
+					// If there is no such hop mark target as unreachable and set zero values.
+					metric := metrics.NewNetworkLatencyMetric(t.Name, t.IPAddress, strings.ToUpper(p.Protocol), p.Port, g.PacketsSent)
+					metric.Tags.Probe = g.Name
+					metric.Tags.Interface = g.Interface
+					metric.Tags.Network = g.Network
+					metric.Tags.DSCP = p.DSCP
+					metric.Tags.Kind = destinationKind(g, t)
+					metric.Fields.HopsNum = len(mtrOutput.Report.Hops)
+					for _, hop := range mtrOutput.Report.Hops {
+						if hop.Host == t.IPAddress {
+							metric.Fields.Status = metrics.StatusOk // host has been reached
+							// Fill measures
+							metric.Fields.Loss = hop.Loss
+							metric.Fields.TotalReceived = metric.Fields.TotalSent - int(float64(metric.Fields.TotalSent)*(hop.Loss/100.0))
+							metric.Fields.RttMean = hop.RttMean
+							metric.Fields.RttMin = hop.RttMin
+							metric.Fields.RttMax = hop.RttMax
+							metric.Fields.RttDeviation = hop.RttDeviation
+						}
+					}
+					resultsMutex.Lock()
+					m = append(m, metric)
+					resultsMutex.Unlock()
+				}(tgt, protocol, checkGroup(group, protocol))
+			}
 		}
 	}
 
@@ -185,11 +302,30 @@ func (nodeCollector *NodeCollector) Scrape(ctx context.Context, mets *Metrics, c
 	}
 
 	nodeName := utils.GetEnvWithDefaultValue("NODE_NAME", "localhost")
+	for _, met := range m {
+		met.Tags.Source = nodeName
+	}
 
+	now := time.Now()
+	var transitions []StateTransition
 	metric_names := []string{"_status", "_sent", "_received", "_rtt_mean", "_rtt_min", "_rtt_max", "_rtt_stddev", "_hops_num"}
 	for _, met := range m {
-		labels := []string{"source", "destination", "destinationIp", "packets", "protocol", "port"}
-		labelValues := []string{nodeName, met.Tags.Dest, met.Tags.DestIp, strconv.Itoa(met.Fields.TotalSent), met.Tags.Protocol, met.Tags.Port}
+		labels := latencyLabels
+		labelValues := []string{nodeName, met.Tags.Dest, met.Tags.DestIp, strconv.Itoa(met.Fields.TotalSent), met.Tags.Protocol, met.Tags.Port, met.Tags.Probe, met.Tags.Interface, met.Tags.Network, met.Tags.DSCP}
+		for _, v := range thresholds.Evaluate(met) {
+			violated := 0.0
+			if v.Violated {
+				violated = 1.0
+			}
+			ch <- prometheus.MustNewConstMetric(thresholdDesc, prometheus.GaugeValue, violated, append(append([]string{}, labelValues...), v.Group, v.Threshold)...)
+		}
+		state, transition := nodeCollector.states.Observe(met, now)
+		if transition != nil {
+			transitions = append(transitions, *transition)
+		}
+		ch <- prometheus.MustNewConstMetric(healthDesc, prometheus.GaugeValue, float64(met.Fields.Health), labelValues...)
+		ch <- prometheus.MustNewConstMetric(statusChangesDesc, prometheus.CounterValue, float64(state.Changes), labelValues...)
+		ch <- prometheus.MustNewConstMetric(statusSinceDesc, prometheus.GaugeValue, now.Sub(state.Since).Seconds(), labelValues...)
 		for i, metricName := range metric_names {
 			buildInfo := prometheus.NewGaugeVec(
 				prometheus.GaugeOpts{
@@ -222,9 +358,133 @@ func (nodeCollector *NodeCollector) Scrape(ctx context.Context, mets *Metrics, c
 			buildInfo.MetricVec.Collect(ch)
 		}
 	}
+
+	nodeCollector.states.Prune(m)
+	notifyResultHandlers(ctx, nodeName, m)
+	notifyTransitionHandlers(ctx, transitions)
+
+	collectTargetInfo(nodeName, cfg.Targets.Targets, ch)
+	if pmtu != nil {
+		pmtu.collect(nodeName, ch)
+	}
+	if twamp != nil {
+		twamp.collect(nodeName, ch)
+	}
+	if services != nil {
+		services.collect(nodeName, ch)
+	}
+
+	nodeCollector.mutex.Lock()
+	defer nodeCollector.mutex.Unlock()
+	for _, res := range dnsResults {
+		labelValues := []string{nodeName, res.Resolver.Name, res.Resolver.Address, res.Port, res.Query}
+		key := strings.Join(labelValues[1:], "/")
+		if res.Timeout {
+			nodeCollector.dnsTimeouts[key]++
+		}
+		ch <- prometheus.MustNewConstMetric(dnsStatusDesc, prometheus.GaugeValue, float64(res.Status), labelValues...)
+		ch <- prometheus.MustNewConstMetric(dnsRcodeDesc, prometheus.GaugeValue, float64(res.Rcode), labelValues...)
+		ch <- prometheus.MustNewConstMetric(dnsResolutionTimeDesc, prometheus.GaugeValue, res.ResolutionTime, labelValues...)
+		ch <- prometheus.MustNewConstMetric(dnsTimeoutsDesc, prometheus.CounterValue, nodeCollector.dnsTimeouts[key], labelValues...)
+	}
 	return nil
 }
 
+// currentTargets returns function which returns the current targets of the node collector. Probers running
+// in background must not read nodeConfig, because it is replaced when configuration is applied.
+func currentTargets(cfg model.NodeCollector) func() []metrics.PingHost {
+	if cfg.CurrentTargets != nil {
+		return cfg.CurrentTargets
+	}
+	targets := cfg.Targets.Targets
+	return func() []metrics.PingHost { return targets }
+}
+
+// probeGroups returns groups defined by NetworkLatencyProbe resources
+// or a single group with default settings and discovered targets if there are no resources,
+// followed by control plane and additional network groups.
+func probeGroups(cfg model.NodeCollector) []model.ProbeGroup {
+	groups := cfg.Groups
+	if len(groups) == 0 {
+		groups = []model.ProbeGroup{{
+			PacketsSent:  cfg.PacketsSent,
+			PacketSize:   cfg.PacketSize,
+			ProbeTimeout: cfg.ProbeTimeout,
+			CheckTargets: cfg.CheckTargets,
+			Targets:      cfg.Targets,
+		}}
+	}
+	result := append(append([]model.ProbeGroup{}, groups...), cfg.ControlPlane...)
+	result = append(result, cfg.Networks...)
+	return append(result, cfg.Attachments...)
+}
+
+// mtrChecks returns checks which are executed with mtr.
+func mtrChecks(checkTargets []*metrics.CheckTarget) []*metrics.CheckTarget {
+	var checks []*metrics.CheckTarget
+	for _, p := range checkTargets {
+		if p.Protocol != DNSProtocol {
+			checks = append(checks, p)
+		}
+	}
+	return checks
+}
+
+// checkGroup returns settings of the group overridden by options of the check.
+func checkGroup(g model.ProbeGroup, p *metrics.CheckTarget) model.ProbeGroup {
+	if p.PacketsSent != "" {
+		g.PacketsSent = p.PacketsSent
+	}
+	if p.PacketSize != "" {
+		g.PacketSize = p.PacketSize
+	}
+	if p.ProbeTimeout != "" {
+		g.ProbeTimeout = p.ProbeTimeout
+	}
+	return g
+}
+
+// mtrArgs returns command line args to run mtr with settings of the group.
+func mtrArgs(g model.ProbeGroup) []string {
+	args := []string{
+		"-G", // timeout for probe
+		g.ProbeTimeout,
+		"-Z", // how long keep probe socket open
+		g.ProbeTimeout,
+		"-n",     // print destination as IP address
+		"--json", // output format
+		"-s",     // packet size in bytes
+		g.PacketSize,
+		"-c", // packets count to sent
+		g.PacketsSent,
+	}
+	if g.PacketInterval != "" {
+		args = append(args, "-i", g.PacketInterval) // interval between packets in seconds
+	}
+	if g.SourceAddress != "" {
+		args = append(args, "-a", g.SourceAddress) // bind outgoing packets to the address
+	} else if g.Interface != "" {
+		args = append(args, "-I", g.Interface) // send packets from the interface
+	}
+	return args
+}
+
+// mtrProcessTimeout returns how long mtr process can run for the group.
+// MTR takes approx 1 second (or the packet interval) for each packet sent.
+func (nodeCollector *NodeCollector) mtrProcessTimeout(g model.ProbeGroup, extraTimeout int) time.Duration {
+	packets, err := strconv.Atoi(g.PacketsSent)
+	if err != nil {
+		_ = level.Error(nodeCollector.Logger).Log("msg", fmt.Sprintf("Packets Sent has incorrect value %v", g.PacketsSent))
+	}
+	interval := 1.0
+	if g.PacketInterval != "" {
+		if interval, err = strconv.ParseFloat(g.PacketInterval, 64); err != nil || interval < 1 {
+			interval = 1.0
+		}
+	}
+	return time.Duration(float64(packets)*interval*float64(time.Second)) + time.Duration(extraTimeout)*time.Second
+}
+
 func (nodeCollector *NodeCollector) Type() Type {
 	return NodeType
 }
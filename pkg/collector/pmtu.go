@@ -0,0 +1,289 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// ipv4HeaderLen and icmpHeaderLen are subtracted from the probe size to get ICMP payload size
+	ipv4HeaderLen = 20
+	icmpHeaderLen = 8
+	// minIPv4MTU is the smallest MTU every IPv4 host must accept
+	minIPv4MTU = 576
+)
+
+var (
+	pmtuLabels          = []string{"source", "destination", "destinationIp"}
+	pathMTUDesc         = prometheus.NewDesc("network_latency_path_mtu_bytes", "Path MTU to destination discovered with DF-flagged ICMP probes, 0 if destination is unreachable", pmtuLabels, nil)
+	interfaceMTUDesc    = prometheus.NewDesc("network_latency_interface_mtu_bytes", "MTU of the local interface used to reach destination", pmtuLabels, nil)
+	pathMTUMismatch     = prometheus.NewDesc("network_latency_path_mtu_mismatch", "1 if path MTU is below the local interface MTU, 0 otherwise. Not exposed if destination is unreachable", pmtuLabels, nil)
+	icmpSeq             uint32
+	errIPv6NotSupported = errors.New("Path MTU discovery supports only IPv4 destinations")
+)
+
+// pmtuResult stores the last path MTU sweep result for a single destination.
+type pmtuResult struct {
+	Target       metrics.PingHost
+	PathMTU      int
+	InterfaceMTU int
+}
+
+// pmtuProber periodically runs path MTU discovery for all targets and caches results,
+// because binary search takes too long to be executed during scrape.
+type pmtuProber struct {
+	logger   log.Logger
+	minSize  int
+	maxSize  int
+	retries  int
+	timeout  time.Duration
+	interval time.Duration
+	results  map[string]pmtuResult
+	mutex    sync.RWMutex
+}
+
+// newPMTUProber reads path MTU discovery settings from environment.
+// Returns nil if path MTU discovery is disabled.
+func newPMTUProber(logger log.Logger) (*pmtuProber, error) {
+	if utils.GetEnvWithDefaultValue("PMTU_ENABLE", "false") != "true" {
+		return nil, nil
+	}
+	minSize, err := strconv.Atoi(utils.GetEnvWithDefaultValue("PMTU_MIN_SIZE", strconv.Itoa(minIPv4MTU)))
+	if err != nil {
+		return nil, errors.Wrap(err, "PMTU_MIN_SIZE has incorrect value")
+	}
+	// 0 means the search upper bound is the MTU of the local interface
+	maxSize, err := strconv.Atoi(utils.GetEnvWithDefaultValue("PMTU_MAX_SIZE", "0"))
+	if err != nil {
+		return nil, errors.Wrap(err, "PMTU_MAX_SIZE has incorrect value")
+	}
+	if maxSize != 0 && maxSize < minSize {
+		return nil, errors.Errorf("PMTU_MAX_SIZE %d is less than PMTU_MIN_SIZE %d", maxSize, minSize)
+	}
+	retries, err := strconv.Atoi(utils.GetEnvWithDefaultValue("PMTU_RETRIES", "2"))
+	if err != nil {
+		return nil, errors.Wrap(err, "PMTU_RETRIES has incorrect value")
+	}
+	timeout, err := time.ParseDuration(utils.GetEnvWithDefaultValue("PMTU_TIMEOUT", "1s"))
+	if err != nil {
+		return nil, errors.Wrap(err, "PMTU_TIMEOUT has incorrect value")
+	}
+	interval, err := time.ParseDuration(utils.GetEnvWithDefaultValue("PMTU_INTERVAL", "5m"))
+	if err != nil {
+		return nil, errors.Wrap(err, "PMTU_INTERVAL has incorrect value")
+	}
+	return &pmtuProber{
+		logger:   logger,
+		minSize:  minSize,
+		maxSize:  maxSize,
+		retries:  retries,
+		timeout:  timeout,
+		interval: interval,
+		results:  make(map[string]pmtuResult),
+	}, nil
+}
+
+// run executes path MTU discovery for targets returned by getTargets until context is done.
+func (p *pmtuProber) run(ctx context.Context, getTargets func() []metrics.PingHost) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		p.sweep(ctx, getTargets())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *pmtuProber) sweep(ctx context.Context, targets []metrics.PingHost) {
+	results := make(map[string]pmtuResult, len(targets))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, tgt := range targets {
+		wg.Add(1)
+		go func(t metrics.PingHost) {
+			defer wg.Done()
+			res, err := p.discover(ctx, t)
+			if err != nil {
+				_ = level.Warn(p.logger).Log("msg", fmt.Sprintf("Path MTU discovery failed for %s", t.IPAddress), "err", err)
+				return
+			}
+			_ = level.Debug(p.logger).Log("msg", fmt.Sprintf("Path MTU to %s is %d, interface MTU is %d", t.IPAddress, res.PathMTU, res.InterfaceMTU))
+			mutex.Lock()
+			results[t.IPAddress] = res
+			mutex.Unlock()
+		}(tgt)
+	}
+	wg.Wait()
+
+	p.mutex.Lock()
+	p.results = results
+	p.mutex.Unlock()
+}
+
+func (p *pmtuProber) discover(ctx context.Context, t metrics.PingHost) (pmtuResult, error) {
+	dst := net.ParseIP(t.IPAddress)
+	if dst == nil || dst.To4() == nil {
+		return pmtuResult{}, errIPv6NotSupported
+	}
+	ifaceMTU, err := interfaceMTUFor(dst)
+	if err != nil {
+		return pmtuResult{}, err
+	}
+	maxSize := p.maxSize
+	if maxSize == 0 {
+		maxSize = ifaceMTU
+	}
+	minSize := p.minSize
+	if minSize > maxSize {
+		minSize = maxSize
+	}
+	pathMTU := searchPathMTU(minSize, maxSize, func(size int) bool {
+		for i := 0; i <= p.retries; i++ {
+			if ctx.Err() != nil {
+				return false
+			}
+			if err := probeDF(dst, size, p.timeout); err == nil {
+				return true
+			}
+		}
+		return false
+	})
+	return pmtuResult{Target: t, PathMTU: pathMTU, InterfaceMTU: ifaceMTU}, nil
+}
+
+// collect sends cached path MTU metrics to the channel.
+func (p *pmtuProber) collect(source string, ch chan<- prometheus.Metric) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, res := range p.results {
+		labelValues := []string{source, res.Target.Name, res.Target.IPAddress}
+		ch <- prometheus.MustNewConstMetric(pathMTUDesc, prometheus.GaugeValue, float64(res.PathMTU), labelValues...)
+		ch <- prometheus.MustNewConstMetric(interfaceMTUDesc, prometheus.GaugeValue, float64(res.InterfaceMTU), labelValues...)
+		// Unreachable destination says nothing about MTU of the path
+		if res.PathMTU == 0 {
+			continue
+		}
+		mismatch := 0.0
+		if res.PathMTU < res.InterfaceMTU {
+			mismatch = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(pathMTUMismatch, prometheus.GaugeValue, mismatch, labelValues...)
+	}
+}
+
+// searchPathMTU returns the biggest size in range [lo, hi] for which probe succeeds using binary search.
+// Returns 0 if even the smallest size can't pass.
+func searchPathMTU(lo int, hi int, probe func(size int) bool) int {
+	if !probe(lo) {
+		return 0
+	}
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if probe(mid) {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo
+}
+
+// interfaceMTUFor returns MTU of the local interface which is used to route packets to destination.
+func interfaceMTUFor(dst net.IP) (int, error) {
+	// UDP "connection" doesn't send anything, but selects source address by routing table
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return 0, err
+	}
+	localIP := conn.LocalAddr().(*net.UDPAddr).IP
+	_ = conn.Close()
+
+	interfaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range interfaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(localIP) {
+				return iface.MTU, nil
+			}
+		}
+	}
+	return 0, errors.Errorf("Can't find interface with address %s", localIP)
+}
+
+// probeDF sends ICMP echo request of the given total IP packet size with Don't Fragment flag
+// and waits for the matching echo reply.
+func probeDF(dst net.IP, size int, timeout time.Duration) error {
+	packetConn, err := net.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = packetConn.Close() }()
+	conn := packetConn.(*net.IPConn)
+	if err = setDontFragment(conn); err != nil {
+		return err
+	}
+
+	id := os.Getpid() & 0xffff
+	seq := int(atomic.AddUint32(&icmpSeq, 1) & 0xffff)
+	payloadSize := size - ipv4HeaderLen - icmpHeaderLen
+	if payloadSize < 0 {
+		payloadSize = 0
+	}
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Body: &icmp.Echo{ID: id, Seq: seq, Data: make([]byte, payloadSize)},
+	}
+	request, err := msg.Marshal(nil)
+	if err != nil {
+		return err
+	}
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err = conn.WriteTo(request, &net.IPAddr{IP: dst}); err != nil {
+		// EMSGSIZE is returned if packet doesn't fit into the local interface or cached path MTU
+		return err
+	}
+
+	buf := make([]byte, size+ipv4HeaderLen)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		if peerAddr, ok := peer.(*net.IPAddr); !ok || !peerAddr.IP.Equal(dst) {
+			continue
+		}
+		reply, err := icmp.ParseMessage(1, buf[:n])
+		if err != nil || reply.Type != ipv4.ICMPTypeEchoReply {
+			continue
+		}
+		if echo, ok := reply.Body.(*icmp.Echo); ok && echo.ID == id && echo.Seq == seq {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package collector
+
+import (
+	"net"
+	"syscall"
+)
+
+// setDontFragment sets DF flag for all packets sent via connection.
+// IP_PMTUDISC_PROBE is used to ignore path MTU cached by kernel, so each probe really goes to the network.
+func setDontFragment(conn *net.IPConn) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_IP, syscall.IP_MTU_DISCOVER, syscall.IP_PMTUDISC_PROBE)
+	})
+	if err != nil {
+		return err
+	}
+	return sockErr
+}
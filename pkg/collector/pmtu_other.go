@@ -0,0 +1,14 @@
+//go:build !linux
+
+package collector
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// setDontFragment is not implemented for non-Linux platforms.
+func setDontFragment(conn *net.IPConn) error {
+	return errors.New("Path MTU discovery is supported only on Linux")
+}
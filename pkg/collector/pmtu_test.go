@@ -0,0 +1,55 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSearchPathMTU checks that binary search finds the biggest size passing through the path.
+func TestSearchPathMTU(t *testing.T) {
+	pathMTU := 1450
+	probes := 0
+	probe := func(size int) bool {
+		probes++
+		return size <= pathMTU
+	}
+	assert.Equal(t, 1450, searchPathMTU(576, 1500, probe))
+	assert.LessOrEqual(t, probes, 12)
+	assert.Equal(t, 1500, searchPathMTU(576, 1500, func(size int) bool { return true }))
+	assert.Equal(t, 0, searchPathMTU(576, 1500, func(size int) bool { return false }))
+}
+
+// TestPMTUCollect checks that mismatch isn't reported for unreachable destinations.
+func TestPMTUCollect(t *testing.T) {
+	p := &pmtuProber{results: map[string]pmtuResult{
+		"10.0.0.2": {Target: metrics.PingHost{Name: "node-2", IPAddress: "10.0.0.2"}, PathMTU: 1450, InterfaceMTU: 1500},
+		"10.0.0.3": {Target: metrics.PingHost{Name: "node-3", IPAddress: "10.0.0.3"}, PathMTU: 1500, InterfaceMTU: 1500},
+		"10.0.0.4": {Target: metrics.PingHost{Name: "node-4", IPAddress: "10.0.0.4"}, PathMTU: 0, InterfaceMTU: 1500},
+	}}
+	ch := make(chan prometheus.Metric, 10)
+	p.collect("node-1", ch)
+	close(ch)
+
+	mismatch := make(map[string]float64)
+	var series int
+	for m := range ch {
+		series++
+		if m.Desc() != pathMTUMismatch {
+			continue
+		}
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		for _, l := range out.GetLabel() {
+			if l.GetName() == "destination" {
+				mismatch[l.GetValue()] = out.GetGauge().GetValue()
+			}
+		}
+	}
+	assert.Equal(t, 8, series)
+	assert.Equal(t, map[string]float64{"node-2": 1, "node-3": 0}, mismatch)
+}
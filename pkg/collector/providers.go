@@ -0,0 +1,378 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	NodesProvider          = "nodes"
+	EndpointSlicesProvider = "endpointslices"
+	DNSProvider            = "dns"
+	FileProvider           = "file"
+
+	// Labels set by providers on discovered targets
+	LabelProvider  = "provider"
+	LabelZone      = "zone"
+	LabelNamespace = "namespace"
+	LabelService   = "service"
+	LabelRecord    = "record"
+)
+
+var (
+	// targetInfoLabels is a fixed list of labels, because labels of a metric can't differ between series
+	targetInfoLabels = []string{"source", "destination", "destinationIp", LabelProvider, LabelZone, LabelNamespace, LabelService, LabelRecord}
+	targetInfoDesc   = prometheus.NewDesc("network_latency_target_info", "Discovered ping target with labels set by the discovery provider, always 1", targetInfoLabels, nil)
+)
+
+// Provider discovers ping targets from a single source.
+type Provider interface {
+	// Name returns provider name which is set to the "provider" label of discovered targets
+	Name() string
+	// Discover returns discovered targets
+	Discover(ctx context.Context) (*metrics.PingHostList, error)
+}
+
+// PeerTargets returns targets discovered by the nodes provider, which run peer exporters.
+func PeerTargets(targets []metrics.PingHost) []metrics.PingHost {
+	var peers []metrics.PingHost
+	for _, t := range targets {
+		if isPeer(t) {
+			peers = append(peers, t)
+		}
+	}
+	return peers
+}
+
+func isPeer(t metrics.PingHost) bool {
+	return t.Labels[LabelProvider] == NodesProvider
+}
+
+// destinationKind returns kind of the target probed in the group.
+func destinationKind(g model.ProbeGroup, t metrics.PingHost) string {
+	if t.Kind != metrics.DestinationNode {
+		return t.Kind
+	}
+	return g.Kind
+}
+
+// withLabels returns host with provider labels, labels already set on host take precedence.
+func withLabels(host metrics.PingHost, labels map[string]string) metrics.PingHost {
+	merged := make(map[string]string, len(labels)+len(host.Labels))
+	for k, v := range labels {
+		if v != "" {
+			merged[k] = v
+		}
+	}
+	for k, v := range host.Labels {
+		merged[k] = v
+	}
+	host.Labels = merged
+	return host
+}
+
+// nodeProvider discovers cluster nodes except the current one.
+type nodeProvider struct {
+	clientSet kubernetes.Interface
+}
+
+// NewNodeProvider returns provider which discovers cluster nodes by internal IP addresses.
+func NewNodeProvider(clientSet kubernetes.Interface) Provider {
+	return &nodeProvider{clientSet: clientSet}
+}
+
+func (p *nodeProvider) Name() string {
+	return NodesProvider
+}
+
+func (p *nodeProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	nodes, err := getClusterNodes(p.clientSet)
+	if err != nil {
+		return nil, err
+	}
+	targets := &metrics.PingHostList{}
+	for _, n := range nodes {
+		if target, ok := NodeTarget(n); ok {
+			targets.Targets = append(targets.Targets, withLabels(target, map[string]string{
+				LabelProvider: NodesProvider,
+				LabelZone:     n.Labels[corev1.LabelTopologyZone],
+			}))
+		}
+	}
+	return targets, nil
+}
+
+// endpointSliceProvider discovers ready endpoints of a Service.
+type endpointSliceProvider struct {
+	clientSet kubernetes.Interface
+	namespace string
+	service   string
+}
+
+// NewEndpointSliceProvider returns provider which discovers ready endpoints of the Service
+// from its EndpointSlices. Service is referenced as "namespace/name" or "name" in the default namespace.
+func NewEndpointSliceProvider(clientSet kubernetes.Interface, service string, defaultNamespace string) (Provider, error) {
+	namespace, name := defaultNamespace, service
+	if i := strings.Index(service, "/"); i >= 0 {
+		namespace, name = service[:i], service[i+1:]
+	}
+	if namespace == "" || name == "" {
+		return nil, errors.Errorf("Service %q must be specified as namespace/name", service)
+	}
+	return &endpointSliceProvider{clientSet: clientSet, namespace: namespace, service: name}, nil
+}
+
+func (p *endpointSliceProvider) Name() string {
+	return EndpointSlicesProvider
+}
+
+func (p *endpointSliceProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	if p.clientSet == nil {
+		return nil, errors.New("There is no connection to Kubernetes")
+	}
+	slices, err := p.clientSet.DiscoveryV1().EndpointSlices(p.namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", discoveryv1.LabelServiceName, p.service),
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "Can't list EndpointSlices of Service %s/%s", p.namespace, p.service)
+	}
+	targets := &metrics.PingHostList{}
+	for _, s := range slices.Items {
+		for _, e := range s.Endpoints {
+			if e.Conditions.Ready != nil && !*e.Conditions.Ready {
+				continue
+			}
+			for _, address := range e.Addresses {
+				name := address
+				if e.TargetRef != nil && e.TargetRef.Name != "" {
+					name = e.TargetRef.Name
+				}
+				zone := ""
+				if e.Zone != nil {
+					zone = *e.Zone
+				}
+				targets.Targets = append(targets.Targets, withLabels(metrics.PingHost{IPAddress: address, Name: name, Kind: metrics.DestinationHost}, map[string]string{
+					LabelProvider:  EndpointSlicesProvider,
+					LabelZone:      zone,
+					LabelNamespace: p.namespace,
+					LabelService:   p.service,
+				}))
+			}
+		}
+	}
+	return targets, nil
+}
+
+// dnsResolver is a subset of net.Resolver used by DNS provider.
+type dnsResolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// dnsProvider discovers targets from DNS records.
+type dnsProvider struct {
+	names    []string
+	resolver dnsResolver
+}
+
+// NewDNSProvider returns provider which resolves names to targets. Names which start with underscore,
+// e.g. "_mtr._udp.example.com", are resolved as SRV records and targets of SRV records are resolved as A records,
+// other names are resolved as A records.
+func NewDNSProvider(names []string) Provider {
+	return &dnsProvider{names: names, resolver: net.DefaultResolver}
+}
+
+func (p *dnsProvider) Name() string {
+	return DNSProvider
+}
+
+func (p *dnsProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	targets := &metrics.PingHostList{}
+	for _, name := range p.names {
+		hosts := []string{name}
+		if strings.HasPrefix(name, "_") {
+			_, records, err := p.resolver.LookupSRV(ctx, "", "", name)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Can't resolve SRV record %s", name)
+			}
+			hosts = hosts[:0]
+			for _, r := range records {
+				hosts = append(hosts, strings.TrimSuffix(r.Target, "."))
+			}
+		}
+		for _, host := range hosts {
+			addresses, err := p.resolver.LookupHost(ctx, host)
+			if err != nil {
+				return nil, errors.Wrapf(err, "Can't resolve %s", host)
+			}
+			for _, address := range addresses {
+				targets.Targets = append(targets.Targets, withLabels(metrics.PingHost{IPAddress: address, Name: host, Kind: metrics.DestinationHost}, map[string]string{
+					LabelProvider: DNSProvider,
+					LabelRecord:   name,
+				}))
+			}
+		}
+	}
+	return targets, nil
+}
+
+// fileProvider reads targets from YAML file.
+type fileProvider struct {
+	path string
+}
+
+// NewFileProvider returns provider which reads targets from YAML file in the following format:
+//
+//	targets:
+//	  - ipAddress: 10.0.0.1
+//	    name: gateway
+//	    labels:
+//	      zone: zone-a
+//
+// The file is read on each discovery, so changes of mounted ConfigMap are applied on the next discovery.
+func NewFileProvider(path string) Provider {
+	return &fileProvider{path: path}
+}
+
+func (p *fileProvider) Name() string {
+	return FileProvider
+}
+
+func (p *fileProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Can't read targets file %s", p.path)
+	}
+	list := &metrics.PingHostList{}
+	if err = yaml.Unmarshal(data, list); err != nil {
+		return nil, errors.Wrapf(err, "Can't parse targets file %s", p.path)
+	}
+	targets := &metrics.PingHostList{}
+	for _, t := range list.Targets {
+		t.Kind = metrics.DestinationHost
+		targets.Targets = append(targets.Targets, withLabels(t, map[string]string{LabelProvider: FileProvider}))
+	}
+	return targets, nil
+}
+
+// combinedProvider merges targets of several providers.
+type combinedProvider struct {
+	providers []Provider
+	logger    log.Logger
+}
+
+// Combine returns provider which merges targets of all providers. Targets with the same IP address
+// are deduplicated, the first provider in the list wins. Errors of single providers are logged
+// and an error is returned only if all providers fail.
+func Combine(logger log.Logger, providers ...Provider) Provider {
+	return &combinedProvider{providers: providers, logger: logger}
+}
+
+func (p *combinedProvider) Name() string {
+	names := make([]string, 0, len(p.providers))
+	for _, provider := range p.providers {
+		names = append(names, provider.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+func (p *combinedProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	targets := &metrics.PingHostList{}
+	seen := make(map[string]bool)
+	var lastErr error
+	failed := 0
+	for _, provider := range p.providers {
+		list, err := provider.Discover(ctx)
+		if err != nil {
+			_ = level.Warn(p.logger).Log("msg", fmt.Sprintf("Can't discover targets with provider %s", provider.Name()), "err", err)
+			lastErr = err
+			failed++
+			continue
+		}
+		for _, t := range list.Targets {
+			if seen[t.IPAddress] {
+				_ = level.Debug(p.logger).Log("msg", fmt.Sprintf("Skip duplicated target {ipAddress: %s, name: %s} discovered by %s", t.IPAddress, t.Name, provider.Name()))
+				continue
+			}
+			seen[t.IPAddress] = true
+			targets.Targets = append(targets.Targets, t)
+		}
+	}
+	if failed > 0 && failed == len(p.providers) {
+		return nil, lastErr
+	}
+	return targets, nil
+}
+
+// ProvidersFromEnv builds discovery providers listed in DISCOVERY_PROVIDERS.
+func ProvidersFromEnv(clientSet kubernetes.Interface, logger log.Logger) (Provider, error) {
+	var providers []Provider
+	for _, name := range strings.Split(utils.GetEnvWithDefaultValue("DISCOVERY_PROVIDERS", NodesProvider), ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+			continue
+		case NodesProvider:
+			providers = append(providers, NewNodeProvider(clientSet))
+		case EndpointSlicesProvider:
+			service := utils.GetEnvWithDefaultValue("DISCOVERY_SERVICE", "")
+			if service == "" {
+				return nil, errors.New("DISCOVERY_SERVICE must be set for endpointslices provider")
+			}
+			provider, err := NewEndpointSliceProvider(clientSet, service, utils.GetNamespace())
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		case DNSProvider:
+			var names []string
+			for _, n := range strings.Split(utils.GetEnvWithDefaultValue("DISCOVERY_DNS_NAMES", ""), ",") {
+				if n = strings.TrimSpace(n); n != "" {
+					names = append(names, n)
+				}
+			}
+			if len(names) == 0 {
+				return nil, errors.New("DISCOVERY_DNS_NAMES must be set for dns provider")
+			}
+			providers = append(providers, NewDNSProvider(names))
+		case FileProvider:
+			path := utils.GetEnvWithDefaultValue("DISCOVERY_FILE", "")
+			if path == "" {
+				return nil, errors.New("DISCOVERY_FILE must be set for file provider")
+			}
+			providers = append(providers, NewFileProvider(path))
+		default:
+			return nil, errors.Errorf("Unknown discovery provider: %s", name)
+		}
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("DISCOVERY_PROVIDERS has no providers")
+	}
+	return Combine(logger, providers...), nil
+}
+
+// collectTargetInfo exposes labels of discovered targets.
+func collectTargetInfo(source string, targets []metrics.PingHost, ch chan<- prometheus.Metric) {
+	for _, t := range targets {
+		labelValues := []string{source, t.Name, t.IPAddress}
+		for _, l := range targetInfoLabels[3:] {
+			labelValues = append(labelValues, t.Labels[l])
+		}
+		ch <- prometheus.MustNewConstMetric(targetInfoDesc, prometheus.GaugeValue, 1, labelValues...)
+	}
+}
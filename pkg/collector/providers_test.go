@@ -0,0 +1,171 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+type fakeResolver struct {
+	srv   map[string][]*net.SRV
+	hosts map[string][]string
+}
+
+func (r *fakeResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	if records, ok := r.srv[name]; ok {
+		return name, records, nil
+	}
+	return "", nil, errors.Errorf("no such host %s", name)
+}
+
+func (r *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addresses, ok := r.hosts[host]; ok {
+		return addresses, nil
+	}
+	return nil, errors.Errorf("no such host %s", host)
+}
+
+type staticProvider struct {
+	name    string
+	targets []metrics.PingHost
+	err     error
+}
+
+func (p *staticProvider) Name() string {
+	return p.name
+}
+
+func (p *staticProvider) Discover(ctx context.Context) (*metrics.PingHostList, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &metrics.PingHostList{Targets: p.targets}, nil
+}
+
+func TestEndpointSliceProvider(t *testing.T) {
+	ready, notReady, zone := true, false, "zone-a"
+	slice := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "web-abc",
+			Namespace: "apps",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "web"},
+		},
+		Endpoints: []discoveryv1.Endpoint{
+			{Addresses: []string{"10.1.0.1"}, Conditions: discoveryv1.EndpointConditions{Ready: &ready}, Zone: &zone,
+				TargetRef: &corev1.ObjectReference{Kind: "Pod", Name: "web-1"}},
+			{Addresses: []string{"10.1.0.2"}, Conditions: discoveryv1.EndpointConditions{Ready: &notReady}},
+		},
+	}
+	other := &discoveryv1.EndpointSlice{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "db-abc",
+			Namespace: "apps",
+			Labels:    map[string]string{discoveryv1.LabelServiceName: "db"},
+		},
+		Endpoints: []discoveryv1.Endpoint{{Addresses: []string{"10.1.0.3"}}},
+	}
+	provider, err := NewEndpointSliceProvider(fake.NewSimpleClientset(slice, other), "apps/web", "monitoring")
+	require.NoError(t, err)
+
+	targets, err := provider.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.1.0.1", Name: "web-1", Kind: metrics.DestinationHost, Labels: map[string]string{
+		LabelProvider: EndpointSlicesProvider, LabelZone: "zone-a", LabelNamespace: "apps", LabelService: "web",
+	}}}, targets.Targets)
+	// endpoints are not nodes even in the default probe group
+	assert.Equal(t, metrics.DestinationHost, destinationKind(model.ProbeGroup{}, targets.Targets[0]))
+
+	_, err = NewEndpointSliceProvider(nil, "apps/", "monitoring")
+	assert.Error(t, err)
+}
+
+func TestDNSProvider(t *testing.T) {
+	provider := &dnsProvider{
+		names: []string{"_mtr._udp.example.com", "gateway.example.com"},
+		resolver: &fakeResolver{
+			srv: map[string][]*net.SRV{"_mtr._udp.example.com": {{Target: "a.example.com."}, {Target: "b.example.com."}}},
+			hosts: map[string][]string{
+				"a.example.com":       {"10.2.0.1"},
+				"b.example.com":       {"10.2.0.2"},
+				"gateway.example.com": {"10.2.0.254"},
+			},
+		},
+	}
+	targets, err := provider.Discover(context.Background())
+	require.NoError(t, err)
+	srv := map[string]string{LabelProvider: DNSProvider, LabelRecord: "_mtr._udp.example.com"}
+	assert.Equal(t, []metrics.PingHost{
+		{IPAddress: "10.2.0.1", Name: "a.example.com", Labels: srv, Kind: metrics.DestinationHost},
+		{IPAddress: "10.2.0.2", Name: "b.example.com", Labels: srv, Kind: metrics.DestinationHost},
+		{IPAddress: "10.2.0.254", Name: "gateway.example.com", Labels: map[string]string{LabelProvider: DNSProvider, LabelRecord: "gateway.example.com"}, Kind: metrics.DestinationHost},
+	}, targets.Targets)
+
+	provider.names = []string{"unknown.example.com"}
+	_, err = provider.Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestFileProvider(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "targets.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+targets:
+  - ipAddress: 10.3.0.1
+    name: gateway
+    labels:
+      zone: zone-b
+  - ipAddress: 10.3.0.2
+`), 0o600))
+
+	targets, err := NewFileProvider(path).Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.PingHost{
+		{IPAddress: "10.3.0.1", Name: "gateway", Labels: map[string]string{LabelProvider: FileProvider, LabelZone: "zone-b"}, Kind: metrics.DestinationHost},
+		{IPAddress: "10.3.0.2", Labels: map[string]string{LabelProvider: FileProvider}, Kind: metrics.DestinationHost},
+	}, targets.Targets)
+
+	_, err = NewFileProvider(filepath.Join(t.TempDir(), "missing.yaml")).Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestCombine(t *testing.T) {
+	first := &staticProvider{name: "first", targets: []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "a"}}}
+	second := &staticProvider{name: "second", targets: []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "dup"}, {IPAddress: "10.0.0.2", Name: "b"}}}
+	broken := &staticProvider{name: "broken", err: errors.New("failed")}
+
+	provider := Combine(log.NewNopLogger(), first, broken, second)
+	assert.Equal(t, "first,broken,second", provider.Name())
+	targets, err := provider.Discover(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []metrics.PingHost{{IPAddress: "10.0.0.1", Name: "a"}, {IPAddress: "10.0.0.2", Name: "b"}}, targets.Targets)
+
+	_, err = Combine(log.NewNopLogger(), broken).Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestProvidersFromEnv(t *testing.T) {
+	t.Setenv("DISCOVERY_PROVIDERS", "nodes,dns")
+	_, err := ProvidersFromEnv(nil, log.NewNopLogger())
+	assert.Error(t, err, "dns provider requires names")
+
+	t.Setenv("DISCOVERY_DNS_NAMES", "gateway.example.com")
+	provider, err := ProvidersFromEnv(nil, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, "nodes,dns", provider.Name())
+
+	t.Setenv("DISCOVERY_PROVIDERS", "consul")
+	_, err = ProvidersFromEnv(nil, log.NewNopLogger())
+	assert.Error(t, err)
+}
@@ -0,0 +1,349 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	ServiceProbeTCP  = "TCP"
+	ServiceProbeHTTP = "HTTP"
+	// ServiceProbePathAnnotation sets path requested by HTTP probes of the Service, "/" by default
+	ServiceProbePathAnnotation = "networklatency.qubership.org/probe-path"
+)
+
+var (
+	serviceLabels         = []string{"source", "namespace", "service", "type", "destination", "destinationIp", "protocol", "port"}
+	serviceStatusDesc     = prometheus.NewDesc("network_latency_service_status", "Status of Service probe: 0 if at least one attempt succeeded, 1 otherwise", serviceLabels, nil)
+	serviceSentDesc       = prometheus.NewDesc("network_latency_service_sent", "Number of connection attempts to Service", serviceLabels, nil)
+	serviceReceivedDesc   = prometheus.NewDesc("network_latency_service_received", "Number of successful connection attempts to Service", serviceLabels, nil)
+	serviceLossDesc       = prometheus.NewDesc("network_latency_service_loss", "Percent of failed connection attempts to Service", serviceLabels, nil)
+	serviceRttMeanDesc    = prometheus.NewDesc("network_latency_service_rtt_mean", "Average time of TCP connect or HTTP response from Service in milliseconds", serviceLabels, nil)
+	serviceRttMinDesc     = prometheus.NewDesc("network_latency_service_rtt_min", "Best time of TCP connect or HTTP response from Service in milliseconds", serviceLabels, nil)
+	serviceRttMaxDesc     = prometheus.NewDesc("network_latency_service_rtt_max", "Worst time of TCP connect or HTTP response from Service in milliseconds", serviceLabels, nil)
+	serviceHTTPCodeDesc   = prometheus.NewDesc("network_latency_service_http_status_code", "HTTP status code of the last successful HTTP probe of Service", serviceLabels, nil)
+	defaultServiceTypes   = []string{string(corev1.ServiceTypeClusterIP), string(corev1.ServiceTypeNodePort)}
+	errNoServiceClientSet = errors.New("Service probes are disabled, because there is no connection to Kubernetes")
+)
+
+// serviceTarget is a single address of a Service which is probed, ClusterIP or NodePort of a node.
+type serviceTarget struct {
+	Namespace   string
+	Service     string
+	Type        string
+	Destination string
+	IPAddress   string
+	Port        int32
+	Protocol    string
+	Path        string
+}
+
+func (t serviceTarget) key() string {
+	return strings.Join([]string{t.Namespace, t.Service, t.Type, t.IPAddress, strconv.Itoa(int(t.Port))}, "/")
+}
+
+// serviceResult stores the last probe result for a single Service address.
+type serviceResult struct {
+	Target   serviceTarget
+	Sent     int
+	Received int
+	RttMin   float64
+	RttMean  float64
+	RttMax   float64
+	HTTPCode int
+}
+
+// serviceProber periodically discovers Services and probes them with TCP connect or HTTP requests.
+// Connections go through kube-proxy, IPVS or eBPF load balancing, so results can be compared
+// with direct node-to-node probes.
+type serviceProber struct {
+	logger     log.Logger
+	clientSet  kubernetes.Interface
+	selector   string
+	namespaces []string
+	types      map[string]bool
+	attempts   int
+	timeout    time.Duration
+	interval   time.Duration
+	results    map[string]serviceResult
+	mutex      sync.RWMutex
+}
+
+// newServiceProber reads Service probe settings from environment.
+// Returns nil if Service probes are disabled.
+func newServiceProber(clientSet kubernetes.Interface, logger log.Logger) (*serviceProber, error) {
+	if utils.GetEnvWithDefaultValue("SERVICE_PROBE_ENABLE", "false") != "true" {
+		return nil, nil
+	}
+	if clientSet == nil {
+		return nil, errNoServiceClientSet
+	}
+	interval, err := time.ParseDuration(utils.GetEnvWithDefaultValue("SERVICE_PROBE_INTERVAL", "30s"))
+	if err != nil || interval <= 0 {
+		return nil, errors.Errorf("SERVICE_PROBE_INTERVAL has incorrect value %s", utils.GetEnvWithDefaultValue("SERVICE_PROBE_INTERVAL", "30s"))
+	}
+	timeout, err := time.ParseDuration(utils.GetEnvWithDefaultValue("SERVICE_PROBE_TIMEOUT", "2s"))
+	if err != nil || timeout <= 0 {
+		return nil, errors.Errorf("SERVICE_PROBE_TIMEOUT has incorrect value %s", utils.GetEnvWithDefaultValue("SERVICE_PROBE_TIMEOUT", "2s"))
+	}
+	attempts, err := strconv.Atoi(utils.GetEnvWithDefaultValue("SERVICE_PROBE_ATTEMPTS", "3"))
+	if err != nil || attempts <= 0 {
+		return nil, errors.Errorf("SERVICE_PROBE_ATTEMPTS has incorrect value %s", utils.GetEnvWithDefaultValue("SERVICE_PROBE_ATTEMPTS", "3"))
+	}
+	types := make(map[string]bool)
+	for _, t := range strings.Split(utils.GetEnvWithDefaultValue("SERVICE_PROBE_TYPES", strings.Join(defaultServiceTypes, ",")), ",") {
+		t = strings.TrimSpace(t)
+		switch t {
+		case "":
+			continue
+		case string(corev1.ServiceTypeClusterIP), string(corev1.ServiceTypeNodePort):
+			types[t] = true
+		default:
+			return nil, errors.Errorf("SERVICE_PROBE_TYPES has unsupported Service type %s", t)
+		}
+	}
+	var namespaces []string
+	for _, ns := range strings.Split(utils.GetEnvWithDefaultValue("SERVICE_PROBE_NAMESPACES", ""), ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	return &serviceProber{
+		logger:     logger,
+		clientSet:  clientSet,
+		selector:   utils.GetEnvWithDefaultValue("SERVICE_PROBE_SELECTOR", "networklatency.qubership.org/probe=true"),
+		namespaces: namespaces,
+		types:      types,
+		attempts:   attempts,
+		timeout:    timeout,
+		interval:   interval,
+		results:    make(map[string]serviceResult),
+	}, nil
+}
+
+// run discovers and probes Services until context is done. NodePorts are probed on nodes returned by getTargets.
+func (p *serviceProber) run(ctx context.Context, getTargets func() []metrics.PingHost) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		targets, err := p.discover(ctx, getTargets())
+		if err != nil {
+			_ = level.Warn(p.logger).Log("msg", "Can't discover Services to probe", "err", err)
+		} else {
+			p.sweep(ctx, targets)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// discover lists Services matching selector and returns their ClusterIP and NodePort addresses.
+// Only TCP ports are probed, headless and ExternalName Services are skipped.
+func (p *serviceProber) discover(ctx context.Context, nodes []metrics.PingHost) ([]serviceTarget, error) {
+	var targets []serviceTarget
+	for _, ns := range p.namespaces {
+		services, err := p.clientSet.CoreV1().Services(ns).List(ctx, metav1.ListOptions{LabelSelector: p.selector})
+		if err != nil {
+			return nil, errors.Wrap(err, "Can't list Services")
+		}
+		for _, svc := range services.Items {
+			path := svc.Annotations[ServiceProbePathAnnotation]
+			if path == "" {
+				path = "/"
+			}
+			clusterIPs := svc.Spec.ClusterIPs
+			if len(clusterIPs) == 0 && svc.Spec.ClusterIP != "" {
+				clusterIPs = []string{svc.Spec.ClusterIP}
+			}
+			for _, port := range svc.Spec.Ports {
+				if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+					continue
+				}
+				target := serviceTarget{Namespace: svc.Namespace, Service: svc.Name, Protocol: serviceProbeProtocol(port), Path: path}
+				if p.types[string(corev1.ServiceTypeClusterIP)] && svc.Spec.Type != corev1.ServiceTypeExternalName {
+					for _, ip := range clusterIPs {
+						if ip == corev1.ClusterIPNone || ip == "" {
+							continue
+						}
+						t := target
+						t.Type, t.Destination, t.IPAddress, t.Port = string(corev1.ServiceTypeClusterIP), svc.Name, ip, port.Port
+						targets = append(targets, t)
+					}
+				}
+				if p.types[string(corev1.ServiceTypeNodePort)] && port.NodePort != 0 {
+					for _, node := range nodes {
+						if node.Labels[LabelProvider] != NodesProvider {
+							continue
+						}
+						t := target
+						t.Type, t.Destination, t.IPAddress, t.Port = string(corev1.ServiceTypeNodePort), node.Name, node.IPAddress, port.NodePort
+						targets = append(targets, t)
+					}
+				}
+			}
+		}
+	}
+	return targets, nil
+}
+
+// serviceProbeProtocol returns HTTP for ports which serve HTTP according to appProtocol or port name, TCP otherwise.
+func serviceProbeProtocol(port corev1.ServicePort) string {
+	if port.AppProtocol != nil {
+		if strings.EqualFold(*port.AppProtocol, "http") {
+			return ServiceProbeHTTP
+		}
+		return ServiceProbeTCP
+	}
+	if port.Name == "http" || strings.HasPrefix(port.Name, "http-") {
+		return ServiceProbeHTTP
+	}
+	return ServiceProbeTCP
+}
+
+func (p *serviceProber) sweep(ctx context.Context, targets []serviceTarget) {
+	results := make(map[string]serviceResult, len(targets))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, tgt := range targets {
+		wg.Add(1)
+		go func(t serviceTarget) {
+			defer wg.Done()
+			res := p.probe(ctx, t)
+			_ = level.Debug(p.logger).Log("msg", fmt.Sprintf("Service %s/%s probe to %s:%d: %d sent, %d received", t.Namespace, t.Service, t.IPAddress, t.Port, res.Sent, res.Received))
+			mutex.Lock()
+			results[t.key()] = res
+			mutex.Unlock()
+		}(tgt)
+	}
+	wg.Wait()
+
+	p.mutex.Lock()
+	p.results = results
+	p.mutex.Unlock()
+}
+
+// probe executes attempts one by one, each attempt opens a new connection,
+// so each of them passes through Service load balancing.
+func (p *serviceProber) probe(ctx context.Context, t serviceTarget) serviceResult {
+	res := serviceResult{Target: t}
+	address := net.JoinHostPort(t.IPAddress, strconv.Itoa(int(t.Port)))
+	client := &http.Client{
+		Timeout:   p.timeout,
+		Transport: &http.Transport{DisableKeepAlives: true},
+		// Redirect is a valid response of Service, it shouldn't be followed
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+	var total float64
+	for i := 0; i < p.attempts; i++ {
+		if ctx.Err() != nil {
+			break
+		}
+		res.Sent++
+		start := time.Now()
+		var err error
+		switch t.Protocol {
+		case ServiceProbeHTTP:
+			var code int
+			code, err = httpProbe(ctx, client, fmt.Sprintf("http://%s%s", address, t.Path))
+			if err == nil {
+				res.HTTPCode = code
+			}
+		default:
+			var conn net.Conn
+			dialer := &net.Dialer{Timeout: p.timeout}
+			conn, err = dialer.DialContext(ctx, "tcp", address)
+			if err == nil {
+				_ = conn.Close()
+			}
+		}
+		if err != nil {
+			_ = level.Debug(p.logger).Log("msg", fmt.Sprintf("Service %s/%s probe to %s failed", t.Namespace, t.Service, address), "err", err)
+			continue
+		}
+		rtt := float64(time.Since(start)) / float64(time.Millisecond)
+		if res.Received == 0 || rtt < res.RttMin {
+			res.RttMin = rtt
+		}
+		if rtt > res.RttMax {
+			res.RttMax = rtt
+		}
+		total += rtt
+		res.Received++
+	}
+	if res.Received > 0 {
+		res.RttMean = total / float64(res.Received)
+	}
+	return res
+}
+
+// httpProbe sends GET request and returns response status code. Any response means Service is reachable.
+func httpProbe(ctx context.Context, client *http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
+
+// collect sends cached Service probe metrics to the channel.
+func (p *serviceProber) collect(source string, ch chan<- prometheus.Metric) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, res := range p.results {
+		t := res.Target
+		labelValues := []string{source, t.Namespace, t.Service, t.Type, t.Destination, t.IPAddress, t.Protocol, strconv.Itoa(int(t.Port))}
+		status := float64(metrics.StatusUnreachable)
+		if res.Received > 0 {
+			status = metrics.StatusOk
+		}
+		loss := 0.0
+		if res.Sent > 0 {
+			loss = float64(res.Sent-res.Received) * 100 / float64(res.Sent)
+		}
+		ch <- prometheus.MustNewConstMetric(serviceStatusDesc, prometheus.GaugeValue, status, labelValues...)
+		ch <- prometheus.MustNewConstMetric(serviceSentDesc, prometheus.GaugeValue, float64(res.Sent), labelValues...)
+		ch <- prometheus.MustNewConstMetric(serviceReceivedDesc, prometheus.GaugeValue, float64(res.Received), labelValues...)
+		ch <- prometheus.MustNewConstMetric(serviceLossDesc, prometheus.GaugeValue, loss, labelValues...)
+		if res.Received == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(serviceRttMeanDesc, prometheus.GaugeValue, res.RttMean, labelValues...)
+		ch <- prometheus.MustNewConstMetric(serviceRttMinDesc, prometheus.GaugeValue, res.RttMin, labelValues...)
+		ch <- prometheus.MustNewConstMetric(serviceRttMaxDesc, prometheus.GaugeValue, res.RttMax, labelValues...)
+		if t.Protocol == ServiceProbeHTTP {
+			ch <- prometheus.MustNewConstMetric(serviceHTTPCodeDesc, prometheus.GaugeValue, float64(res.HTTPCode), labelValues...)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestServiceProber(t *testing.T) {
+	t.Setenv("SERVICE_PROBE_ENABLE", "true")
+	t.Setenv("SERVICE_PROBE_ATTEMPTS", "2")
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	tcpPort := listener.Addr().(*net.TCPAddr).Port
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/healthz", r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	httpPort := server.Listener.Addr().(*net.TCPAddr).Port
+
+	labels := map[string]string{"networklatency.qubership.org/probe": "true"}
+	clientSet := fake.NewSimpleClientset(
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "apps", Labels: labels},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeNodePort, ClusterIP: "127.0.0.1", Ports: []corev1.ServicePort{
+				{Name: "sql", Protocol: corev1.ProtocolTCP, Port: int32(tcpPort), NodePort: int32(tcpPort)},
+				{Name: "dns", Protocol: corev1.ProtocolUDP, Port: 53},
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "apps", Labels: labels,
+				Annotations: map[string]string{ServiceProbePathAnnotation: "/healthz"}},
+			Spec: corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "127.0.0.1", Ports: []corev1.ServicePort{
+				{Name: "http", Port: int32(httpPort)},
+			}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "apps", Labels: labels},
+			Spec:       corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone, Ports: []corev1.ServicePort{{Port: 80}}},
+		},
+		&corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "apps"},
+			Spec:       corev1.ServiceSpec{ClusterIP: "127.0.0.2", Ports: []corev1.ServicePort{{Port: 80}}},
+		},
+	)
+	prober, err := newServiceProber(clientSet, log.NewNopLogger())
+	require.NoError(t, err)
+	require.NotNil(t, prober)
+
+	nodes := []metrics.PingHost{
+		{IPAddress: "127.0.0.1", Name: "node2", Labels: map[string]string{LabelProvider: NodesProvider}},
+		{IPAddress: "10.3.0.1", Name: "gateway", Labels: map[string]string{LabelProvider: FileProvider}},
+	}
+	targets, err := prober.discover(context.Background(), nodes)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []serviceTarget{
+		{Namespace: "apps", Service: "db", Type: "ClusterIP", Destination: "db", IPAddress: "127.0.0.1", Port: int32(tcpPort), Protocol: ServiceProbeTCP, Path: "/"},
+		{Namespace: "apps", Service: "db", Type: "NodePort", Destination: "node2", IPAddress: "127.0.0.1", Port: int32(tcpPort), Protocol: ServiceProbeTCP, Path: "/"},
+		{Namespace: "apps", Service: "web", Type: "ClusterIP", Destination: "web", IPAddress: "127.0.0.1", Port: int32(httpPort), Protocol: ServiceProbeHTTP, Path: "/healthz"},
+	}, targets)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	prober.sweep(ctx, targets)
+	require.Len(t, prober.results, 3)
+	for _, res := range prober.results {
+		assert.Equal(t, 2, res.Sent)
+		assert.Equal(t, 2, res.Received)
+		assert.LessOrEqual(t, res.RttMin, res.RttMax)
+	}
+	assert.Equal(t, http.StatusNoContent, prober.results[targets[2].key()].HTTPCode)
+}
+
+func TestServiceProberDisabled(t *testing.T) {
+	prober, err := newServiceProber(nil, log.NewNopLogger())
+	assert.NoError(t, err)
+	assert.Nil(t, prober)
+
+	t.Setenv("SERVICE_PROBE_ENABLE", "true")
+	_, err = newServiceProber(nil, log.NewNopLogger())
+	assert.ErrorIs(t, err, errNoServiceClientSet)
+
+	t.Setenv("SERVICE_PROBE_TYPES", "LoadBalancer")
+	_, err = newServiceProber(fake.NewSimpleClientset(), log.NewNopLogger())
+	assert.Error(t, err)
+}
@@ -0,0 +1,132 @@
+package collector
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// pairState stores dampened health of a single probed pair.
+type pairState struct {
+	// Health is the current dampened health
+	Health int
+	// Since is the time of the last transition
+	Since time.Time
+	// Changes is a total number of transitions
+	Changes int
+	// pending is the worst observed health which differs from the current one in the same direction
+	pending int
+	// pendingCount is how many times in a row health worse (or better) than the current one has been observed
+	pendingCount int
+}
+
+// StateTransition describes change of a pair health.
+type StateTransition struct {
+	Metric *metrics.NetworkLatencyMetric
+	From   int
+	To     int
+}
+
+// StateTracker implements per-pair state machine which requires several consecutive
+// observations before changing health, so single lost probes don't flip the status.
+type StateTracker struct {
+	// failureThreshold is how many consecutive worse observations are required to degrade health
+	failureThreshold int
+	// successThreshold is how many consecutive better observations are required to recover health
+	successThreshold int
+	states           map[string]*pairState
+	mutex            sync.Mutex
+}
+
+// NewStateTracker reads STATUS_FAILURE_THRESHOLD and STATUS_SUCCESS_THRESHOLD from environment.
+func NewStateTracker() (*StateTracker, error) {
+	failureThreshold, err := strconv.Atoi(utils.GetEnvWithDefaultValue("STATUS_FAILURE_THRESHOLD", "1"))
+	if err != nil || failureThreshold < 1 {
+		return nil, errors.Errorf("STATUS_FAILURE_THRESHOLD should be a positive integer")
+	}
+	successThreshold, err := strconv.Atoi(utils.GetEnvWithDefaultValue("STATUS_SUCCESS_THRESHOLD", "1"))
+	if err != nil || successThreshold < 1 {
+		return nil, errors.Errorf("STATUS_SUCCESS_THRESHOLD should be a positive integer")
+	}
+	return &StateTracker{
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
+		states:           make(map[string]*pairState),
+	}, nil
+}
+
+// pairKey returns unique key of probed pair.
+func pairKey(m *metrics.NetworkLatencyMetric) string {
+	return m.Tags.Probe + "/" + m.Tags.Interface + "/" + m.Tags.Network + "/" + m.Tags.Dest + "/" + m.Tags.DestIp + "/" + m.Tags.Protocol + "/" + m.Tags.Port + "/" + m.Tags.DSCP
+}
+
+// Observe applies observed health of the metric to the pair state machine, then overrides metric
+// health and status with dampened values. Returns transition if health has been changed.
+func (s *StateTracker) Observe(m *metrics.NetworkLatencyMetric, now time.Time) (pairState, *StateTransition) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	observed := m.Fields.Health
+	key := pairKey(m)
+	state, found := s.states[key]
+	var transition *StateTransition
+	switch {
+	case !found:
+		state = &pairState{Health: observed, Since: now}
+		s.states[key] = state
+	case observed == state.Health:
+		state.pendingCount = 0
+	default:
+		// Consecutive observations worse (or better) than the current health are counted together
+		// and the worst of them is pending, so a pair alternating between degraded and down still changes health
+		worse := observed > state.Health
+		if state.pendingCount > 0 && (state.pending > state.Health) == worse {
+			state.pendingCount++
+			if observed > state.pending {
+				state.pending = observed
+			}
+		} else {
+			state.pending = observed
+			state.pendingCount = 1
+		}
+		required := s.successThreshold
+		if worse {
+			required = s.failureThreshold
+		}
+		if state.pendingCount >= required {
+			transition = &StateTransition{Metric: m, From: state.Health, To: state.pending}
+			state.Health = state.pending
+			state.Since = now
+			state.Changes++
+			state.pendingCount = 0
+		}
+	}
+
+	m.Fields.Health = state.Health
+	if state.Health == metrics.HealthDown {
+		m.Fields.Status = metrics.StatusUnreachable
+	} else {
+		m.Fields.Status = metrics.StatusOk
+	}
+	return *state, transition
+}
+
+// Prune removes states of pairs which are not probed anymore.
+func (s *StateTracker) Prune(current []*metrics.NetworkLatencyMetric) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	keys := make(map[string]bool, len(current))
+	for _, m := range current {
+		keys[pairKey(m)] = true
+	}
+	for key := range s.states {
+		if !keys[key] {
+			delete(s.states, key)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestStateTrackerDampening checks that health changes only after required consecutive observations.
+func TestStateTrackerDampening(t *testing.T) {
+	tracker := &StateTracker{failureThreshold: 3, successThreshold: 2, states: make(map[string]*pairState)}
+	now := time.Now()
+	observe := func(health int) (pairState, *StateTransition) {
+		m := metrics.NewNetworkLatencyMetric("node1", "1.2.3.4", "ICMP", "1", "10")
+		m.Fields.Health = health
+		now = now.Add(time.Minute)
+		return tracker.Observe(m, now)
+	}
+
+	state, transition := observe(metrics.HealthOk)
+	assert.Equal(t, metrics.HealthOk, state.Health)
+	assert.Nil(t, transition)
+
+	// Two failures are not enough, a success resets the counter
+	observe(metrics.HealthDown)
+	observe(metrics.HealthDown)
+	state, _ = observe(metrics.HealthOk)
+	assert.Equal(t, metrics.HealthOk, state.Health)
+
+	observe(metrics.HealthDown)
+	observe(metrics.HealthDown)
+	state, transition = observe(metrics.HealthDown)
+	assert.Equal(t, metrics.HealthDown, state.Health)
+	assert.Equal(t, &StateTransition{Metric: transition.Metric, From: metrics.HealthOk, To: metrics.HealthDown}, transition)
+	assert.Equal(t, metrics.StatusUnreachable, transition.Metric.Fields.Status)
+	assert.Equal(t, 1, state.Changes)
+	assert.Equal(t, now, state.Since)
+
+	observe(metrics.HealthOk)
+	state, transition = observe(metrics.HealthOk)
+	assert.Equal(t, metrics.HealthOk, state.Health)
+	assert.NotNil(t, transition)
+	assert.Equal(t, 2, state.Changes)
+}
+
+// TestStateTrackerAlternatingFailures checks that alternating degraded and down observations
+// are counted together and the worst of them is reported.
+func TestStateTrackerAlternatingFailures(t *testing.T) {
+	tracker := &StateTracker{failureThreshold: 3, successThreshold: 2, states: make(map[string]*pairState)}
+	now := time.Now()
+	observe := func(health int) (pairState, *StateTransition) {
+		m := metrics.NewNetworkLatencyMetric("node1", "1.2.3.4", "ICMP", "1", "10")
+		m.Fields.Health = health
+		now = now.Add(time.Minute)
+		return tracker.Observe(m, now)
+	}
+
+	observe(metrics.HealthOk)
+	observe(metrics.HealthDegraded)
+	observe(metrics.HealthDown)
+	state, transition := observe(metrics.HealthDegraded)
+	assert.Equal(t, metrics.HealthDown, state.Health)
+	assert.Equal(t, &StateTransition{Metric: transition.Metric, From: metrics.HealthOk, To: metrics.HealthDown}, transition)
+
+	// Recovery through degraded and healthy observations reports the worst of them
+	observe(metrics.HealthOk)
+	state, transition = observe(metrics.HealthDegraded)
+	assert.Equal(t, metrics.HealthDegraded, state.Health)
+	assert.Equal(t, &StateTransition{Metric: transition.Metric, From: metrics.HealthDown, To: metrics.HealthDegraded}, transition)
+}
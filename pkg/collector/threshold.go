@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ThresholdRttMean = "rtt_mean"
+	ThresholdRttMax  = "rtt_max"
+	ThresholdLoss    = "loss"
+	ThresholdJitter  = "jitter"
+)
+
+// thresholdGroup is a ThresholdGroup with compiled destination selector.
+type thresholdGroup struct {
+	model.ThresholdGroup
+	destination *regexp.Regexp
+}
+
+// Thresholds evaluates probe results against configured threshold groups.
+type Thresholds struct {
+	groups []thresholdGroup
+}
+
+// thresholdViolation describes the state of a single configured threshold for a pair.
+type thresholdViolation struct {
+	Group     string
+	Threshold string
+	Violated  bool
+}
+
+// LoadThresholds reads thresholds configuration from YAML file.
+func LoadThresholds(path string) (*Thresholds, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "Can't read thresholds configuration %s", path)
+	}
+	cfg := model.ThresholdConfig{}
+	if err = yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, errors.Wrapf(err, "Can't parse thresholds configuration %s", path)
+	}
+	return NewThresholds(cfg)
+}
+
+// NewThresholds validates configuration and compiles group selectors.
+func NewThresholds(cfg model.ThresholdConfig) (*Thresholds, error) {
+	t := &Thresholds{}
+	for i, g := range cfg.Groups {
+		if g.Name == "" {
+			return nil, errors.Errorf("Threshold group #%d has no name", i)
+		}
+		group := thresholdGroup{ThresholdGroup: g}
+		if g.Destination != "" {
+			re, err := regexp.Compile("^(?:" + g.Destination + ")$")
+			if err != nil {
+				return nil, errors.Wrapf(err, "Threshold group %s has invalid destination selector", g.Name)
+			}
+			group.destination = re
+		}
+		t.groups = append(t.groups, group)
+	}
+	return t, nil
+}
+
+// match returns the first group which selectors match the metric or nil.
+func (t *Thresholds) match(m *metrics.NetworkLatencyMetric) *thresholdGroup {
+	if t == nil {
+		return nil
+	}
+	for i := range t.groups {
+		g := &t.groups[i]
+		if g.Protocol != "" && !strings.EqualFold(g.Protocol, m.Tags.Protocol) {
+			continue
+		}
+		if g.destination != nil && !g.destination.MatchString(m.Tags.Dest) && !g.destination.MatchString(m.Tags.DestIp) {
+			continue
+		}
+		return g
+	}
+	return nil
+}
+
+// Evaluate checks the metric against thresholds of the matched group and sets its health.
+// Unreachable targets are always down, reachable targets with any violated threshold are degraded.
+func (t *Thresholds) Evaluate(m *metrics.NetworkLatencyMetric) []thresholdViolation {
+	var violations []thresholdViolation
+	if g := t.match(m); g != nil {
+		check := func(name string, limit *float64, value float64) {
+			if limit != nil {
+				violations = append(violations, thresholdViolation{Group: g.Name, Threshold: name, Violated: value > *limit})
+			}
+		}
+		check(ThresholdRttMean, g.RttMean, m.Fields.RttMean)
+		check(ThresholdRttMax, g.RttMax, m.Fields.RttMax)
+		check(ThresholdLoss, g.Loss, m.Fields.Loss)
+		check(ThresholdJitter, g.Jitter, m.Fields.RttDeviation)
+	}
+
+	m.Fields.Health = metrics.HealthOk
+	if m.Fields.Status == metrics.StatusUnreachable {
+		m.Fields.Health = metrics.HealthDown
+		return violations
+	}
+	for _, v := range violations {
+		if v.Violated {
+			m.Fields.Health = metrics.HealthDegraded
+		}
+	}
+	return violations
+}
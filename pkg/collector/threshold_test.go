@@ -0,0 +1,39 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestThresholdsEvaluate checks that the first matching group is used and health is calculated from violations.
+func TestThresholdsEvaluate(t *testing.T) {
+	rttMean, loss := 5.0, 10.0
+	thresholds, err := NewThresholds(model.ThresholdConfig{Groups: []model.ThresholdGroup{
+		{Name: "icmp-workers", Destination: "worker-.*", Protocol: "icmp", RttMean: &rttMean},
+		{Name: "default", Loss: &loss},
+	}})
+	assert.NoError(t, err)
+
+	m := metrics.NewNetworkLatencyMetric("worker-1", "1.2.3.4", "ICMP", "1", "10")
+	m.Fields.Status = metrics.StatusOk
+	m.Fields.RttMean = 7.5
+	m.Fields.Loss = 50
+	assert.Equal(t, []thresholdViolation{{Group: "icmp-workers", Threshold: ThresholdRttMean, Violated: true}}, thresholds.Evaluate(m))
+	assert.Equal(t, metrics.HealthDegraded, m.Fields.Health)
+
+	m = metrics.NewNetworkLatencyMetric("master-1", "1.2.3.5", "ICMP", "1", "10")
+	m.Fields.Status = metrics.StatusOk
+	m.Fields.Loss = 0
+	assert.Equal(t, []thresholdViolation{{Group: "default", Threshold: ThresholdLoss, Violated: false}}, thresholds.Evaluate(m))
+	assert.Equal(t, metrics.HealthOk, m.Fields.Health)
+
+	m = metrics.NewNetworkLatencyMetric("master-1", "1.2.3.5", "TCP", "80", "10")
+	thresholds.Evaluate(m)
+	assert.Equal(t, metrics.HealthDown, m.Fields.Health)
+
+	_, err = NewThresholds(model.ThresholdConfig{Groups: []model.ThresholdGroup{{Name: "broken", Destination: "("}}})
+	assert.Error(t, err)
+}
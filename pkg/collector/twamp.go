@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/twamp"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	twampForwardMeanDesc = prometheus.NewDesc("network_latency_twamp_forward_delay_mean", "Average one-way delay from source to destination measured with TWAMP-light", pmtuLabels, nil)
+	twampForwardMinDesc  = prometheus.NewDesc("network_latency_twamp_forward_delay_min", "Best one-way delay from source to destination measured with TWAMP-light", pmtuLabels, nil)
+	twampForwardMaxDesc  = prometheus.NewDesc("network_latency_twamp_forward_delay_max", "Worst one-way delay from source to destination measured with TWAMP-light", pmtuLabels, nil)
+	twampReverseMeanDesc = prometheus.NewDesc("network_latency_twamp_reverse_delay_mean", "Average one-way delay from destination to source measured with TWAMP-light", pmtuLabels, nil)
+	twampReverseMinDesc  = prometheus.NewDesc("network_latency_twamp_reverse_delay_min", "Best one-way delay from destination to source measured with TWAMP-light", pmtuLabels, nil)
+	twampReverseMaxDesc  = prometheus.NewDesc("network_latency_twamp_reverse_delay_max", "Worst one-way delay from destination to source measured with TWAMP-light", pmtuLabels, nil)
+	twampRttMeanDesc     = prometheus.NewDesc("network_latency_twamp_rtt_mean", "Average RTT without processing time of reflector measured with TWAMP-light", pmtuLabels, nil)
+	twampSentDesc        = prometheus.NewDesc("network_latency_twamp_sent", "Number of TWAMP-light test packets sent to destination", pmtuLabels, nil)
+	twampReceivedDesc    = prometheus.NewDesc("network_latency_twamp_received", "Number of TWAMP-light test packets reflected back from destination", pmtuLabels, nil)
+	twampForwardLossDesc = prometheus.NewDesc("network_latency_twamp_forward_loss", "Percent of TWAMP-light test packets lost from source to destination", pmtuLabels, nil)
+	twampReverseLossDesc = prometheus.NewDesc("network_latency_twamp_reverse_loss", "Percent of TWAMP-light test packets lost from destination to source", pmtuLabels, nil)
+	twampClockErrorDesc  = prometheus.NewDesc("network_latency_twamp_clock_error", "Sum of clock error estimates of source and destination, accuracy of one-way delays", pmtuLabels, nil)
+	twampClockSyncDesc   = prometheus.NewDesc("network_latency_twamp_clock_synchronized", "1 if clocks of both source and destination are synchronized to UTC, 0 otherwise", pmtuLabels, nil)
+	twampStatusDesc      = prometheus.NewDesc("network_latency_twamp_status", "Status of the last TWAMP-light session: 0 if succeeded, 1 if failed", pmtuLabels, nil)
+)
+
+// twampResult stores the last TWAMP-light session result for a single destination.
+type twampResult struct {
+	Target metrics.PingHost
+	// Status is metrics.StatusUnreachable if the session failed, other fields are empty then
+	Status int
+	twamp.Result
+}
+
+// twampProber periodically executes TWAMP-light sessions to reflectors of peer exporters and caches results,
+// because sessions take too long to be executed during scrape.
+type twampProber struct {
+	logger  log.Logger
+	cfg     twamp.Config
+	results map[string]twampResult
+	mutex   sync.RWMutex
+}
+
+// newTWAMPProber reads TWAMP settings from environment.
+// Returns nil if TWAMP is disabled.
+func newTWAMPProber(logger log.Logger) (*twampProber, error) {
+	cfg, err := twamp.ConfigFromEnv()
+	if err != nil || !cfg.Enabled {
+		return nil, err
+	}
+	return &twampProber{logger: logger, cfg: cfg, results: make(map[string]twampResult)}, nil
+}
+
+// run executes TWAMP-light sessions to peer exporters among targets returned by getTargets until context is done.
+// Other targets don't run a reflector.
+func (p *twampProber) run(ctx context.Context, getTargets func() []metrics.PingHost) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		p.sweep(ctx, PeerTargets(getTargets()))
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *twampProber) sweep(ctx context.Context, targets []metrics.PingHost) {
+	results := make(map[string]twampResult, len(targets))
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, tgt := range targets {
+		wg.Add(1)
+		go func(t metrics.PingHost) {
+			defer wg.Done()
+			result := twampResult{Target: t, Status: metrics.StatusOk}
+			res, err := twamp.Measure(ctx, net.JoinHostPort(t.IPAddress, p.cfg.Port), p.cfg)
+			if err != nil {
+				_ = level.Warn(p.logger).Log("msg", fmt.Sprintf("TWAMP-light session failed for %s", t.IPAddress), "err", err)
+				result.Status = metrics.StatusUnreachable
+			} else {
+				_ = level.Debug(p.logger).Log("msg", fmt.Sprintf("TWAMP-light session to %s: %d sent, %d reflected, %d received", t.IPAddress, res.Sent, res.Reflected, res.Received))
+				result.Result = res
+			}
+			mutex.Lock()
+			results[t.IPAddress] = result
+			mutex.Unlock()
+		}(tgt)
+	}
+	wg.Wait()
+
+	p.mutex.Lock()
+	p.results = results
+	p.mutex.Unlock()
+}
+
+// collect sends cached TWAMP-light metrics to the channel.
+func (p *twampProber) collect(source string, ch chan<- prometheus.Metric) {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	for _, res := range p.results {
+		labelValues := []string{source, res.Target.Name, res.Target.IPAddress}
+		ch <- prometheus.MustNewConstMetric(twampStatusDesc, prometheus.GaugeValue, float64(res.Status), labelValues...)
+		if res.Status != metrics.StatusOk {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(twampSentDesc, prometheus.GaugeValue, float64(res.Sent), labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampReceivedDesc, prometheus.GaugeValue, float64(res.Received), labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampForwardLossDesc, prometheus.GaugeValue, res.ForwardLoss, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampReverseLossDesc, prometheus.GaugeValue, res.ReverseLoss, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampClockErrorDesc, prometheus.GaugeValue, float64(res.ClockError)/float64(time.Millisecond), labelValues...)
+		synchronized := 0.0
+		if res.Synchronized {
+			synchronized = 1
+		}
+		ch <- prometheus.MustNewConstMetric(twampClockSyncDesc, prometheus.GaugeValue, synchronized, labelValues...)
+		if res.Received == 0 {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(twampForwardMeanDesc, prometheus.GaugeValue, res.Forward.Mean, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampForwardMinDesc, prometheus.GaugeValue, res.Forward.Min, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampForwardMaxDesc, prometheus.GaugeValue, res.Forward.Max, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampReverseMeanDesc, prometheus.GaugeValue, res.Reverse.Mean, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampReverseMinDesc, prometheus.GaugeValue, res.Reverse.Min, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampReverseMaxDesc, prometheus.GaugeValue, res.Reverse.Max, labelValues...)
+		ch <- prometheus.MustNewConstMetric(twampRttMeanDesc, prometheus.GaugeValue, res.Rtt.Mean, labelValues...)
+	}
+}
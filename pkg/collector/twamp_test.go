@@ -0,0 +1,54 @@
+package collector
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/twamp"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestPeerTargets checks that only targets of the nodes provider are probed with TWAMP-light.
+func TestPeerTargets(t *testing.T) {
+	targets := []metrics.PingHost{
+		{Name: "node-2", IPAddress: "10.0.0.2", Labels: map[string]string{LabelProvider: NodesProvider}},
+		{Name: "web-1", IPAddress: "10.1.0.5", Labels: map[string]string{LabelProvider: EndpointSlicesProvider}},
+		{Name: "example.com", IPAddress: "93.184.216.34"},
+	}
+	assert.Equal(t, targets[:1], PeerTargets(targets))
+}
+
+// TestTWAMPCollect checks that only status is reported for failed sessions.
+func TestTWAMPCollect(t *testing.T) {
+	p := &twampProber{results: map[string]twampResult{
+		"10.0.0.2": {Target: metrics.PingHost{Name: "node-2", IPAddress: "10.0.0.2"}, Status: metrics.StatusOk,
+			Result: twamp.Result{Sent: 10, Received: 10, Reflected: 10}},
+		"10.0.0.3": {Target: metrics.PingHost{Name: "node-3", IPAddress: "10.0.0.3"}, Status: metrics.StatusUnreachable},
+	}}
+	ch := make(chan prometheus.Metric, 64)
+	p.collect("node-1", ch)
+	close(ch)
+
+	status := make(map[string]float64)
+	series := make(map[string]int)
+	for m := range ch {
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		var destination string
+		for _, l := range out.GetLabel() {
+			if l.GetName() == "destination" {
+				destination = l.GetValue()
+			}
+		}
+		series[destination]++
+		if m.Desc() == twampStatusDesc {
+			status[destination] = out.GetGauge().GetValue()
+		}
+	}
+	assert.Equal(t, map[string]float64{"node-2": 0, "node-3": float64(metrics.StatusUnreachable)}, status)
+	assert.Equal(t, 1, series["node-3"])
+	assert.Greater(t, series["node-2"], 1)
+}
@@ -0,0 +1,310 @@
+// Package controller contains controllers of network-latency-exporter custom resources.
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/api/v1alpha1"
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+)
+
+// reconcileAll is a name of the single request, because all probes are applied together
+const reconcileAll = "all"
+
+// ProbeReconciler applies NetworkLatencyProbe resources which select the current node as source
+// to the node collector and reports the result to the status of resources.
+type ProbeReconciler struct {
+	// Client is used to read probes from cache and to update their status
+	Client client.Client
+	// Reader is used to read targets directly from API server, so they are not cached on each node
+	Reader    client.Reader
+	Container *collector.Container
+	NodeName  string
+	// Defaults are settings used when they are not set in probe
+	Defaults model.ProbeGroup
+	// Resync is an interval to pick up changes of pods and services selected by probes
+	Resync time.Duration
+	Logger log.Logger
+
+	applied []model.ProbeGroup
+}
+
+// SetupWithManager registers reconciler in manager.
+func (r *ProbeReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	all := handler.EnqueueRequestsFromMapFunc(func(client.Object) []reconcile.Request {
+		return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: reconcileAll}}}
+	})
+	return ctrl.NewControllerManagedBy(mgr).
+		Named("networklatencyprobe").
+		// Status updates from other nodes don't change generation, so they are skipped
+		Watches(&source.Kind{Type: &v1alpha1.NetworkLatencyProbe{}}, all, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &corev1.Node{}}, all, builder.WithPredicates(predicate.LabelChangedPredicate{})).
+		Complete(r)
+}
+
+// Reconcile resolves targets of all probes selecting the current node and applies them to the node collector.
+func (r *ProbeReconciler) Reconcile(ctx context.Context, _ reconcile.Request) (reconcile.Result, error) {
+	node := &corev1.Node{}
+	if err := r.Reader.Get(ctx, types.NamespacedName{Name: r.NodeName}, node); err != nil {
+		return reconcile.Result{}, errors.Wrapf(err, "Can't get node %s", r.NodeName)
+	}
+	probes := &v1alpha1.NetworkLatencyProbeList{}
+	if err := r.Client.List(ctx, probes); err != nil {
+		return reconcile.Result{}, errors.Wrap(err, "Can't list NetworkLatencyProbe resources")
+	}
+	sort.Slice(probes.Items, func(i, j int) bool { return probes.Items[i].Name < probes.Items[j].Name })
+
+	var groups []model.ProbeGroup
+	for i := range probes.Items {
+		probe := &probes.Items[i]
+		selected, err := matches(probe.Spec.Sources, node.Labels)
+		if err == nil && !selected {
+			r.removeStatus(ctx, probe)
+			continue
+		}
+		group := model.ProbeGroup{}
+		if err == nil {
+			group, err = r.resolve(ctx, probe)
+		}
+		status := v1alpha1.ProbeNodeStatus{
+			Node:               r.NodeName,
+			ObservedGeneration: probe.Generation,
+			Ready:              err == nil,
+			Targets:            len(group.Targets.Targets),
+		}
+		if err != nil {
+			status.Message = err.Error()
+			_ = level.Warn(r.Logger).Log("msg", fmt.Sprintf("Can't apply NetworkLatencyProbe %s", probe.Name), "err", err)
+		} else {
+			groups = append(groups, group)
+		}
+		r.updateStatus(ctx, probe, status)
+	}
+
+	if !reflect.DeepEqual(groups, r.applied) {
+		_ = level.Info(r.Logger).Log("msg", fmt.Sprintf("Apply %d probes from NetworkLatencyProbe resources", len(groups)))
+		r.Container.SetProbeGroups(ctx, groups)
+		r.applied = groups
+	}
+	return reconcile.Result{RequeueAfter: r.Resync}, nil
+}
+
+// resolve converts probe to the probe group with resolved targets.
+func (r *ProbeReconciler) resolve(ctx context.Context, probe *v1alpha1.NetworkLatencyProbe) (model.ProbeGroup, error) {
+	spec := probe.Spec
+	group := r.Defaults
+	group.Name = probe.Name
+	group.CheckTargets = nil
+	group.Targets = metrics.PingHostList{}
+	if spec.PacketsNum > 0 {
+		group.PacketsSent = strconv.Itoa(spec.PacketsNum)
+	}
+	if spec.PacketSize > 0 {
+		group.PacketSize = strconv.Itoa(spec.PacketSize)
+	}
+	if spec.Timeout > 0 {
+		group.ProbeTimeout = strconv.Itoa(spec.Timeout)
+	}
+	if spec.Interval != nil && spec.Interval.Duration > 0 {
+		group.PacketInterval = strconv.FormatFloat(spec.Interval.Seconds(), 'f', -1, 64)
+	}
+
+	protocols := spec.Protocols
+	if len(protocols) == 0 {
+		protocols = []string{"ICMP"}
+	}
+	checkTargets, err := collector.ParseMtrCheckList(protocols)
+	if err != nil {
+		return group, err
+	}
+	group.CheckTargets = checkTargets
+
+	targets, err := r.resolveTargets(ctx, spec.Targets)
+	if err != nil {
+		return group, err
+	}
+	group.Targets = targets
+	return group, nil
+}
+
+func (r *ProbeReconciler) resolveTargets(ctx context.Context, spec v1alpha1.ProbeTargets) (metrics.PingHostList, error) {
+	var targets metrics.PingHostList
+	seen := make(map[string]bool)
+	add := func(t metrics.PingHost) {
+		if !seen[t.IPAddress] {
+			seen[t.IPAddress] = true
+			targets.Targets = append(targets.Targets, t)
+		}
+	}
+
+	if spec.Nodes != nil {
+		selector, err := metav1.LabelSelectorAsSelector(spec.Nodes)
+		if err != nil {
+			return targets, errors.Wrap(err, "Invalid nodes selector")
+		}
+		nodes := &corev1.NodeList{}
+		if err = r.Reader.List(ctx, nodes, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+			return targets, errors.Wrap(err, "Can't list nodes")
+		}
+		for _, n := range nodes.Items {
+			if t, ok := collector.NodeTarget(n); ok {
+				add(t)
+			}
+		}
+	}
+
+	for _, s := range spec.Pods {
+		opts, err := listOptions(s)
+		if err != nil {
+			return targets, errors.Wrap(err, "Invalid pods selector")
+		}
+		pods := &corev1.PodList{}
+		if err = r.Reader.List(ctx, pods, opts...); err != nil {
+			return targets, errors.Wrap(err, "Can't list pods")
+		}
+		for _, p := range pods.Items {
+			if p.Status.Phase == corev1.PodRunning && p.Status.PodIP != "" {
+				add(metrics.PingHost{IPAddress: p.Status.PodIP, Name: p.Name, Kind: metrics.DestinationPod})
+			}
+		}
+	}
+
+	for _, s := range spec.Services {
+		opts, err := listOptions(s)
+		if err != nil {
+			return targets, errors.Wrap(err, "Invalid services selector")
+		}
+		services := &corev1.ServiceList{}
+		if err = r.Reader.List(ctx, services, opts...); err != nil {
+			return targets, errors.Wrap(err, "Can't list services")
+		}
+		for _, svc := range services.Items {
+			// Headless services have no virtual IP
+			if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+				add(metrics.PingHost{IPAddress: svc.Spec.ClusterIP, Name: svc.Name, Kind: metrics.DestinationService})
+			}
+		}
+	}
+
+	for _, h := range spec.Hosts {
+		if net.ParseIP(h.IPAddress) == nil {
+			return targets, errors.Errorf("Invalid IP address of host %s: %s", h.Name, h.IPAddress)
+		}
+		name := h.Name
+		if name == "" {
+			name = h.IPAddress
+		}
+		add(metrics.PingHost{IPAddress: h.IPAddress, Name: name, Kind: metrics.DestinationHost})
+	}
+	return targets, nil
+}
+
+// updateStatus sets status of the current node in probe if it has been changed.
+func (r *ProbeReconciler) updateStatus(ctx context.Context, probe *v1alpha1.NetworkLatencyProbe, status v1alpha1.ProbeNodeStatus) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &v1alpha1.NetworkLatencyProbe{}
+		if err := r.Reader.Get(ctx, client.ObjectKeyFromObject(probe), current); err != nil {
+			return err
+		}
+		idx := -1
+		for i, s := range current.Status.Nodes {
+			if s.Node == status.Node {
+				idx = i
+				status.LastUpdateTime = s.LastUpdateTime
+				if s == status {
+					return nil
+				}
+			}
+		}
+		status.LastUpdateTime = metav1.Now()
+		if idx >= 0 {
+			current.Status.Nodes[idx] = status
+		} else {
+			current.Status.Nodes = append(current.Status.Nodes, status)
+			sort.Slice(current.Status.Nodes, func(i, j int) bool { return current.Status.Nodes[i].Node < current.Status.Nodes[j].Node })
+		}
+		return r.Client.Status().Update(ctx, current)
+	})
+	if err != nil {
+		_ = level.Warn(r.Logger).Log("msg", fmt.Sprintf("Can't update status of NetworkLatencyProbe %s", probe.Name), "err", err)
+	}
+}
+
+// removeStatus removes status of the current node from probe which doesn't select it anymore.
+func (r *ProbeReconciler) removeStatus(ctx context.Context, probe *v1alpha1.NetworkLatencyProbe) {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &v1alpha1.NetworkLatencyProbe{}
+		if err := r.Reader.Get(ctx, client.ObjectKeyFromObject(probe), current); err != nil {
+			return err
+		}
+		nodes := current.Status.Nodes[:0]
+		for _, s := range current.Status.Nodes {
+			if s.Node != r.NodeName {
+				nodes = append(nodes, s)
+			}
+		}
+		if len(nodes) == len(current.Status.Nodes) {
+			return nil
+		}
+		current.Status.Nodes = nodes
+		return r.Client.Status().Update(ctx, current)
+	})
+	if err != nil {
+		_ = level.Warn(r.Logger).Log("msg", fmt.Sprintf("Can't update status of NetworkLatencyProbe %s", probe.Name), "err", err)
+	}
+}
+
+// matches returns true if selector is empty or matches labels.
+func matches(selector *metav1.LabelSelector, l map[string]string) (bool, error) {
+	if selector == nil {
+		return true, nil
+	}
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false, errors.Wrap(err, "Invalid sources selector")
+	}
+	return s.Matches(labels.Set(l)), nil
+}
+
+func listOptions(s v1alpha1.ObjectSelector) ([]client.ListOption, error) {
+	var opts []client.ListOption
+	if s.Namespace != "" {
+		opts = append(opts, client.InNamespace(s.Namespace))
+	}
+	selector := labels.Everything()
+	if s.Selector != nil {
+		var err error
+		if selector, err = metav1.LabelSelectorAsSelector(s.Selector); err != nil {
+			return nil, err
+		}
+		opts = append(opts, client.MatchingLabelsSelector{Selector: selector})
+	}
+	// Targets are read directly from API server by each exporter, so listing all objects of the cluster is not allowed
+	if s.Namespace == "" && selector.Empty() {
+		return nil, errors.New("Namespace or selector must be set")
+	}
+	return opts, nil
+}
@@ -0,0 +1,170 @@
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/api/v1alpha1"
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/model"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func node(name string, ip string, labels map[string]string) *corev1.Node {
+	return &corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels},
+		Status: corev1.NodeStatus{Addresses: []corev1.NodeAddress{
+			{Type: corev1.NodeInternalIP, Address: ip},
+			{Type: corev1.NodeHostName, Address: name},
+		}},
+	}
+}
+
+func newReconciler(t *testing.T, objects ...client.Object) (*ProbeReconciler, *collector.Container) {
+	t.Setenv("NODE_NAME", "node-1")
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+
+	cfgCont := collector.NewConfigContainer([]string{string(collector.NodeType)}, "monitoring", log.NewNopLogger())
+	require.NoError(t, cfgCont.Initialize(context.Background(), "10", "64", "3", nil, metrics.PingHostList{}, "/metrics"))
+	return &ProbeReconciler{
+		Client:    c,
+		Reader:    c,
+		Container: cfgCont,
+		NodeName:  "node-1",
+		Defaults:  model.ProbeGroup{PacketsSent: "10", PacketSize: "64", ProbeTimeout: "3"},
+		Resync:    time.Minute,
+		Logger:    log.NewNopLogger(),
+	}, cfgCont
+}
+
+func TestReconcileAppliesProbes(t *testing.T) {
+	probe := &v1alpha1.NetworkLatencyProbe{
+		ObjectMeta: metav1.ObjectMeta{Name: "workers", Generation: 2},
+		Spec: v1alpha1.NetworkLatencyProbeSpec{
+			Targets: v1alpha1.ProbeTargets{
+				Nodes: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "worker"}},
+				Pods: []v1alpha1.ObjectSelector{{
+					Namespace: "default",
+					Selector:  &metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				}},
+				Services: []v1alpha1.ObjectSelector{{Namespace: "default"}},
+				Hosts:    []v1alpha1.StaticHost{{Name: "gateway", IPAddress: "192.168.0.1"}, {IPAddress: "10.0.0.2"}},
+			},
+			Protocols:  []string{"ICMP", "TCP:22"},
+			PacketsNum: 5,
+			Interval:   &metav1.Duration{Duration: 500 * time.Millisecond},
+		},
+	}
+	other := &v1alpha1.NetworkLatencyProbe{
+		ObjectMeta: metav1.ObjectMeta{Name: "masters"},
+		Spec: v1alpha1.NetworkLatencyProbeSpec{
+			Sources: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "master"}},
+			Targets: v1alpha1.ProbeTargets{Hosts: []v1alpha1.StaticHost{{IPAddress: "10.0.0.9"}}},
+		},
+		Status: v1alpha1.NetworkLatencyProbeStatus{Nodes: []v1alpha1.ProbeNodeStatus{{Node: "node-1", Ready: true}}},
+	}
+	r, cfgCont := newReconciler(t,
+		node("node-1", "10.0.0.1", map[string]string{"role": "worker"}),
+		node("node-2", "10.0.0.2", map[string]string{"role": "worker"}),
+		node("node-3", "10.0.0.3", map[string]string{"role": "master"}),
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-1", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.1.0.1"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-2", Namespace: "default", Labels: map[string]string{"app": "web"}},
+			Status:     corev1.PodStatus{Phase: corev1.PodPending},
+		},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"}, Spec: corev1.ServiceSpec{ClusterIP: "10.96.0.10"}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: "headless", Namespace: "default"}, Spec: corev1.ServiceSpec{ClusterIP: corev1.ClusterIPNone}},
+		probe, other,
+	)
+
+	result, err := r.Reconcile(context.Background(), reconcile.Request{})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, result.RequeueAfter)
+
+	nc := cfgCont.GetConfig(context.Background(), collector.NodeType).(model.NodeCollector)
+	require.Len(t, nc.Groups, 1)
+	group := nc.Groups[0]
+	assert.Equal(t, "workers", group.Name)
+	assert.Equal(t, "5", group.PacketsSent)
+	assert.Equal(t, "64", group.PacketSize)
+	assert.Equal(t, "0.5", group.PacketInterval)
+	require.Len(t, group.CheckTargets, 2)
+	assert.Equal(t, "22", group.CheckTargets[1].Port)
+	// node-2 is probed once, although it is also a static host, only node targets are nodes
+	assert.Equal(t, []metrics.PingHost{
+		{IPAddress: "10.0.0.2", Name: "node-2"},
+		{IPAddress: "10.1.0.1", Name: "web-1", Kind: metrics.DestinationPod},
+		{IPAddress: "10.96.0.10", Name: "web", Kind: metrics.DestinationService},
+		{IPAddress: "192.168.0.1", Name: "gateway", Kind: metrics.DestinationHost},
+	}, group.Targets.Targets)
+
+	updated := &v1alpha1.NetworkLatencyProbe{}
+	require.NoError(t, r.Client.Get(context.Background(), client.ObjectKeyFromObject(probe), updated))
+	require.Len(t, updated.Status.Nodes, 1)
+	assert.Equal(t, "node-1", updated.Status.Nodes[0].Node)
+	assert.True(t, updated.Status.Nodes[0].Ready)
+	assert.Equal(t, 4, updated.Status.Nodes[0].Targets)
+	assert.Equal(t, int64(2), updated.Status.Nodes[0].ObservedGeneration)
+
+	// status of the node is removed from probe which doesn't select it
+	require.NoError(t, r.Client.Get(context.Background(), client.ObjectKeyFromObject(other), updated))
+	assert.Empty(t, updated.Status.Nodes)
+}
+
+func TestReconcileReportsInvalidProbe(t *testing.T) {
+	tests := []struct {
+		protocols []string
+		targets   v1alpha1.ProbeTargets
+		message   string
+	}{
+		{protocols: []string{"HTTP"}, message: "Unsupported protocol HTTP, supported protocols are ICMP, SCTP, TCP, UDP"},
+		{protocols: []string{"DNS"}, message: "Unsupported protocol DNS, supported protocols are ICMP, SCTP, TCP, UDP"},
+		{protocols: []string{"TCP:80;443", "TCP:79-81"}, message: "Check TCP:80 is specified more than once"},
+		{
+			targets: v1alpha1.ProbeTargets{Pods: []v1alpha1.ObjectSelector{{Selector: &metav1.LabelSelector{}}}},
+			message: "Invalid pods selector: Namespace or selector must be set",
+		},
+		{
+			targets: v1alpha1.ProbeTargets{Services: []v1alpha1.ObjectSelector{{}}},
+			message: "Invalid services selector: Namespace or selector must be set",
+		},
+	}
+	for _, tt := range tests {
+		probe := &v1alpha1.NetworkLatencyProbe{
+			ObjectMeta: metav1.ObjectMeta{Name: "invalid"},
+			Spec: v1alpha1.NetworkLatencyProbeSpec{
+				Targets:   tt.targets,
+				Protocols: tt.protocols,
+			},
+		}
+		r, cfgCont := newReconciler(t, node("node-1", "10.0.0.1", nil), probe)
+
+		_, err := r.Reconcile(context.Background(), reconcile.Request{})
+		require.NoError(t, err)
+
+		nc := cfgCont.GetConfig(context.Background(), collector.NodeType).(model.NodeCollector)
+		assert.Empty(t, nc.Groups)
+		updated := &v1alpha1.NetworkLatencyProbe{}
+		require.NoError(t, r.Client.Get(context.Background(), client.ObjectKeyFromObject(probe), updated))
+		require.Len(t, updated.Status.Nodes, 1)
+		assert.False(t, updated.Status.Nodes[0].Ready)
+		assert.Equal(t, tt.message, updated.Status.Nodes[0].Message)
+	}
+}
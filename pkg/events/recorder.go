@@ -0,0 +1,90 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+const (
+	component = "network-latency-exporter"
+
+	ReasonNodeUnreachable = "NetworkLatencyNodeUnreachable"
+	ReasonNodeReachable   = "NetworkLatencyNodeReachable"
+)
+
+// Recorder emits Kubernetes Events against the destination Node when it becomes unreachable
+// from the current node or reachable again. Deduplication and rate limiting are done
+// by the client-go event correlator.
+type Recorder struct {
+	broadcaster record.EventBroadcaster
+	recorder    record.EventRecorder
+	logger      log.Logger
+}
+
+// NewRecorderFromEnv creates recorder configured with EVENTS_BURST and EVENTS_QPS.
+func NewRecorderFromEnv(clientSet kubernetes.Interface, source string, logger log.Logger) (*Recorder, error) {
+	burst, err := strconv.Atoi(utils.GetEnvWithDefaultValue("EVENTS_BURST", "25"))
+	if err != nil {
+		return nil, errors.Wrap(err, "EVENTS_BURST has incorrect value")
+	}
+	qps, err := strconv.ParseFloat(utils.GetEnvWithDefaultValue("EVENTS_QPS", "0.0033"), 32)
+	if err != nil {
+		return nil, errors.Wrap(err, "EVENTS_QPS has incorrect value")
+	}
+	return NewRecorder(clientSet, source, record.CorrelatorOptions{BurstSize: burst, QPS: float32(qps)}, logger), nil
+}
+
+// NewRecorder creates recorder which sends events to the API server on behalf of the source node.
+func NewRecorder(clientSet kubernetes.Interface, source string, options record.CorrelatorOptions, logger log.Logger) *Recorder {
+	broadcaster := record.NewBroadcasterWithCorrelatorOptions(options)
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{Interface: clientSet.CoreV1().Events("")})
+	broadcaster.StartEventWatcher(func(e *corev1.Event) {
+		_ = level.Debug(logger).Log("msg", fmt.Sprintf("Event %s for node %s: %s", e.Reason, e.InvolvedObject.Name, e.Message))
+	})
+	return &Recorder{
+		broadcaster: broadcaster,
+		recorder:    broadcaster.NewRecorder(scheme.Scheme, corev1.EventSource{Component: component, Host: source}),
+		logger:      logger,
+	}
+}
+
+// HandleTransition implements collector.TransitionHandler.
+// Only transitions between reachable (up or degraded) and unreachable (down) states are reported.
+func (r *Recorder) HandleTransition(ctx context.Context, t collector.StateTransition) {
+	wasDown := t.From == metrics.HealthDown
+	isDown := t.To == metrics.HealthDown
+	// Other destinations, e.g. control plane components or pods, don't describe node reachability
+	if wasDown == isDown || t.Metric.Tags.Kind != metrics.DestinationNode {
+		return
+	}
+
+	tags := t.Metric.Tags
+	// Node events use node name as UID, the same way as kubelet and kubectl describe do
+	node := &corev1.ObjectReference{Kind: "Node", Name: tags.Dest, UID: types.UID(tags.Dest)}
+	if isDown {
+		r.recorder.Eventf(node, corev1.EventTypeWarning, ReasonNodeUnreachable,
+			"Node %s (%s) is unreachable from node %s via %s:%s", tags.Dest, tags.DestIp, tags.Source, tags.Protocol, tags.Port)
+	} else {
+		r.recorder.Eventf(node, corev1.EventTypeNormal, ReasonNodeReachable,
+			"Node %s (%s) is reachable again from node %s via %s:%s", tags.Dest, tags.DestIp, tags.Source, tags.Protocol, tags.Port)
+	}
+}
+
+// Shutdown stops sending events.
+func (r *Recorder) Shutdown() {
+	r.broadcaster.Shutdown()
+}
@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/collector"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestHandleTransition(t *testing.T) {
+	fake := record.NewFakeRecorder(10)
+	r := &Recorder{recorder: fake, logger: log.NewNopLogger()}
+	m := metrics.NewNetworkLatencyMetric("node-2", "10.0.0.2", "ICMP", "1", "10")
+	m.Tags.Source = "node-1"
+
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: m, From: metrics.HealthOk, To: metrics.HealthDown})
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: m, From: metrics.HealthOk, To: metrics.HealthDegraded})
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: m, From: metrics.HealthDown, To: metrics.HealthDegraded})
+
+	kubelet := metrics.NewNetworkLatencyMetric("node-2", "10.0.0.2", "TCP", "10250", "10")
+	kubelet.Tags.Kind = metrics.DestinationControlPlane
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: kubelet, From: metrics.HealthOk, To: metrics.HealthDown})
+	pod := metrics.NewNetworkLatencyMetric("exporter-abcde", "192.168.1.2", "ICMP", "1", "10")
+	pod.Tags.Kind = metrics.DestinationPod
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: pod, From: metrics.HealthOk, To: metrics.HealthDown})
+	// endpoint discovered by the endpointslices provider is not a node
+	endpoint := metrics.NewNetworkLatencyMetric("web-1", "10.1.0.1", "ICMP", "1", "10")
+	endpoint.Tags.Kind = metrics.DestinationHost
+	r.HandleTransition(context.Background(), collector.StateTransition{Metric: endpoint, From: metrics.HealthOk, To: metrics.HealthDown})
+
+	assert.Len(t, fake.Events, 2)
+	assert.Equal(t, "Warning NetworkLatencyNodeUnreachable Node node-2 (10.0.0.2) is unreachable from node node-1 via ICMP:1", <-fake.Events)
+	assert.Equal(t, "Normal NetworkLatencyNodeReachable Node node-2 (10.0.0.2) is reachable again from node node-1 via ICMP:1", <-fake.Events)
+}
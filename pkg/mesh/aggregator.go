@@ -0,0 +1,296 @@
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// MatrixPath is a path of HTTP endpoint which serves the latency matrix of all nodes.
+const MatrixPath = "/matrix"
+
+var (
+	checkLabels           = []string{"protocol", "port", "probe", "interface", "network", "dscp"}
+	pairLabels            = append([]string{"source", "destination"}, checkLabels...)
+	destinationLabels     = append([]string{"destination"}, checkLabels...)
+	meshSourcesDesc       = prometheus.NewDesc("network_latency_mesh_sources", "Number of source nodes which results are aggregated", nil, nil)
+	meshPairsDesc         = prometheus.NewDesc("network_latency_mesh_pairs", "Number of probed source and destination pairs", checkLabels, nil)
+	meshUnreachableDesc   = prometheus.NewDesc("network_latency_mesh_unreachable_pairs", "Number of pairs with unreachable destination", checkLabels, nil)
+	meshWorstRttDesc      = prometheus.NewDesc("network_latency_mesh_worst_rtt_mean", "Average RTT of the pair with the highest average RTT among reachable pairs", pairLabels, nil)
+	meshWorstLossDesc     = prometheus.NewDesc("network_latency_mesh_worst_loss", "Percent of lost packets of the pair with the highest loss", pairLabels, nil)
+	meshMedianRttDesc     = prometheus.NewDesc("network_latency_mesh_destination_rtt_mean_median", "Median of average RTT to destination across source nodes which reach it", destinationLabels, nil)
+	meshMedianLossDesc    = prometheus.NewDesc("network_latency_mesh_destination_loss_median", "Median of lost packets percent to destination across source nodes", destinationLabels, nil)
+	meshDestSourcesDesc   = prometheus.NewDesc("network_latency_mesh_destination_sources", "Number of source nodes which probe destination", destinationLabels, nil)
+	meshReportAgeDesc     = prometheus.NewDesc("network_latency_mesh_report_age_seconds", "Age of the latest results of source node", []string{"source"}, nil)
+	meshCollectErrorsDesc = prometheus.NewDesc("network_latency_mesh_collect_errors_total", "Total number of failed attempts to collect results of exporters", nil, nil)
+)
+
+// Matrix is a full source x destination matrix of the latest results.
+type Matrix struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Nodes are names of all source and destination nodes
+	Nodes []string `json:"nodes"`
+	// Rows maps source node to its results
+	Rows map[string]MatrixRow `json:"rows"`
+	// Partitions describes split of the network
+	Partitions Partitions `json:"partitions"`
+}
+
+// MatrixRow contains results of a single source node.
+type MatrixRow struct {
+	Timestamp time.Time `json:"timestamp"`
+	// Cells maps destination to results of all checks of this destination
+	Cells map[string][]PairResult `json:"cells"`
+}
+
+// AggregatorConfig describes how often results are collected from exporters.
+type AggregatorConfig struct {
+	Interval time.Duration
+	// MaxAge is a maximal age of report which is taken into account
+	MaxAge time.Duration
+	// Asymmetry are thresholds of differences between directions of node pairs
+	Asymmetry AsymmetryThresholds
+}
+
+// AggregatorConfigFromEnv reads aggregator configuration from environment.
+func AggregatorConfigFromEnv() (AggregatorConfig, error) {
+	cfg := AggregatorConfig{}
+	var err error
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("AGGREGATOR_INTERVAL", "30s")); err != nil {
+		return cfg, errors.Wrap(err, "AGGREGATOR_INTERVAL has incorrect value")
+	}
+	if cfg.MaxAge, err = time.ParseDuration(utils.GetEnvWithDefaultValue("AGGREGATOR_MAX_AGE", "5m")); err != nil {
+		return cfg, errors.Wrap(err, "AGGREGATOR_MAX_AGE has incorrect value")
+	}
+	if cfg.Asymmetry, err = AsymmetryThresholdsFromEnv(); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Aggregator periodically collects results of all exporters and serves them as the latency matrix
+// and aggregated metrics. It implements prometheus.Collector and http.Handler for MatrixPath.
+type Aggregator struct {
+	cfg           AggregatorConfig
+	peers         *Peers
+	logger        log.Logger
+	reports       []Report
+	collectErrors float64
+	mutex         sync.RWMutex
+}
+
+// NewAggregator creates aggregator of results collected from peers.
+func NewAggregator(cfg AggregatorConfig, peers *Peers, logger log.Logger) *Aggregator {
+	return &Aggregator{cfg: cfg, peers: peers, logger: logger}
+}
+
+// Run collects results every interval until context is done.
+func (a *Aggregator) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		reports, err := a.peers.Collect(ctx)
+		if err != nil {
+			_ = level.Error(a.logger).Log("msg", "Can't collect results of exporters", "err", err)
+			a.mutex.Lock()
+			a.collectErrors++
+			a.mutex.Unlock()
+		} else {
+			a.SetReports(reports)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// SetReports replaces the latest reports. Reports older than max age are dropped.
+func (a *Aggregator) SetReports(reports []Report) {
+	fresh := Fresh(reports, time.Now(), a.cfg.MaxAge)
+	sort.Slice(fresh, func(i, j int) bool { return fresh[i].Source < fresh[j].Source })
+	a.mutex.Lock()
+	a.reports = fresh
+	a.mutex.Unlock()
+}
+
+// Reports returns the latest reports.
+func (a *Aggregator) Reports() []Report {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.reports
+}
+
+// Matrix builds the latency matrix from the latest reports.
+func (a *Aggregator) Matrix() Matrix {
+	reports := a.Reports()
+	matrix := Matrix{Timestamp: time.Now(), Rows: make(map[string]MatrixRow, len(reports))}
+	nodes := make(map[string]bool)
+	for _, r := range reports {
+		nodes[r.Source] = true
+		row := MatrixRow{Timestamp: r.Timestamp, Cells: make(map[string][]PairResult)}
+		for _, res := range r.Results {
+			nodes[res.Destination] = true
+			row.Cells[res.Destination] = append(row.Cells[res.Destination], res)
+		}
+		matrix.Rows[r.Source] = row
+	}
+	for n := range nodes {
+		matrix.Nodes = append(matrix.Nodes, n)
+	}
+	sort.Strings(matrix.Nodes)
+	matrix.Partitions = Partition(reports)
+	return matrix
+}
+
+// ServeHTTP serves the latency matrix as JSON.
+func (a *Aggregator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(a.Matrix())
+}
+
+// Describe implements prometheus.Collector.
+func (a *Aggregator) Describe(ch chan<- *prometheus.Desc) {
+	ch <- meshSourcesDesc
+	ch <- meshPairsDesc
+	ch <- meshUnreachableDesc
+	ch <- meshWorstRttDesc
+	ch <- meshWorstLossDesc
+	ch <- meshMedianRttDesc
+	ch <- meshMedianLossDesc
+	ch <- meshDestSourcesDesc
+	ch <- meshReportAgeDesc
+	ch <- meshCollectErrorsDesc
+	ch <- partitionGroupsDesc
+	ch <- partitionMembersDesc
+	ch <- partitionMemberDesc
+	ch <- majorityUnreachableDesc
+	ch <- unreachableSourcesDesc
+	ch <- asymmetryRttDesc
+	ch <- asymmetryLossDesc
+	ch <- asymmetryHopsDesc
+	ch <- asymmetricDesc
+}
+
+// check identifies results of the same check across sources.
+type check struct {
+	protocol string
+	port     string
+	probe    string
+	iface    string
+	network  string
+	dscp     string
+}
+
+func (c check) labels() []string {
+	return []string{c.protocol, c.port, c.probe, c.iface, c.network, c.dscp}
+}
+
+type sample struct {
+	source string
+	result PairResult
+}
+
+// Collect implements prometheus.Collector.
+func (a *Aggregator) Collect(ch chan<- prometheus.Metric) {
+	reports := a.Reports()
+	a.mutex.RLock()
+	collectErrors := a.collectErrors
+	a.mutex.RUnlock()
+
+	now := time.Now()
+	ch <- prometheus.MustNewConstMetric(meshSourcesDesc, prometheus.GaugeValue, float64(len(reports)))
+	ch <- prometheus.MustNewConstMetric(meshCollectErrorsDesc, prometheus.CounterValue, collectErrors)
+	Partition(reports).collect(ch)
+	for _, p := range Symmetry(reports, a.cfg.Asymmetry) {
+		p.collect(ch)
+	}
+
+	byCheck := make(map[check][]sample)
+	for _, r := range reports {
+		ch <- prometheus.MustNewConstMetric(meshReportAgeDesc, prometheus.GaugeValue, now.Sub(r.Timestamp).Seconds(), r.Source)
+		for _, res := range r.Results {
+			c := check{protocol: res.Protocol, port: res.Port, probe: res.Probe, iface: res.Interface, network: res.Network, dscp: res.DSCP}
+			byCheck[c] = append(byCheck[c], sample{source: r.Source, result: res})
+		}
+	}
+
+	for c, samples := range byCheck {
+		unreachable := 0
+		var worstRtt, worstLoss *sample
+		byDestination := make(map[string][]PairResult)
+		for i := range samples {
+			s := &samples[i]
+			byDestination[s.result.Destination] = append(byDestination[s.result.Destination], s.result)
+			if s.result.Status != metrics.StatusOk {
+				unreachable++
+			} else if worstRtt == nil || s.result.RttMean > worstRtt.result.RttMean {
+				worstRtt = s
+			}
+			if worstLoss == nil || s.result.Loss > worstLoss.result.Loss {
+				worstLoss = s
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(meshPairsDesc, prometheus.GaugeValue, float64(len(samples)), c.labels()...)
+		ch <- prometheus.MustNewConstMetric(meshUnreachableDesc, prometheus.GaugeValue, float64(unreachable), c.labels()...)
+		if worstRtt != nil {
+			ch <- prometheus.MustNewConstMetric(meshWorstRttDesc, prometheus.GaugeValue, worstRtt.result.RttMean,
+				append([]string{worstRtt.source, worstRtt.result.Destination}, c.labels()...)...)
+		}
+		if worstLoss != nil {
+			ch <- prometheus.MustNewConstMetric(meshWorstLossDesc, prometheus.GaugeValue, worstLoss.result.Loss,
+				append([]string{worstLoss.source, worstLoss.result.Destination}, c.labels()...)...)
+		}
+
+		for dest, results := range byDestination {
+			labelValues := append([]string{dest}, c.labels()...)
+			var rtts, losses []float64
+			for _, res := range results {
+				if res.Status == metrics.StatusOk {
+					rtts = append(rtts, res.RttMean)
+				}
+				losses = append(losses, res.Loss)
+			}
+			ch <- prometheus.MustNewConstMetric(meshDestSourcesDesc, prometheus.GaugeValue, float64(len(results)), labelValues...)
+			ch <- prometheus.MustNewConstMetric(meshMedianLossDesc, prometheus.GaugeValue, median(losses), labelValues...)
+			if len(rtts) > 0 {
+				ch <- prometheus.MustNewConstMetric(meshMedianRttDesc, prometheus.GaugeValue, median(rtts), labelValues...)
+			}
+		}
+	}
+}
+
+// Fresh returns reports which are not older than maxAge.
+func Fresh(reports []Report, now time.Time, maxAge time.Duration) []Report {
+	var fresh []Report
+	for _, r := range reports {
+		if !r.Timestamp.IsZero() && now.Sub(r.Timestamp) <= maxAge {
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh
+}
+
+// median returns median of values, values are sorted in place.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	mid := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[mid-1] + values[mid]) / 2
+	}
+	return values[mid]
+}
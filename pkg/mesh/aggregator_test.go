@@ -0,0 +1,64 @@
+package mesh
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregator(t *testing.T) {
+	now := time.Now()
+	a := NewAggregator(AggregatorConfig{Interval: time.Minute, MaxAge: 5 * time.Minute}, nil, log.NewNopLogger())
+	a.SetReports([]Report{
+		{Source: "node-2", Timestamp: now, Results: []PairResult{
+			{Destination: "node-1", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 2, Loss: 0},
+			{Destination: "node-3", Protocol: "ICMP", Status: metrics.StatusUnreachable, Loss: 100},
+		}},
+		{Source: "node-1", Timestamp: now, Results: []PairResult{
+			{Destination: "node-2", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 1},
+			{Destination: "node-3", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 5, Loss: 10},
+		}},
+		// stale report is dropped
+		{Source: "node-4", Timestamp: now.Add(-time.Hour), Results: []PairResult{
+			{Destination: "node-1", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 100},
+		}},
+	})
+
+	matrix := a.Matrix()
+	assert.Equal(t, []string{"node-1", "node-2", "node-3"}, matrix.Nodes)
+	require.Contains(t, matrix.Rows, "node-1")
+	assert.Len(t, matrix.Rows["node-1"].Cells["node-3"], 1)
+	assert.NotContains(t, matrix.Rows, "node-4")
+
+	expected := `
+# HELP network_latency_mesh_worst_rtt_mean Average RTT of the pair with the highest average RTT among reachable pairs
+# TYPE network_latency_mesh_worst_rtt_mean gauge
+network_latency_mesh_worst_rtt_mean{destination="node-3",dscp="",interface="",network="",port="",probe="",protocol="ICMP",source="node-1"} 5
+# HELP network_latency_mesh_worst_loss Percent of lost packets of the pair with the highest loss
+# TYPE network_latency_mesh_worst_loss gauge
+network_latency_mesh_worst_loss{destination="node-3",dscp="",interface="",network="",port="",probe="",protocol="ICMP",source="node-2"} 100
+# HELP network_latency_mesh_destination_loss_median Median of lost packets percent to destination across source nodes
+# TYPE network_latency_mesh_destination_loss_median gauge
+network_latency_mesh_destination_loss_median{destination="node-1",dscp="",interface="",network="",port="",probe="",protocol="ICMP"} 0
+network_latency_mesh_destination_loss_median{destination="node-2",dscp="",interface="",network="",port="",probe="",protocol="ICMP"} 0
+network_latency_mesh_destination_loss_median{destination="node-3",dscp="",interface="",network="",port="",probe="",protocol="ICMP"} 55
+# HELP network_latency_mesh_unreachable_pairs Number of pairs with unreachable destination
+# TYPE network_latency_mesh_unreachable_pairs gauge
+network_latency_mesh_unreachable_pairs{dscp="",interface="",network="",port="",probe="",protocol="ICMP"} 1
+`
+	assert.NoError(t, testutil.CollectAndCompare(a, strings.NewReader(expected),
+		"network_latency_mesh_worst_rtt_mean", "network_latency_mesh_worst_loss",
+		"network_latency_mesh_destination_loss_median", "network_latency_mesh_unreachable_pairs"))
+}
+
+func TestMedian(t *testing.T) {
+	assert.Equal(t, 0.0, median(nil))
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 3, 2}))
+}
@@ -0,0 +1,276 @@
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	ReasonNetworkDegraded  = "NetworkLatencyDegraded"
+	ReasonNetworkHealthy   = "NetworkLatencyHealthy"
+	ReasonNotEnoughSources = "NotEnoughSources"
+)
+
+// ConditionConfig describes how the node condition is calculated and updated.
+type ConditionConfig struct {
+	// Type of the node condition, e.g. NetworkLatencyDegraded
+	Type string
+	// Interval between updates of conditions
+	Interval time.Duration
+	// Quorum is a fraction of sources which must report degraded or down pair to set condition to True
+	Quorum float64
+	// MinSources is a minimal number of sources required to make a decision
+	MinSources int
+	// MaxAge is a maximal age of report which is taken into account
+	MaxAge time.Duration
+	// LeaseName is a name of Lease used for leader election between exporters
+	LeaseName string
+}
+
+// ConditionConfigFromEnv reads node condition settings from environment.
+func ConditionConfigFromEnv() (ConditionConfig, error) {
+	cfg := ConditionConfig{
+		Type:      utils.GetEnvWithDefaultValue("NODE_CONDITION_TYPE", "NetworkLatencyDegraded"),
+		LeaseName: utils.GetEnvWithDefaultValue("NODE_CONDITIONS_LEASE", "network-latency-exporter-conditions"),
+	}
+	var err error
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("NODE_CONDITIONS_INTERVAL", "1m")); err != nil {
+		return cfg, errors.Wrap(err, "NODE_CONDITIONS_INTERVAL has incorrect value")
+	}
+	if cfg.Quorum, err = strconv.ParseFloat(utils.GetEnvWithDefaultValue("NODE_CONDITIONS_QUORUM", "0.5"), 64); err != nil {
+		return cfg, errors.Wrap(err, "NODE_CONDITIONS_QUORUM has incorrect value")
+	}
+	if cfg.Quorum <= 0 || cfg.Quorum > 1 {
+		return cfg, errors.Errorf("NODE_CONDITIONS_QUORUM must be in range (0, 1], got %v", cfg.Quorum)
+	}
+	if cfg.MinSources, err = strconv.Atoi(utils.GetEnvWithDefaultValue("NODE_CONDITIONS_MIN_SOURCES", "2")); err != nil {
+		return cfg, errors.Wrap(err, "NODE_CONDITIONS_MIN_SOURCES has incorrect value")
+	}
+	if cfg.MaxAge, err = time.ParseDuration(utils.GetEnvWithDefaultValue("NODE_CONDITIONS_MAX_AGE", "5m")); err != nil {
+		return cfg, errors.Wrap(err, "NODE_CONDITIONS_MAX_AGE has incorrect value")
+	}
+	return cfg, nil
+}
+
+// Vote is a summary of opinions of all sources about a single destination node.
+type Vote struct {
+	// Sources is a number of sources which have fresh results for destination
+	Sources int
+	// Degraded is a number of sources which see destination as degraded or down
+	Degraded int
+	// Down is a number of sources which see destination as down
+	Down int
+}
+
+// Tally counts votes for each destination. Each source votes with the worst health
+// among all protocols it checks. Reports older than maxAge, self checks and results of destinations
+// other than nodes, e.g. control plane components or pods in secondary networks, are ignored.
+func Tally(reports []Report, now time.Time, maxAge time.Duration) map[string]*Vote {
+	votes := make(map[string]*Vote)
+	for _, r := range Fresh(reports, now, maxAge) {
+		worst := make(map[string]int)
+		for _, res := range r.Results {
+			if !res.nodeTarget(r.Source) {
+				continue
+			}
+			if health, ok := worst[res.Destination]; !ok || res.Health > health {
+				worst[res.Destination] = res.Health
+			}
+		}
+		for dest, health := range worst {
+			v, ok := votes[dest]
+			if !ok {
+				v = &Vote{}
+				votes[dest] = v
+			}
+			v.Sources++
+			if health >= metrics.HealthDegraded {
+				v.Degraded++
+			}
+			if health == metrics.HealthDown {
+				v.Down++
+			}
+		}
+	}
+	return votes
+}
+
+// condition returns status, reason and message of the node condition for the vote.
+func (cfg ConditionConfig) condition(v *Vote) (corev1.ConditionStatus, string, string) {
+	if v.Sources == 0 || v.Sources < cfg.MinSources {
+		return corev1.ConditionUnknown, ReasonNotEnoughSources,
+			fmt.Sprintf("Only %d source nodes have results, at least %d are required", v.Sources, cfg.MinSources)
+	}
+	message := fmt.Sprintf("%d of %d source nodes report degraded or lost connectivity (%d down)", v.Degraded, v.Sources, v.Down)
+	if float64(v.Degraded) >= cfg.Quorum*float64(v.Sources) {
+		return corev1.ConditionTrue, ReasonNetworkDegraded, message
+	}
+	return corev1.ConditionFalse, ReasonNetworkHealthy, message
+}
+
+// ConditionUpdater patches the node condition of destination nodes according to votes of all exporters.
+// Only the exporter which holds the Lease updates conditions, so each node has a single writer.
+type ConditionUpdater struct {
+	cfg       ConditionConfig
+	clientSet kubernetes.Interface
+	peers     *Peers
+	namespace string
+	identity  string
+	logger    log.Logger
+}
+
+// NewConditionUpdater creates updater which participates in leader election with identity.
+func NewConditionUpdater(cfg ConditionConfig, clientSet kubernetes.Interface, peers *Peers, namespace string, identity string, logger log.Logger) *ConditionUpdater {
+	return &ConditionUpdater{
+		cfg:       cfg,
+		clientSet: clientSet,
+		peers:     peers,
+		namespace: namespace,
+		identity:  identity,
+		logger:    logger,
+	}
+}
+
+// Run participates in leader election and updates conditions while being the leader until context is done.
+func (u *ConditionUpdater) Run(ctx context.Context) {
+	lock := &resourcelock.LeaseLock{
+		LeaseMeta:  metav1.ObjectMeta{Name: u.cfg.LeaseName, Namespace: u.namespace},
+		Client:     u.clientSet.CoordinationV1(),
+		LockConfig: resourcelock.ResourceLockConfig{Identity: u.identity},
+	}
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            lock,
+		LeaseDuration:   15 * time.Second,
+		RenewDeadline:   10 * time.Second,
+		RetryPeriod:     2 * time.Second,
+		ReleaseOnCancel: true,
+		Name:            u.cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(ctx context.Context) {
+				_ = level.Info(u.logger).Log("msg", fmt.Sprintf("Started updating %s node conditions as leader", u.cfg.Type))
+				u.loop(ctx)
+			},
+			OnStoppedLeading: func() {
+				_ = level.Info(u.logger).Log("msg", fmt.Sprintf("Stopped updating %s node conditions", u.cfg.Type))
+			},
+		},
+	})
+	if err != nil {
+		_ = level.Error(u.logger).Log("msg", "Can't create leader elector for node conditions", "err", err)
+		return
+	}
+	// Elector returns when leadership is lost, so try to acquire it again
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+}
+
+func (u *ConditionUpdater) loop(ctx context.Context) {
+	ticker := time.NewTicker(u.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		reports, err := u.peers.Collect(ctx)
+		if err != nil {
+			_ = level.Error(u.logger).Log("msg", "Can't collect results of exporters", "err", err)
+			continue
+		}
+		u.Update(ctx, Tally(reports, time.Now(), u.cfg.MaxAge))
+	}
+}
+
+// Update sets condition on each voted node. Conditions which are not changed are not patched.
+// Conditions of nodes without votes, e.g. nodes which are not probed anymore, are reset to Unknown,
+// so they don't keep stale status.
+func (u *ConditionUpdater) Update(ctx context.Context, votes map[string]*Vote) {
+	for nodeName, v := range votes {
+		if err := u.updateNode(ctx, nodeName, v); err != nil {
+			_ = level.Warn(u.logger).Log("msg", fmt.Sprintf("Can't update condition %s of node %s", u.cfg.Type, nodeName), "err", err)
+		}
+	}
+
+	nodes, err := u.clientSet.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		_ = level.Warn(u.logger).Log("msg", fmt.Sprintf("Can't list nodes to reset condition %s", u.cfg.Type), "err", err)
+		return
+	}
+	for _, node := range nodes.Items {
+		if _, ok := votes[node.Name]; ok || !hasCondition(node, u.cfg.Type) {
+			continue
+		}
+		if err := u.updateNode(ctx, node.Name, &Vote{}); err != nil {
+			_ = level.Warn(u.logger).Log("msg", fmt.Sprintf("Can't reset condition %s of node %s", u.cfg.Type, node.Name), "err", err)
+		}
+	}
+}
+
+// hasCondition returns true if the node has condition of the type.
+func hasCondition(node corev1.Node, conditionType string) bool {
+	for _, c := range node.Status.Conditions {
+		if string(c.Type) == conditionType {
+			return true
+		}
+	}
+	return false
+}
+
+func (u *ConditionUpdater) updateNode(ctx context.Context, nodeName string, v *Vote) error {
+	node, err := u.clientSet.CoreV1().Nodes().Get(ctx, nodeName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	status, reason, message := u.cfg.condition(v)
+	now := metav1.Now()
+	condition := corev1.NodeCondition{
+		Type:               corev1.NodeConditionType(u.cfg.Type),
+		Status:             status,
+		Reason:             reason,
+		Message:            message,
+		LastHeartbeatTime:  now,
+		LastTransitionTime: now,
+	}
+	for _, c := range node.Status.Conditions {
+		if c.Type != condition.Type {
+			continue
+		}
+		if c.Status == status && c.Reason == reason && c.Message == message {
+			return nil
+		}
+		if c.Status == status {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+
+	// Conditions are merged by type, so other conditions of the node are kept
+	patch, err := json.Marshal(map[string]interface{}{
+		"status": map[string]interface{}{
+			"conditions": []corev1.NodeCondition{condition},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = u.clientSet.CoreV1().Nodes().PatchStatus(ctx, nodeName, patch); err != nil {
+		return err
+	}
+	_ = level.Debug(u.logger).Log("msg", fmt.Sprintf("Condition %s of node %s is set to %s: %s", u.cfg.Type, nodeName, status, message))
+	return nil
+}
@@ -0,0 +1,143 @@
+package mesh
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestTally(t *testing.T) {
+	now := time.Now()
+	reports := []Report{
+		{Source: "node-1", Timestamp: now, Results: []PairResult{
+			{Destination: "node-2", Protocol: "ICMP", Health: metrics.HealthOk},
+			{Destination: "node-2", Protocol: "TCP", Health: metrics.HealthDown},
+			{Destination: "node-3", Protocol: "ICMP", Health: metrics.HealthOk},
+			// restarting kubelet doesn't make the node unreachable
+			{Destination: "node-3", Protocol: "TCP", Port: "10250", Probe: "kubelet", Kind: metrics.DestinationControlPlane, Health: metrics.HealthDown},
+		}},
+		{Source: "node-3", Timestamp: now, Results: []PairResult{
+			// endpoints discovered by the endpointslices provider are not nodes
+			{Destination: "web-1", DestinationIP: "10.1.0.1", Protocol: "ICMP", Kind: metrics.DestinationHost, Health: metrics.HealthDown},
+			// pods in secondary networks are not nodes
+			{Destination: "exporter-abcde", Network: "monitoring/sriov", Kind: metrics.DestinationPod, Health: metrics.HealthDown},
+			{Destination: "node-2", Protocol: "ICMP", Health: metrics.HealthDegraded},
+			{Destination: "node-3", Protocol: "ICMP", Health: metrics.HealthDown},
+		}},
+		// stale report is ignored
+		{Source: "node-4", Timestamp: now.Add(-time.Hour), Results: []PairResult{
+			{Destination: "node-2", Protocol: "ICMP", Health: metrics.HealthOk},
+		}},
+	}
+
+	votes := Tally(reports, now, 5*time.Minute)
+
+	assert.Equal(t, &Vote{Sources: 2, Degraded: 2, Down: 1}, votes["node-2"])
+	assert.Equal(t, &Vote{Sources: 1}, votes["node-3"])
+	assert.NotContains(t, votes, "node-1")
+	assert.NotContains(t, votes, "exporter-abcde")
+	assert.NotContains(t, votes, "web-1")
+}
+
+func TestCondition(t *testing.T) {
+	cfg := ConditionConfig{Quorum: 0.5, MinSources: 2}
+
+	status, reason, _ := cfg.condition(&Vote{Sources: 1, Degraded: 1})
+	assert.Equal(t, corev1.ConditionUnknown, status)
+	assert.Equal(t, ReasonNotEnoughSources, reason)
+
+	status, _, _ = ConditionConfig{Quorum: 0.5}.condition(&Vote{})
+	assert.Equal(t, corev1.ConditionUnknown, status)
+
+	status, reason, message := cfg.condition(&Vote{Sources: 4, Degraded: 2, Down: 1})
+	assert.Equal(t, corev1.ConditionTrue, status)
+	assert.Equal(t, ReasonNetworkDegraded, reason)
+	assert.Equal(t, "2 of 4 source nodes report degraded or lost connectivity (1 down)", message)
+
+	status, reason, _ = cfg.condition(&Vote{Sources: 4, Degraded: 1})
+	assert.Equal(t, corev1.ConditionFalse, status)
+	assert.Equal(t, ReasonNetworkHealthy, reason)
+}
+
+func TestUpdatePatchesNodeCondition(t *testing.T) {
+	transition := metav1.NewTime(time.Now().Add(-time.Hour).Truncate(time.Second))
+	clientSet := fake.NewSimpleClientset(&corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+		Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+			{Type: corev1.NodeReady, Status: corev1.ConditionTrue},
+			{Type: "NetworkLatencyDegraded", Status: corev1.ConditionFalse, Reason: ReasonNetworkHealthy, LastTransitionTime: transition},
+		}},
+	})
+	cfg := ConditionConfig{Type: "NetworkLatencyDegraded", Quorum: 0.5, MinSources: 2}
+	u := NewConditionUpdater(cfg, clientSet, nil, "monitoring", "node-1", log.NewNopLogger())
+
+	u.Update(context.Background(), map[string]*Vote{"node-2": {Sources: 3, Degraded: 3}})
+
+	node, err := clientSet.CoreV1().Nodes().Get(context.Background(), "node-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, node.Status.Conditions, 2)
+	for _, c := range node.Status.Conditions {
+		switch c.Type {
+		case corev1.NodeReady:
+			assert.Equal(t, corev1.ConditionTrue, c.Status)
+		case "NetworkLatencyDegraded":
+			assert.Equal(t, corev1.ConditionTrue, c.Status)
+			assert.Equal(t, ReasonNetworkDegraded, c.Reason)
+			assert.True(t, c.LastTransitionTime.After(transition.Time))
+		}
+	}
+	assert.Equal(t, 1, countPatches(clientSet))
+
+	// unchanged condition is not patched again
+	u.Update(context.Background(), map[string]*Vote{"node-2": {Sources: 3, Degraded: 3}})
+	assert.Equal(t, 1, countPatches(clientSet))
+}
+
+func TestUpdateResetsConditionWithoutVotes(t *testing.T) {
+	clientSet := fake.NewSimpleClientset(
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-2"},
+			Status: corev1.NodeStatus{Conditions: []corev1.NodeCondition{
+				{Type: "NetworkLatencyDegraded", Status: corev1.ConditionTrue, Reason: ReasonNetworkDegraded},
+			}},
+		},
+		&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-3"}},
+	)
+	cfg := ConditionConfig{Type: "NetworkLatencyDegraded", Quorum: 0.5, MinSources: 2}
+	u := NewConditionUpdater(cfg, clientSet, nil, "monitoring", "node-1", log.NewNopLogger())
+
+	u.Update(context.Background(), map[string]*Vote{})
+
+	node, err := clientSet.CoreV1().Nodes().Get(context.Background(), "node-2", metav1.GetOptions{})
+	require.NoError(t, err)
+	require.Len(t, node.Status.Conditions, 1)
+	assert.Equal(t, corev1.ConditionUnknown, node.Status.Conditions[0].Status)
+	assert.Equal(t, ReasonNotEnoughSources, node.Status.Conditions[0].Reason)
+	// node without the condition isn't patched
+	node, err = clientSet.CoreV1().Nodes().Get(context.Background(), "node-3", metav1.GetOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, node.Status.Conditions)
+	assert.Equal(t, 1, countPatches(clientSet))
+
+	// reset condition is not patched again
+	u.Update(context.Background(), map[string]*Vote{})
+	assert.Equal(t, 1, countPatches(clientSet))
+}
+
+func countPatches(clientSet *fake.Clientset) int {
+	patches := 0
+	for _, a := range clientSet.Actions() {
+		if a.GetVerb() == "patch" {
+			patches++
+		}
+	}
+	return patches
+}
@@ -0,0 +1,127 @@
+package mesh
+
+import (
+	"sort"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	partitionGroupsDesc     = prometheus.NewDesc("network_partition_groups", "Number of connected groups of nodes in reachability graph, 1 if the network is not split", nil, nil)
+	partitionMembersDesc    = prometheus.NewDesc("network_partition_members", "Number of nodes in partition", []string{"partition"}, nil)
+	partitionMemberDesc     = prometheus.NewDesc("network_partition_member", "Partition of node, always 1", []string{"partition", "node"}, nil)
+	majorityUnreachableDesc = prometheus.NewDesc("network_partition_majority_unreachable", "1 if node is unreachable from the majority of source nodes which probe it, 0 otherwise", []string{"node"}, nil)
+	unreachableSourcesDesc  = prometheus.NewDesc("network_partition_unreachable_sources", "Number of source nodes which can't reach node", []string{"node"}, nil)
+)
+
+// Partitions describes split of the reachability graph.
+type Partitions struct {
+	// Groups are connected components of the reachability graph, the biggest first.
+	// Two nodes are connected if any of them reaches the other one with any check.
+	Groups [][]string `json:"groups"`
+	// MajorityUnreachable are nodes which are unreachable from the majority of source nodes which probe them
+	MajorityUnreachable []string `json:"majorityUnreachable"`
+	// UnreachableSources maps node to number of source nodes which can't reach it
+	UnreachableSources map[string]int `json:"-"`
+}
+
+// Partition calculates connected components of the reachability graph built from reports.
+// Only results of node destinations are used.
+func Partition(reports []Report) Partitions {
+	parent := make(map[string]string)
+	var find func(string) string
+	find = func(n string) string {
+		if _, ok := parent[n]; !ok {
+			parent[n] = n
+		}
+		if parent[n] != n {
+			parent[n] = find(parent[n])
+		}
+		return parent[n]
+	}
+	union := func(a, b string) {
+		ra, rb := find(a), find(b)
+		if ra == rb {
+			return
+		}
+		// keep the smallest name as root, so the result does not depend on order of reports
+		if rb < ra {
+			ra, rb = rb, ra
+		}
+		parent[rb] = ra
+	}
+
+	probed := make(map[string]int)
+	unreachable := make(map[string]int)
+	for _, r := range reports {
+		find(r.Source)
+		reached := make(map[string]bool)
+		for _, res := range r.Results {
+			if !res.nodeTarget(r.Source) {
+				continue
+			}
+			find(res.Destination)
+			if _, ok := reached[res.Destination]; !ok {
+				reached[res.Destination] = false
+			}
+			if res.Status == metrics.StatusOk {
+				reached[res.Destination] = true
+			}
+		}
+		for dest, ok := range reached {
+			probed[dest]++
+			if ok {
+				union(r.Source, dest)
+			} else {
+				unreachable[dest]++
+			}
+		}
+	}
+
+	groups := make(map[string][]string)
+	for n := range parent {
+		root := find(n)
+		groups[root] = append(groups[root], n)
+	}
+	p := Partitions{UnreachableSources: unreachable}
+	for _, g := range groups {
+		sort.Strings(g)
+		p.Groups = append(p.Groups, g)
+	}
+	sort.Slice(p.Groups, func(i, j int) bool {
+		if len(p.Groups[i]) != len(p.Groups[j]) {
+			return len(p.Groups[i]) > len(p.Groups[j])
+		}
+		return p.Groups[i][0] < p.Groups[j][0]
+	})
+	for n, count := range probed {
+		if unreachable[n]*2 > count {
+			p.MajorityUnreachable = append(p.MajorityUnreachable, n)
+		}
+	}
+	sort.Strings(p.MajorityUnreachable)
+	return p
+}
+
+func (p Partitions) collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(partitionGroupsDesc, prometheus.GaugeValue, float64(len(p.Groups)))
+	majority := make(map[string]bool, len(p.MajorityUnreachable))
+	for _, n := range p.MajorityUnreachable {
+		majority[n] = true
+	}
+	for _, g := range p.Groups {
+		// the smallest member identifies the partition, so the label doesn't change when other partitions grow
+		partition := g[0]
+		ch <- prometheus.MustNewConstMetric(partitionMembersDesc, prometheus.GaugeValue, float64(len(g)), partition)
+		for _, n := range g {
+			ch <- prometheus.MustNewConstMetric(partitionMemberDesc, prometheus.GaugeValue, 1, partition, n)
+			value := 0.0
+			if majority[n] {
+				value = 1
+			}
+			ch <- prometheus.MustNewConstMetric(majorityUnreachableDesc, prometheus.GaugeValue, value, n)
+			ch <- prometheus.MustNewConstMetric(unreachableSourcesDesc, prometheus.GaugeValue, float64(p.UnreachableSources[n]), n)
+		}
+	}
+}
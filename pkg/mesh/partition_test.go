@@ -0,0 +1,82 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reachability(source string, destinations map[string]int) Report {
+	r := Report{Source: source}
+	for dest, status := range destinations {
+		r.Results = append(r.Results, PairResult{Destination: dest, Protocol: "ICMP", Status: status})
+	}
+	return r
+}
+
+func TestPartitionSplit(t *testing.T) {
+	ok, down := metrics.StatusOk, metrics.StatusUnreachable
+	p := Partition([]Report{
+		reachability("a", map[string]int{"b": ok, "c": ok, "d": down, "e": down}),
+		reachability("b", map[string]int{"a": ok, "c": ok, "d": down, "e": down}),
+		reachability("c", map[string]int{"a": ok, "b": ok, "d": down, "e": down}),
+		reachability("d", map[string]int{"a": down, "b": down, "c": down, "e": ok}),
+		reachability("e", map[string]int{"a": down, "b": down, "c": down, "d": ok}),
+	})
+
+	assert.Equal(t, [][]string{{"a", "b", "c"}, {"d", "e"}}, p.Groups)
+	assert.Equal(t, []string{"d", "e"}, p.MajorityUnreachable)
+	assert.Equal(t, 2, p.UnreachableSources["a"])
+}
+
+// TestPartitionLabel checks that partitions are labeled with the smallest member.
+func TestPartitionLabel(t *testing.T) {
+	p := Partitions{Groups: [][]string{{"c", "d", "e"}, {"a", "b"}}}
+	ch := make(chan prometheus.Metric, 32)
+	p.collect(ch)
+	close(ch)
+
+	members := make(map[string]float64)
+	for m := range ch {
+		if m.Desc() != partitionMembersDesc {
+			continue
+		}
+		var out dto.Metric
+		require.NoError(t, m.Write(&out))
+		members[out.GetLabel()[0].GetValue()] = out.GetGauge().GetValue()
+	}
+	assert.Equal(t, map[string]float64{"c": 3, "a": 2}, members)
+}
+
+func TestPartitionSingleNode(t *testing.T) {
+	ok, down := metrics.StatusOk, metrics.StatusUnreachable
+	p := Partition([]Report{
+		reachability("a", map[string]int{"b": ok, "c": down}),
+		reachability("b", map[string]int{"a": ok, "c": down}),
+		// one-way reachability still connects nodes
+		reachability("c", map[string]int{"a": ok, "b": down}),
+		reachability("d", map[string]int{"a": ok, "b": ok, "c": down}),
+	})
+
+	assert.Equal(t, [][]string{{"a", "b", "c", "d"}}, p.Groups)
+	assert.Equal(t, []string{"c"}, p.MajorityUnreachable)
+}
+
+func TestPartitionIgnoresOtherDestinations(t *testing.T) {
+	ok, down := metrics.StatusOk, metrics.StatusUnreachable
+	a := reachability("a", map[string]int{"b": ok})
+	a.Results = append(a.Results, PairResult{Destination: "b", Protocol: "TCP", Probe: "etcd", Kind: metrics.DestinationControlPlane, Status: down})
+	b := reachability("b", map[string]int{"a": ok})
+	b.Results = append(b.Results, PairResult{Destination: "apiserver-1", Protocol: "TCP", Probe: "apiserver", Kind: metrics.DestinationControlPlane, Status: ok},
+		PairResult{Destination: "exporter-abcde", Protocol: "ICMP", Network: "monitoring/sriov", Kind: metrics.DestinationPod, Status: down},
+		PairResult{Destination: "web-1", Protocol: "ICMP", Kind: metrics.DestinationHost, Status: ok})
+	p := Partition([]Report{a, b})
+
+	assert.Equal(t, [][]string{{"a", "b"}}, p.Groups)
+	assert.Empty(t, p.MajorityUnreachable)
+	assert.Zero(t, p.UnreachableSources["b"])
+}
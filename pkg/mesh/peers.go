@@ -0,0 +1,128 @@
+package mesh
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// PeersConfig describes how exporter pods are discovered and how their results are fetched.
+type PeersConfig struct {
+	Namespace string
+	// Selector is a label selector of exporter pods
+	Selector string
+	Port     int
+	// Scheme is http or https, depending on web configuration of exporters
+	Scheme             string
+	InsecureSkipVerify bool
+	Timeout            time.Duration
+}
+
+// PeersConfigFromEnv reads peers configuration from environment.
+func PeersConfigFromEnv(namespace string) (PeersConfig, error) {
+	cfg := PeersConfig{
+		Namespace:          namespace,
+		Selector:           utils.GetEnvWithDefaultValue("PEERS_SELECTOR", "app.kubernetes.io/name=network-latency-exporter"),
+		Scheme:             utils.GetEnvWithDefaultValue("PEERS_SCHEME", "http"),
+		InsecureSkipVerify: utils.GetEnvWithDefaultValue("PEERS_INSECURE_SKIP_VERIFY", "false") == "true",
+	}
+	if cfg.Scheme != "http" && cfg.Scheme != "https" {
+		return cfg, errors.Errorf("Unsupported PEERS_SCHEME: %s", cfg.Scheme)
+	}
+	var err error
+	if cfg.Port, err = strconv.Atoi(utils.GetEnvWithDefaultValue("PEERS_PORT", "9273")); err != nil {
+		return cfg, errors.Wrap(err, "PEERS_PORT has incorrect value")
+	}
+	if cfg.Timeout, err = time.ParseDuration(utils.GetEnvWithDefaultValue("PEERS_TIMEOUT", "5s")); err != nil {
+		return cfg, errors.Wrap(err, "PEERS_TIMEOUT has incorrect value")
+	}
+	return cfg, nil
+}
+
+// Peers collects the latest results from all running exporter pods.
+type Peers struct {
+	cfg       PeersConfig
+	clientSet kubernetes.Interface
+	client    *http.Client
+	logger    log.Logger
+}
+
+// NewPeers creates peers collector.
+func NewPeers(cfg PeersConfig, clientSet kubernetes.Interface, logger log.Logger) *Peers {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} // #nosec G402 -- enabled explicitly by user
+	return &Peers{
+		cfg:       cfg,
+		clientSet: clientSet,
+		client:    &http.Client{Timeout: cfg.Timeout, Transport: transport},
+		logger:    logger,
+	}
+}
+
+// Collect fetches reports of all running exporter pods. Pods which can't be reached are skipped.
+func (p *Peers) Collect(ctx context.Context) ([]Report, error) {
+	pods, err := p.clientSet.CoreV1().Pods(p.cfg.Namespace).List(ctx, metav1.ListOptions{LabelSelector: p.cfg.Selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "Can't list exporter pods")
+	}
+
+	var reports []Report
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+			continue
+		}
+		wg.Add(1)
+		go func(pod *corev1.Pod) {
+			defer wg.Done()
+			report, err := p.fetch(ctx, pod.Status.PodIP)
+			if err != nil {
+				_ = level.Warn(p.logger).Log("msg", fmt.Sprintf("Can't get results of pod %s on node %s", pod.Name, pod.Spec.NodeName), "err", err)
+				return
+			}
+			if report.Source == "" {
+				report.Source = pod.Spec.NodeName
+			}
+			mutex.Lock()
+			reports = append(reports, report)
+			mutex.Unlock()
+		}(pod)
+	}
+	wg.Wait()
+	return reports, nil
+}
+
+func (p *Peers) fetch(ctx context.Context, ip string) (Report, error) {
+	var report Report
+	address := fmt.Sprintf("%s://%s%s", p.cfg.Scheme, net.JoinHostPort(ip, strconv.Itoa(p.cfg.Port)), ResultsPath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return report, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return report, errors.Errorf("%s returned %s", address, resp.Status)
+	}
+	err = json.NewDecoder(resp.Body).Decode(&report)
+	return report, err
+}
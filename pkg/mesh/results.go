@@ -0,0 +1,111 @@
+package mesh
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+)
+
+// ResultsPath is a path of HTTP endpoint which serves the latest results of exporter as Report.
+const ResultsPath = "/results"
+
+// PairResult is a result of probe from the source node to a single destination with a single protocol.
+type PairResult struct {
+	Destination   string  `json:"destination"`
+	DestinationIP string  `json:"destinationIp"`
+	Protocol      string  `json:"protocol"`
+	Port          string  `json:"port"`
+	Probe         string  `json:"probe,omitempty"`
+	Interface     string  `json:"interface,omitempty"`
+	Network       string  `json:"network,omitempty"`
+	DSCP          string  `json:"dscp,omitempty"`
+	Kind          string  `json:"kind,omitempty"`
+	Status        int     `json:"status"`
+	Health        int     `json:"health"`
+	Sent          int     `json:"sent"`
+	Received      int     `json:"received"`
+	Loss          float64 `json:"loss"`
+	RttMean       float64 `json:"rttMean"`
+	RttMin        float64 `json:"rttMin"`
+	RttMax        float64 `json:"rttMax"`
+	RttDeviation  float64 `json:"rttDeviation"`
+	HopsNum       int     `json:"hopsNum"`
+}
+
+// nodeTarget returns true if the destination is a node, which reachability is evaluated from the result.
+// Results of the source node itself are ignored.
+func (r PairResult) nodeTarget(source string) bool {
+	return r.Kind == metrics.DestinationNode && r.Destination != "" && r.Destination != source
+}
+
+// Report is a row of the mesh: the latest results of all probes executed by a single source node.
+type Report struct {
+	Source    string       `json:"source"`
+	Timestamp time.Time    `json:"timestamp"`
+	Results   []PairResult `json:"results"`
+}
+
+// NewPairResult converts probe result to PairResult.
+func NewPairResult(m *metrics.NetworkLatencyMetric) PairResult {
+	return PairResult{
+		Destination:   m.Tags.Dest,
+		DestinationIP: m.Tags.DestIp,
+		Protocol:      m.Tags.Protocol,
+		Port:          m.Tags.Port,
+		Probe:         m.Tags.Probe,
+		Interface:     m.Tags.Interface,
+		Network:       m.Tags.Network,
+		DSCP:          m.Tags.DSCP,
+		Kind:          m.Tags.Kind,
+		Status:        m.Fields.Status,
+		Health:        m.Fields.Health,
+		Sent:          m.Fields.TotalSent,
+		Received:      m.Fields.TotalReceived,
+		Loss:          m.Fields.Loss,
+		RttMean:       m.Fields.RttMean,
+		RttMin:        m.Fields.RttMin,
+		RttMax:        m.Fields.RttMax,
+		RttDeviation:  m.Fields.RttDeviation,
+		HopsNum:       m.Fields.HopsNum,
+	}
+}
+
+// Store keeps the latest results of the current exporter.
+// It implements collector.ResultHandler and http.Handler for ResultsPath.
+type Store struct {
+	report Report
+	mutex  sync.RWMutex
+}
+
+// NewStore creates empty store for results of the source node.
+func NewStore(source string) *Store {
+	return &Store{report: Report{Source: source}}
+}
+
+// HandleResults implements collector.ResultHandler.
+func (s *Store) HandleResults(ctx context.Context, source string, results []*metrics.NetworkLatencyMetric) {
+	report := Report{Source: source, Timestamp: time.Now(), Results: make([]PairResult, 0, len(results))}
+	for _, m := range results {
+		report.Results = append(report.Results, NewPairResult(m))
+	}
+	s.mutex.Lock()
+	s.report = report
+	s.mutex.Unlock()
+}
+
+// Report returns the latest results.
+func (s *Store) Report() Report {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.report
+}
+
+// ServeHTTP serves the latest results as JSON.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.Report())
+}
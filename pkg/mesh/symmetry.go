@@ -0,0 +1,133 @@
+package mesh
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	asymmetryRttDesc  = prometheus.NewDesc("network_latency_asymmetry_rtt_mean_delta", "Difference of average RTT from source to destination and from destination to source", pairLabels, nil)
+	asymmetryLossDesc = prometheus.NewDesc("network_latency_asymmetry_loss_delta", "Difference of lost packets percent from source to destination and from destination to source", pairLabels, nil)
+	asymmetryHopsDesc = prometheus.NewDesc("network_latency_asymmetry_hops_delta", "Difference of number of hops from source to destination and from destination to source", pairLabels, nil)
+	asymmetricDesc    = prometheus.NewDesc("network_latency_asymmetric", "1 if any difference between directions exceeds threshold or destination is reachable only in one direction, 0 otherwise", pairLabels, nil)
+)
+
+// AsymmetryThresholds are maximal allowed absolute differences between two directions of a node pair.
+type AsymmetryThresholds struct {
+	// RttMean is in milliseconds
+	RttMean float64
+	// Loss is in percents
+	Loss float64
+	Hops int
+}
+
+// AsymmetryThresholdsFromEnv reads asymmetry thresholds from environment.
+func AsymmetryThresholdsFromEnv() (AsymmetryThresholds, error) {
+	t := AsymmetryThresholds{}
+	var err error
+	if t.RttMean, err = strconv.ParseFloat(utils.GetEnvWithDefaultValue("ASYMMETRY_RTT_THRESHOLD", "5"), 64); err != nil {
+		return t, errors.Wrap(err, "ASYMMETRY_RTT_THRESHOLD has incorrect value")
+	}
+	if t.Loss, err = strconv.ParseFloat(utils.GetEnvWithDefaultValue("ASYMMETRY_LOSS_THRESHOLD", "10"), 64); err != nil {
+		return t, errors.Wrap(err, "ASYMMETRY_LOSS_THRESHOLD has incorrect value")
+	}
+	if t.Hops, err = strconv.Atoi(utils.GetEnvWithDefaultValue("ASYMMETRY_HOPS_THRESHOLD", "2")); err != nil {
+		return t, errors.Wrap(err, "ASYMMETRY_HOPS_THRESHOLD has incorrect value")
+	}
+	return t, nil
+}
+
+// PairSymmetry compares results of the same check in both directions of a node pair.
+// Source is always lexicographically less than Destination, deltas are source->destination minus destination->source.
+type PairSymmetry struct {
+	Source      string
+	Destination string
+	check
+	// Reachable is true if destination is reachable in both directions, RTT and hops deltas are calculated only in this case
+	Reachable  bool
+	RttDelta   float64
+	LossDelta  float64
+	HopsDelta  int
+	Asymmetric bool
+}
+
+// Symmetry finds results of the same check in both directions of node pairs and compares them.
+func Symmetry(reports []Report, thresholds AsymmetryThresholds) []PairSymmetry {
+	type direction struct {
+		source      string
+		destination string
+		check
+	}
+	results := make(map[direction]PairResult)
+	for _, r := range reports {
+		for _, res := range r.Results {
+			if res.Destination == "" || res.Destination == r.Source {
+				continue
+			}
+			results[direction{r.Source, res.Destination, check{res.Protocol, res.Port, res.Probe, res.Interface, res.Network, res.DSCP}}] = res
+		}
+	}
+
+	var pairs []PairSymmetry
+	for d, forward := range results {
+		if d.source > d.destination {
+			continue
+		}
+		backward, ok := results[direction{d.destination, d.source, d.check}]
+		if !ok {
+			continue
+		}
+		p := PairSymmetry{
+			Source:      d.source,
+			Destination: d.destination,
+			check:       d.check,
+			Reachable:   forward.Status == metrics.StatusOk && backward.Status == metrics.StatusOk,
+			LossDelta:   forward.Loss - backward.Loss,
+		}
+		p.Asymmetric = forward.Status != backward.Status || math.Abs(p.LossDelta) > thresholds.Loss
+		if p.Reachable {
+			p.RttDelta = forward.RttMean - backward.RttMean
+			p.HopsDelta = forward.HopsNum - backward.HopsNum
+			p.Asymmetric = p.Asymmetric || math.Abs(p.RttDelta) > thresholds.RttMean ||
+				math.Abs(float64(p.HopsDelta)) > float64(thresholds.Hops)
+		}
+		pairs = append(pairs, p)
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		a, b := pairs[i], pairs[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.Destination != b.Destination {
+			return a.Destination < b.Destination
+		}
+		if a.protocol != b.protocol {
+			return a.protocol < b.protocol
+		}
+		if a.port != b.port {
+			return a.port < b.port
+		}
+		return a.probe < b.probe
+	})
+	return pairs
+}
+
+func (p PairSymmetry) collect(ch chan<- prometheus.Metric) {
+	labelValues := append([]string{p.Source, p.Destination}, p.labels()...)
+	ch <- prometheus.MustNewConstMetric(asymmetryLossDesc, prometheus.GaugeValue, p.LossDelta, labelValues...)
+	if p.Reachable {
+		ch <- prometheus.MustNewConstMetric(asymmetryRttDesc, prometheus.GaugeValue, p.RttDelta, labelValues...)
+		ch <- prometheus.MustNewConstMetric(asymmetryHopsDesc, prometheus.GaugeValue, float64(p.HopsDelta), labelValues...)
+	}
+	asymmetric := 0.0
+	if p.Asymmetric {
+		asymmetric = 1
+	}
+	ch <- prometheus.MustNewConstMetric(asymmetricDesc, prometheus.GaugeValue, asymmetric, labelValues...)
+}
@@ -0,0 +1,42 @@
+package mesh
+
+import (
+	"testing"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSymmetry(t *testing.T) {
+	thresholds := AsymmetryThresholds{RttMean: 5, Loss: 10, Hops: 2}
+	pairs := Symmetry([]Report{
+		{Source: "node-2", Results: []PairResult{
+			{Destination: "node-1", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 9, HopsNum: 5},
+			{Destination: "node-1", Protocol: "TCP", Port: "80", Status: metrics.StatusUnreachable, Loss: 100},
+			{Destination: "node-3", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 1, HopsNum: 1},
+		}},
+		{Source: "node-1", Results: []PairResult{
+			{Destination: "node-2", Protocol: "ICMP", Status: metrics.StatusOk, RttMean: 2, HopsNum: 1},
+			{Destination: "node-2", Protocol: "TCP", Port: "80", Status: metrics.StatusOk},
+		}},
+	}, thresholds)
+
+	// node-2 -> node-3 has no reverse direction
+	require.Len(t, pairs, 2)
+	assert.Equal(t, PairSymmetry{
+		Source: "node-1", Destination: "node-2", check: check{protocol: "ICMP"},
+		Reachable: true, RttDelta: -7, HopsDelta: -4, Asymmetric: true,
+	}, pairs[0])
+	assert.Equal(t, PairSymmetry{
+		Source: "node-1", Destination: "node-2", check: check{protocol: "TCP", port: "80"},
+		LossDelta: -100, Asymmetric: true,
+	}, pairs[1])
+
+	pairs = Symmetry([]Report{
+		{Source: "node-1", Results: []PairResult{{Destination: "node-2", Status: metrics.StatusOk, RttMean: 2, Loss: 10, HopsNum: 3}}},
+		{Source: "node-2", Results: []PairResult{{Destination: "node-1", Status: metrics.StatusOk, RttMean: 4, HopsNum: 1}}},
+	}, thresholds)
+	require.Len(t, pairs, 1)
+	assert.False(t, pairs[0].Asymmetric)
+}
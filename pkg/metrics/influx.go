@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+)
+
+// LineProtocol formats the metric as a single line of InfluxDB line protocol
+// with MeasurementName as a measurement and nanosecond precision timestamp.
+func (m *NetworkLatencyMetric) LineProtocol(timestamp time.Time) string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(MeasurementName))
+	writeTag(&b, "source", m.Tags.Source)
+	writeTag(&b, "destination", m.Tags.Dest)
+	writeTag(&b, "destinationIp", m.Tags.DestIp)
+	writeTag(&b, "protocol", m.Tags.Protocol)
+	writeTag(&b, "port", m.Tags.Port)
+	writeTag(&b, "probe", m.Tags.Probe)
+	writeTag(&b, "interface", m.Tags.Interface)
+	writeTag(&b, "network", m.Tags.Network)
+	writeTag(&b, "dscp", m.Tags.DSCP)
+
+	b.WriteString(" status=")
+	b.WriteString(strconv.Itoa(m.Fields.Status))
+	b.WriteString("i,health=")
+	b.WriteString(strconv.Itoa(m.Fields.Health))
+	b.WriteString("i,sent=")
+	b.WriteString(strconv.Itoa(m.Fields.TotalSent))
+	b.WriteString("i,received=")
+	b.WriteString(strconv.Itoa(m.Fields.TotalReceived))
+	b.WriteString("i,loss=")
+	b.WriteString(strconv.FormatFloat(m.Fields.Loss, 'f', -1, 64))
+	b.WriteString(",rtt_mean=")
+	b.WriteString(strconv.FormatFloat(m.Fields.RttMean, 'f', -1, 64))
+	b.WriteString(",rtt_min=")
+	b.WriteString(strconv.FormatFloat(m.Fields.RttMin, 'f', -1, 64))
+	b.WriteString(",rtt_max=")
+	b.WriteString(strconv.FormatFloat(m.Fields.RttMax, 'f', -1, 64))
+	b.WriteString(",rtt_stddev=")
+	b.WriteString(strconv.FormatFloat(m.Fields.RttDeviation, 'f', -1, 64))
+	b.WriteString(",hops_num=")
+	b.WriteString(strconv.Itoa(m.Fields.HopsNum))
+	b.WriteString("i ")
+	b.WriteString(strconv.FormatInt(timestamp.UnixNano(), 10))
+	return b.String()
+}
+
+// writeTag appends escaped tag to the line. Tags with empty values are not allowed by line protocol and skipped.
+func writeTag(b *strings.Builder, key string, value string) {
+	if value == "" {
+		return
+	}
+	b.WriteByte(',')
+	b.WriteString(tagEscaper.Replace(key))
+	b.WriteByte('=')
+	b.WriteString(tagEscaper.Replace(value))
+}
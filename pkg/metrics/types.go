@@ -6,12 +6,65 @@ const (
 	MeasurementName   = "network_latency"
 	StatusOk          = 0
 	StatusUnreachable = 1
+	HealthOk          = 0
+	HealthDegraded    = 1
+	HealthDown        = 2
+)
+
+// Kinds of probed destinations. Only results of node destinations are used to evaluate node reachability.
+const (
+	DestinationNode         = ""
+	DestinationControlPlane = "controlplane"
+	DestinationPod          = "pod"
+	DestinationService      = "service"
+	// DestinationHost is any other host, e.g. discovered by providers other than nodes
+	DestinationHost = "host"
 )
 
 type CheckTarget struct {
 	Protocol string
 	Port     string
 	MtrKey   string
+	// DefaultPort is true if port isn't set in the check, so Port is a default one
+	DefaultPort bool
+	// DSCP is a traffic class which packets are marked with, e.g. "EF" or "46", empty for unmarked packets
+	DSCP string
+	// ToS is a value of the IP ToS byte which corresponds to DSCP
+	ToS int
+	// PacketsSent, PacketSize and ProbeTimeout override settings of the probe group if not empty
+	PacketsSent  string
+	PacketSize   string
+	ProbeTimeout string
+	// DNSQuery is a name to resolve, used only for DNS checks
+	DNSQuery string
+	// DNSResolvers is a list of resolvers to query, used only for DNS checks
+	DNSResolvers []DNSResolver
+}
+
+// DNSResolver describes DNS server which is queried during DNS checks.
+type DNSResolver struct {
+	// Name is a human-readable resolver name, e.g. "cluster" or "node-local"
+	Name string
+	// Address is an IP address of resolver
+	Address string
+}
+
+// DNSMetric stores result of single DNS resolution.
+type DNSMetric struct {
+	// Resolver which has been queried
+	Resolver DNSResolver
+	// Port of resolver
+	Port string
+	// Query is a resolved name
+	Query string
+	// Status - OK = 0 or UNREACHABLE = 1 (resolver did not answer)
+	Status int
+	// Rcode is a response code returned by resolver, -1 if there is no response
+	Rcode int
+	// Timeout is true if resolver did not answer in time
+	Timeout bool
+	// Resolution time in milliseconds
+	ResolutionTime float64
 }
 
 type MtrOutput struct {
@@ -64,6 +117,8 @@ type NetworkLatencyMetric struct {
 
 // NetworkLatencyMetricTags stores metric meta information.
 type NetworkLatencyMetricTags struct {
+	// Source host name
+	Source string
 	// Destination host name
 	Dest string
 	// Destination host IP address
@@ -72,6 +127,16 @@ type NetworkLatencyMetricTags struct {
 	Protocol string
 	// Port used for check
 	Port string
+	// Probe is a name of probe group, empty for probes configured with environment variables
+	Probe string
+	// Interface is a local interface which packets are sent from, empty for the default route
+	Interface string
+	// Network is a name of the probed network, empty for the cluster network
+	Network string
+	// DSCP is a traffic class which packets are marked with, empty for unmarked packets
+	DSCP string
+	// Kind of the destination, DestinationNode for cluster nodes
+	Kind string
 }
 
 // NetworkLatencyMetricFields stores metric data.
@@ -94,6 +159,10 @@ type NetworkLatencyMetricFields struct {
 	RttDeviation float64
 	// Number of hops in packet path
 	HopsNum int
+	// Percent of lost packets
+	Loss float64
+	// Health - OK = 0, DEGRADED (some thresholds are violated) = 1 or DOWN = 2
+	Health int
 }
 
 func NewNetworkLatencyMetric(dest string, destIp string, protocol string, port string, sent string) *NetworkLatencyMetric {
@@ -110,12 +179,18 @@ func NewNetworkLatencyMetric(dest string, destIp string, protocol string, port s
 	sentInt, _ := strconv.Atoi(sent)
 	m.Fields.TotalSent = sentInt
 	m.Fields.Status = StatusUnreachable
+	m.Fields.Loss = 100.0
+	m.Fields.Health = HealthDown
 	return m
 }
 
 type PingHost struct {
 	IPAddress string `yaml:"ipAddress"`
 	Name      string `yaml:"name"`
+	// Labels are set by discovery provider, e.g. provider name, zone or service
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Kind of the target, DestinationNode for cluster nodes, kind of the probe group is used if empty
+	Kind string `yaml:"-"`
 }
 
 // PingHostList stores list of ping targets to collect network latency metrics.
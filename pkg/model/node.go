@@ -15,4 +15,16 @@ type NodeCollector struct {
 	CheckTargets []*metrics.CheckTarget
 	Targets      metrics.PingHostList
 	MetricsPath  string
+	// Groups are probe groups defined by NetworkLatencyProbe resources.
+	// If there are no groups, settings and targets above are used.
+	Groups []ProbeGroup
+	// ControlPlane are probe groups of control plane components, probed in addition to groups above.
+	ControlPlane []ProbeGroup
+	// Networks are probe groups of additional node networks, probed in addition to groups above.
+	Networks []ProbeGroup
+	// Attachments are probe groups of secondary pod networks attached by Multus, probed in addition to groups above.
+	Attachments []ProbeGroup
+	// CurrentTargets returns targets of the currently applied configuration,
+	// it is safe to call from background probers while configuration is updated.
+	CurrentTargets func() []metrics.PingHost
 }
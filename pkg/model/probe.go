@@ -0,0 +1,26 @@
+package model
+
+import (
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+)
+
+// ProbeGroup is a set of targets probed with the same settings, e.g. defined by a NetworkLatencyProbe resource.
+type ProbeGroup struct {
+	// Name of the group, used as the `probe` label value
+	Name         string
+	PacketsSent  string
+	PacketSize   string
+	ProbeTimeout string
+	// PacketInterval is an interval between packets in seconds, mtr default is used if empty
+	PacketInterval string
+	CheckTargets   []*metrics.CheckTarget
+	Targets        metrics.PingHostList
+	// Interface is a local interface which packets are sent from, the default route is used if empty
+	Interface string
+	// Network is a name of the network the targets belong to, empty for the cluster network
+	Network string
+	// SourceAddress is a local address which packets are sent from, takes precedence over Interface
+	SourceAddress string
+	// Kind of the targets, metrics.DestinationNode for cluster nodes
+	Kind string
+}
@@ -0,0 +1,25 @@
+package model
+
+// ThresholdConfig describes latency thresholds evaluated by exporter for each probed pair.
+type ThresholdConfig struct {
+	Groups []ThresholdGroup `yaml:"groups"`
+}
+
+// ThresholdGroup stores thresholds applied to targets matched by the group selectors.
+// A pair is evaluated against the first matching group. Empty selectors match any value.
+type ThresholdGroup struct {
+	// Name of the group, used as the `group` label value
+	Name string `yaml:"name"`
+	// Destination is a regular expression matched against destination name or IP address
+	Destination string `yaml:"destination"`
+	// Protocol is a protocol name, e.g. ICMP
+	Protocol string `yaml:"protocol"`
+	// RttMean is a maximal allowed average RTT in milliseconds
+	RttMean *float64 `yaml:"rttMean"`
+	// RttMax is a maximal allowed worst RTT in milliseconds
+	RttMax *float64 `yaml:"rttMax"`
+	// Loss is a maximal allowed percent of lost packets
+	Loss *float64 `yaml:"loss"`
+	// Jitter is a maximal allowed standard deviation of RTT in milliseconds
+	Jitter *float64 `yaml:"jitter"`
+}
@@ -0,0 +1,187 @@
+// Package report publishes probe results of exporter as NetworkLatencyReport resources.
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/api/v1alpha1"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+var healthNames = map[int]string{
+	metrics.HealthOk:       v1alpha1.HealthOk,
+	metrics.HealthDegraded: v1alpha1.HealthDegraded,
+	metrics.HealthDown:     v1alpha1.HealthDown,
+}
+
+// Config describes how often and how big reports are published.
+type Config struct {
+	// Interval is a minimal interval between updates of the report
+	Interval time.Duration
+	// MaxResults is a maximal number of results in the report, the worst results are kept
+	MaxResults int
+}
+
+// ConfigFromEnv reads reports configuration from environment.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{}
+	var err error
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("REPORTS_INTERVAL", "1m")); err != nil {
+		return cfg, errors.Wrap(err, "REPORTS_INTERVAL has incorrect value")
+	}
+	if cfg.MaxResults, err = strconv.Atoi(utils.GetEnvWithDefaultValue("REPORTS_MAX_RESULTS", "500")); err != nil {
+		return cfg, errors.Wrap(err, "REPORTS_MAX_RESULTS has incorrect value")
+	}
+	return cfg, nil
+}
+
+// Publisher writes the latest probe results of the source node to NetworkLatencyReport with the node name.
+// It implements collector.ResultHandler. Results are published not more often than once per interval.
+type Publisher struct {
+	cfg       Config
+	client    client.Client
+	source    string
+	logger    log.Logger
+	results   []*metrics.NetworkLatencyMetric
+	timestamp time.Time
+	published time.Time
+	nodeUID   types.UID
+	mutex     sync.Mutex
+}
+
+// NewPublisher creates publisher of reports for the source node.
+func NewPublisher(cfg Config, c client.Client, source string, logger log.Logger) *Publisher {
+	return &Publisher{cfg: cfg, client: c, source: source, logger: logger}
+}
+
+// HandleResults implements collector.ResultHandler.
+func (p *Publisher) HandleResults(ctx context.Context, source string, results []*metrics.NetworkLatencyMetric) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.results = results
+	p.timestamp = time.Now()
+}
+
+// Run publishes new results every interval until context is done.
+func (p *Publisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := p.Publish(ctx); err != nil {
+			_ = level.Warn(p.logger).Log("msg", fmt.Sprintf("Can't publish NetworkLatencyReport %s", p.source), "err", err)
+		}
+	}
+}
+
+// Publish creates or updates report if there are results which haven't been published yet.
+func (p *Publisher) Publish(ctx context.Context) error {
+	p.mutex.Lock()
+	results, timestamp := p.results, p.timestamp
+	p.mutex.Unlock()
+	if timestamp.IsZero() || timestamp.Equal(p.published) {
+		return nil
+	}
+
+	if p.nodeUID == "" {
+		node := &corev1.Node{}
+		if err := p.client.Get(ctx, client.ObjectKey{Name: p.source}, node); err != nil {
+			return errors.Wrapf(err, "Can't get node %s", p.source)
+		}
+		p.nodeUID = node.UID
+	}
+
+	report := &v1alpha1.NetworkLatencyReport{ObjectMeta: metav1.ObjectMeta{Name: p.source}}
+	_, err := controllerutil.CreateOrUpdate(ctx, p.client, report, func() error {
+		// Report is removed together with the node
+		report.OwnerReferences = []metav1.OwnerReference{{
+			APIVersion: "v1",
+			Kind:       "Node",
+			Name:       p.source,
+			UID:        p.nodeUID,
+		}}
+		p.fill(report, results, timestamp)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	p.published = timestamp
+	return nil
+}
+
+// fill sets results to report, the worst results go first and results over the limit are truncated.
+func (p *Publisher) fill(report *v1alpha1.NetworkLatencyReport, results []*metrics.NetworkLatencyMetric, timestamp time.Time) {
+	report.Source = p.source
+	report.Timestamp = metav1.NewTime(timestamp)
+	report.Summary = v1alpha1.ReportSummary{Results: len(results)}
+
+	sorted := append([]*metrics.NetworkLatencyMetric{}, results...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		a, b := sorted[i], sorted[j]
+		if a.Fields.Health != b.Fields.Health {
+			return a.Fields.Health > b.Fields.Health
+		}
+		if a.Tags.Dest != b.Tags.Dest {
+			return a.Tags.Dest < b.Tags.Dest
+		}
+		if a.Tags.Protocol != b.Tags.Protocol {
+			return a.Tags.Protocol < b.Tags.Protocol
+		}
+		if a.Tags.Port != b.Tags.Port {
+			return a.Tags.Port < b.Tags.Port
+		}
+		return a.Tags.Probe < b.Tags.Probe
+	})
+
+	report.Results = make([]v1alpha1.ReportResult, 0, len(sorted))
+	for _, m := range sorted {
+		switch m.Fields.Health {
+		case metrics.HealthDown:
+			report.Summary.Down++
+		case metrics.HealthDegraded:
+			report.Summary.Degraded++
+		default:
+			report.Summary.Ok++
+		}
+		if p.cfg.MaxResults > 0 && len(report.Results) >= p.cfg.MaxResults {
+			continue
+		}
+		report.Results = append(report.Results, v1alpha1.ReportResult{
+			Destination:   m.Tags.Dest,
+			DestinationIP: m.Tags.DestIp,
+			Protocol:      m.Tags.Protocol,
+			Port:          m.Tags.Port,
+			Probe:         m.Tags.Probe,
+			Health:        healthNames[m.Fields.Health],
+			RttMean:       metav1.Duration{Duration: millis(m.Fields.RttMean)},
+			RttMax:        metav1.Duration{Duration: millis(m.Fields.RttMax)},
+			Loss:          strconv.FormatFloat(m.Fields.Loss, 'f', 1, 64),
+			HopsNum:       m.Fields.HopsNum,
+		})
+	}
+	report.Truncated = len(sorted) - len(report.Results)
+}
+
+// millis converts milliseconds to duration rounded to microseconds.
+func millis(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond)).Round(time.Microsecond)
+}
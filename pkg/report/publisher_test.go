@@ -0,0 +1,78 @@
+package report
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/api/v1alpha1"
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func result(dest string, health int, rtt float64) *metrics.NetworkLatencyMetric {
+	m := metrics.NewNetworkLatencyMetric(dest, "10.0.0.1", "ICMP", "1", "10")
+	m.Fields.Health = health
+	m.Fields.RttMean = rtt
+	m.Fields.RttMax = rtt * 2
+	m.Fields.Loss = 0
+	return m
+}
+
+func TestPublish(t *testing.T) {
+	scheme := runtime.NewScheme()
+	require.NoError(t, clientgoscheme.AddToScheme(scheme))
+	require.NoError(t, v1alpha1.AddToScheme(scheme))
+	c := fake.NewClientBuilder().WithScheme(scheme).
+		WithObjects(&corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1", UID: "uid-1"}}).Build()
+	p := NewPublisher(Config{Interval: time.Minute, MaxResults: 2}, c, "node-1", log.NewNopLogger())
+	ctx := context.Background()
+
+	// nothing to publish before the first scrape
+	require.NoError(t, p.Publish(ctx))
+	err := c.Get(ctx, client.ObjectKey{Name: "node-1"}, &v1alpha1.NetworkLatencyReport{})
+	assert.Error(t, err)
+
+	p.HandleResults(ctx, "node-1", []*metrics.NetworkLatencyMetric{
+		result("node-2", metrics.HealthOk, 0.5),
+		result("node-3", metrics.HealthDown, 0),
+		result("node-4", metrics.HealthDegraded, 12.3456),
+	})
+	require.NoError(t, p.Publish(ctx))
+
+	report := &v1alpha1.NetworkLatencyReport{}
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "node-1"}, report))
+	assert.Equal(t, "node-1", report.Source)
+	assert.Equal(t, v1alpha1.ReportSummary{Results: 3, Ok: 1, Degraded: 1, Down: 1}, report.Summary)
+	assert.Equal(t, 1, report.Truncated)
+	require.Len(t, report.Results, 2)
+	assert.Equal(t, "node-3", report.Results[0].Destination)
+	assert.Equal(t, v1alpha1.HealthDown, report.Results[0].Health)
+	assert.Equal(t, "node-4", report.Results[1].Destination)
+	assert.Equal(t, "12.346ms", report.Results[1].RttMean.Duration.String())
+	assert.Equal(t, "0.0", report.Results[1].Loss)
+	require.Len(t, report.OwnerReferences, 1)
+	assert.Equal(t, "Node", report.OwnerReferences[0].Kind)
+	assert.Equal(t, "uid-1", string(report.OwnerReferences[0].UID))
+
+	// the same results are not published twice
+	version := report.ResourceVersion
+	require.NoError(t, p.Publish(ctx))
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "node-1"}, report))
+	assert.Equal(t, version, report.ResourceVersion)
+
+	p.HandleResults(ctx, "node-1", []*metrics.NetworkLatencyMetric{result("node-2", metrics.HealthOk, 0.5)})
+	require.NoError(t, p.Publish(ctx))
+	require.NoError(t, c.Get(ctx, client.ObjectKey{Name: "node-1"}, report))
+	assert.Equal(t, v1alpha1.ReportSummary{Results: 1, Ok: 1}, report.Summary)
+	assert.Equal(t, 0, report.Truncated)
+	assert.Len(t, report.Results, 1)
+}
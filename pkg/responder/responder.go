@@ -0,0 +1,118 @@
+package responder
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxPacketSize is the biggest UDP payload echoed back
+	maxPacketSize = 65535
+	// connTimeout limits how long a single TCP connection is served
+	connTimeout = 5 * time.Second
+)
+
+// Config describes on which port responder listens.
+type Config struct {
+	Enabled bool
+	Port    string
+}
+
+// ConfigFromEnv reads responder configuration from environment.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Enabled: utils.GetEnvWithDefaultValue("RESPONDER_ENABLE", "false") == "true",
+		Port:    utils.GetEnvWithDefaultValue("RESPONDER_PORT", "9274"),
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		return cfg, errors.Errorf("RESPONDER_PORT has incorrect value %s", cfg.Port)
+	}
+	return cfg, nil
+}
+
+// Responder echoes UDP datagrams and accepts TCP connections on the same port,
+// so UDP and TCP checks of peer exporters measure a real application path instead of ICMP unreachable or RST.
+type Responder struct {
+	udp    net.PacketConn
+	tcp    net.Listener
+	logger log.Logger
+	wg     sync.WaitGroup
+}
+
+// Listen opens UDP and TCP sockets on address.
+func Listen(address string, logger log.Logger) (*Responder, error) {
+	udp, err := net.ListenPacket("udp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't listen UDP on %s", address)
+	}
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		_ = udp.Close()
+		return nil, errors.Wrapf(err, "can't listen TCP on %s", address)
+	}
+	return &Responder{udp: udp, tcp: tcp, logger: logger}, nil
+}
+
+// Addr returns the address responder listens on.
+func (r *Responder) Addr() string {
+	return r.tcp.Addr().String()
+}
+
+// Run serves requests until context is done.
+func (r *Responder) Run(ctx context.Context) {
+	_ = level.Info(r.logger).Log("msg", fmt.Sprintf("Starting UDP echo and TCP responder on %s", r.Addr()))
+	r.wg.Add(2)
+	go r.serveUDP()
+	go r.serveTCP()
+	<-ctx.Done()
+	_ = r.udp.Close()
+	_ = r.tcp.Close()
+	r.wg.Wait()
+}
+
+func (r *Responder) serveUDP() {
+	defer r.wg.Done()
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, addr, err := r.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			_ = level.Debug(r.logger).Log("msg", "Can't read UDP packet", "err", err)
+			continue
+		}
+		if _, err := r.udp.WriteTo(buf[:n], addr); err != nil {
+			_ = level.Debug(r.logger).Log("msg", fmt.Sprintf("Can't echo UDP packet to %s", addr), "err", err)
+		}
+	}
+}
+
+func (r *Responder) serveTCP() {
+	defer r.wg.Done()
+	for {
+		conn, err := r.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			_ = level.Debug(r.logger).Log("msg", "Can't accept TCP connection", "err", err)
+			continue
+		}
+		go func() {
+			defer conn.Close()
+			_ = conn.SetDeadline(time.Now().Add(connTimeout))
+			_, _ = io.CopyN(conn, conn, maxPacketSize)
+		}()
+	}
+}
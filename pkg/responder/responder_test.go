@@ -0,0 +1,52 @@
+package responder
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponder(t *testing.T) {
+	r, err := Listen("127.0.0.1:0", log.NewNopLogger())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		r.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for _, network := range []string{"udp", "tcp"} {
+		conn, err := net.DialTimeout(network, r.Addr(), time.Second)
+		require.NoError(t, err, network)
+		_ = conn.SetDeadline(time.Now().Add(time.Second))
+		_, err = conn.Write([]byte("ping"))
+		require.NoError(t, err, network)
+		buf := make([]byte, 4)
+		n, err := conn.Read(buf)
+		require.NoError(t, err, network)
+		assert.Equal(t, "ping", string(buf[:n]), network)
+		_ = conn.Close()
+	}
+}
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("RESPONDER_PORT", "70000")
+	_, err := ConfigFromEnv()
+	assert.Error(t, err)
+
+	t.Setenv("RESPONDER_ENABLE", "true")
+	t.Setenv("RESPONDER_PORT", "9274")
+	cfg, err := ConfigFromEnv()
+	require.NoError(t, err)
+	assert.Equal(t, Config{Enabled: true, Port: "9274"}, cfg)
+}
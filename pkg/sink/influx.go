@@ -0,0 +1,305 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+const (
+	// maxUDPPayload is a maximal size of a single UDP datagram with line protocol
+	maxUDPPayload = 1400
+)
+
+// InfluxConfig describes where and how probe results are written to InfluxDB.
+type InfluxConfig struct {
+	// URL of InfluxDB, e.g. http://influxdb:8086 or udp://influxdb:8089. Empty URL disables push.
+	URL string
+	// Version of InfluxDB HTTP API: 1 or 2
+	Version string
+	// Database and RetentionPolicy are used by InfluxDB v1
+	Database        string
+	RetentionPolicy string
+	Username        string
+	Password        string
+	// Org, Bucket and Token are used by InfluxDB v2
+	Org    string
+	Bucket string
+	Token  string
+	// BatchSize is a maximal number of lines written in a single request
+	BatchSize int
+	// BufferLimit is a maximal number of lines kept in memory while InfluxDB is unavailable
+	BufferLimit   int
+	FlushInterval time.Duration
+	Retries       int
+	RetryBackoff  time.Duration
+	Timeout       time.Duration
+	// PullEnable enables HTTP handler which serves the latest results in line protocol for Telegraf
+	PullEnable bool
+}
+
+// InfluxConfigFromEnv reads InfluxDB sink configuration from environment.
+func InfluxConfigFromEnv() (InfluxConfig, error) {
+	cfg := InfluxConfig{
+		URL:             utils.GetEnvWithDefaultValue("INFLUX_URL", ""),
+		Version:         utils.GetEnvWithDefaultValue("INFLUX_VERSION", "1"),
+		Database:        utils.GetEnvWithDefaultValue("INFLUX_DATABASE", "network_latency"),
+		RetentionPolicy: utils.GetEnvWithDefaultValue("INFLUX_RETENTION_POLICY", ""),
+		Username:        utils.GetEnvWithDefaultValue("INFLUX_USERNAME", ""),
+		Password:        utils.GetEnvWithDefaultValue("INFLUX_PASSWORD", ""),
+		Org:             utils.GetEnvWithDefaultValue("INFLUX_ORG", ""),
+		Bucket:          utils.GetEnvWithDefaultValue("INFLUX_BUCKET", "network_latency"),
+		Token:           utils.GetEnvWithDefaultValue("INFLUX_TOKEN", ""),
+		PullEnable:      utils.GetEnvWithDefaultValue("INFLUX_PULL_ENABLE", "false") == "true",
+	}
+	if cfg.Version != "1" && cfg.Version != "2" {
+		return cfg, errors.Errorf("Unsupported InfluxDB version: %s", cfg.Version)
+	}
+
+	var err error
+	if cfg.BatchSize, err = strconv.Atoi(utils.GetEnvWithDefaultValue("INFLUX_BATCH_SIZE", "1000")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_BATCH_SIZE has incorrect value")
+	}
+	if cfg.BufferLimit, err = strconv.Atoi(utils.GetEnvWithDefaultValue("INFLUX_BUFFER_LIMIT", "10000")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_BUFFER_LIMIT has incorrect value")
+	}
+	if cfg.Retries, err = strconv.Atoi(utils.GetEnvWithDefaultValue("INFLUX_RETRIES", "3")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_RETRIES has incorrect value")
+	}
+	if cfg.FlushInterval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("INFLUX_FLUSH_INTERVAL", "10s")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_FLUSH_INTERVAL has incorrect value")
+	}
+	if cfg.RetryBackoff, err = time.ParseDuration(utils.GetEnvWithDefaultValue("INFLUX_RETRY_BACKOFF", "1s")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_RETRY_BACKOFF has incorrect value")
+	}
+	if cfg.Timeout, err = time.ParseDuration(utils.GetEnvWithDefaultValue("INFLUX_TIMEOUT", "5s")); err != nil {
+		return cfg, errors.Wrap(err, "INFLUX_TIMEOUT has incorrect value")
+	}
+	return cfg, nil
+}
+
+// InfluxSink writes probe results to InfluxDB in line protocol with batching and retries.
+// It implements collector.ResultHandler and http.Handler for the pull endpoint.
+type InfluxSink struct {
+	cfg    InfluxConfig
+	client *http.Client
+	logger log.Logger
+	// buffer stores lines which haven't been written yet
+	buffer []string
+	// dropped counts lines dropped from the head of buffer when it's full,
+	// so lines written by Flush are removed correctly if buffer was trimmed during the write
+	dropped int
+	// latest stores lines of the latest results for the pull endpoint
+	latest []string
+	flush  chan struct{}
+	mutex  sync.Mutex
+}
+
+// NewInfluxSink creates sink for provided configuration.
+func NewInfluxSink(cfg InfluxConfig, logger log.Logger) (*InfluxSink, error) {
+	if cfg.URL != "" {
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, errors.Wrap(err, "Invalid InfluxDB URL")
+		}
+		if u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "udp" {
+			return nil, errors.Errorf("Unsupported InfluxDB URL scheme: %s", u.Scheme)
+		}
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = 1000
+	}
+	return &InfluxSink{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+		logger: logger,
+		flush:  make(chan struct{}, 1),
+	}, nil
+}
+
+// HandleResults implements collector.ResultHandler.
+func (s *InfluxSink) HandleResults(ctx context.Context, source string, results []*metrics.NetworkLatencyMetric) {
+	now := time.Now()
+	lines := make([]string, 0, len(results))
+	for _, m := range results {
+		lines = append(lines, m.LineProtocol(now))
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.latest = lines
+	if s.cfg.URL == "" {
+		return
+	}
+	s.buffer = append(s.buffer, lines...)
+	if s.cfg.BufferLimit > 0 && len(s.buffer) > s.cfg.BufferLimit {
+		dropped := len(s.buffer) - s.cfg.BufferLimit
+		s.buffer = s.buffer[dropped:]
+		s.dropped += dropped
+		_ = level.Warn(s.logger).Log("msg", fmt.Sprintf("InfluxDB buffer is full, %d oldest lines are dropped", dropped))
+	}
+	if len(s.buffer) >= s.cfg.BatchSize {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Run periodically writes buffered lines to InfluxDB until context is done.
+func (s *InfluxSink) Run(ctx context.Context) {
+	if s.cfg.URL == "" {
+		return
+	}
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.flush:
+		}
+		s.Flush(ctx)
+	}
+}
+
+// Flush writes all buffered lines in batches. Lines of a failed batch are kept in buffer for the next attempt.
+func (s *InfluxSink) Flush(ctx context.Context) {
+	for {
+		s.mutex.Lock()
+		size := len(s.buffer)
+		if size > s.cfg.BatchSize {
+			size = s.cfg.BatchSize
+		}
+		batch := append([]string{}, s.buffer[:size]...)
+		dropped := s.dropped
+		s.mutex.Unlock()
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := s.writeWithRetry(ctx, batch); err != nil {
+			_ = level.Error(s.logger).Log("msg", "Failed to write results to InfluxDB", "err", err)
+			return
+		}
+
+		s.mutex.Lock()
+		// Lines of the batch dropped during the write are not in buffer anymore
+		if written := len(batch) - (s.dropped - dropped); written > 0 {
+			s.buffer = s.buffer[written:]
+		}
+		s.mutex.Unlock()
+	}
+}
+
+func (s *InfluxSink) writeWithRetry(ctx context.Context, lines []string) error {
+	return withRetry(ctx, s.cfg.Retries, s.cfg.RetryBackoff, s.logger, "InfluxDB write", func() error {
+		return s.write(ctx, lines)
+	})
+}
+
+func (s *InfluxSink) write(ctx context.Context, lines []string) error {
+	u, err := url.Parse(s.cfg.URL)
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "udp" {
+		if err = s.writeUDP(u.Host, lines); err != nil {
+			return recoverableError{err}
+		}
+		return nil
+	}
+
+	query := url.Values{}
+	query.Set("precision", "ns")
+	if s.cfg.Version == "2" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/api/v2/write"
+		query.Set("org", s.cfg.Org)
+		query.Set("bucket", s.cfg.Bucket)
+	} else {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/write"
+		query.Set("db", s.cfg.Database)
+		if s.cfg.RetentionPolicy != "" {
+			query.Set("rp", s.cfg.RetentionPolicy)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), strings.NewReader(strings.Join(lines, "\n")+"\n"))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if s.cfg.Version == "2" && s.cfg.Token != "" {
+		req.Header.Set("Authorization", "Token "+s.cfg.Token)
+	} else if s.cfg.Username != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("InfluxDB returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	if isRecoverableStatus(resp.StatusCode) {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// writeUDP sends lines in datagrams not bigger than maxUDPPayload.
+func (s *InfluxSink) writeUDP(address string, lines []string) error {
+	conn, err := net.DialTimeout("udp", address, s.cfg.Timeout)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = conn.Close() }()
+
+	var payload bytes.Buffer
+	for _, line := range lines {
+		if payload.Len() > 0 && payload.Len()+len(line)+1 > maxUDPPayload {
+			if _, err = conn.Write(payload.Bytes()); err != nil {
+				return err
+			}
+			payload.Reset()
+		}
+		payload.WriteString(line)
+		payload.WriteByte('\n')
+	}
+	if payload.Len() > 0 {
+		_, err = conn.Write(payload.Bytes())
+	}
+	return err
+}
+
+// ServeHTTP serves the latest probe results in line protocol, e.g. for Telegraf http input.
+func (s *InfluxSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	lines := s.latest
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, line := range lines {
+		_, _ = io.WriteString(w, line+"\n")
+	}
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/prometheus/common/promlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func testResults() []*metrics.NetworkLatencyMetric {
+	m := metrics.NewNetworkLatencyMetric("node 2", "1.2.3.4", "ICMP", "1", "10")
+	m.Tags.Source = "node1"
+	m.Fields.Status = metrics.StatusOk
+	m.Fields.Health = metrics.HealthOk
+	m.Fields.TotalReceived = 10
+	m.Fields.Loss = 0
+	m.Fields.RttMean = 0.5
+	m.Fields.HopsNum = 1
+	return []*metrics.NetworkLatencyMetric{m}
+}
+
+// TestLineProtocol checks line protocol formatting and escaping of tags.
+func TestLineProtocol(t *testing.T) {
+	line := testResults()[0].LineProtocol(time.Unix(1, 0))
+	assert.Equal(t, `network_latency,source=node1,destination=node\ 2,destinationIp=1.2.3.4,protocol=ICMP,port=1 `+
+		`status=0i,health=0i,sent=10i,received=10i,loss=0,rtt_mean=0.5,rtt_min=0,rtt_max=0,rtt_stddev=0,hops_num=1i 1000000000`, line)
+}
+
+// TestInfluxSinkWrite checks that results are written to InfluxDB v1 and v2 endpoints and retried on errors.
+func TestInfluxSinkWrite(t *testing.T) {
+	logger := promlog.New(&promlog.Config{})
+	for _, version := range []string{"1", "2"} {
+		var requests []*http.Request
+		var bodies []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			body, _ := io.ReadAll(r.Body)
+			requests = append(requests, r)
+			bodies = append(bodies, string(body))
+			if len(requests) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+
+		s, err := NewInfluxSink(InfluxConfig{
+			URL: server.URL, Version: version, Database: "db", Username: "user", Password: "pass",
+			Org: "org", Bucket: "bucket", Token: "secret", BatchSize: 10, Retries: 1, Timeout: time.Second,
+		}, logger)
+		assert.NoError(t, err)
+		s.HandleResults(context.Background(), "node1", testResults())
+		s.Flush(context.Background())
+		server.Close()
+
+		assert.Len(t, requests, 2, "version %s", version)
+		assert.Empty(t, s.buffer)
+		assert.True(t, strings.HasPrefix(bodies[1], "network_latency,source=node1,"))
+		if version == "1" {
+			assert.Equal(t, "/write", requests[1].URL.Path)
+			assert.Equal(t, "db", requests[1].URL.Query().Get("db"))
+			user, pass, _ := requests[1].BasicAuth()
+			assert.Equal(t, "user:pass", user+":"+pass)
+		} else {
+			assert.Equal(t, "/api/v2/write", requests[1].URL.Path)
+			assert.Equal(t, "bucket", requests[1].URL.Query().Get("bucket"))
+			assert.Equal(t, "Token secret", requests[1].Header.Get("Authorization"))
+		}
+	}
+}
+
+// TestInfluxSinkFlushWithFullBuffer checks that lines dropped from full buffer during a write
+// are neither resent nor cause unsent lines to be lost.
+func TestInfluxSinkFlushWithFullBuffer(t *testing.T) {
+	var bodies []string
+	var s *InfluxSink
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) == 1 {
+			// New results arrive while the first batch is written and overflow the buffer
+			for i := 0; i < 3; i++ {
+				s.HandleResults(context.Background(), "node1", testResults())
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var err error
+	s, err = NewInfluxSink(InfluxConfig{URL: server.URL, Version: "1", BatchSize: 2, BufferLimit: 3, Timeout: time.Second},
+		promlog.New(&promlog.Config{}))
+	assert.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		s.HandleResults(context.Background(), "node1", testResults())
+	}
+	s.Flush(context.Background())
+
+	// 2 lines of the first batch are written, 3 new lines push out all 3 old ones and are written by next batches
+	assert.Len(t, bodies, 3)
+	assert.Equal(t, 2, strings.Count(bodies[0], "\n"))
+	assert.Equal(t, 2, strings.Count(bodies[1], "\n"))
+	assert.Equal(t, 1, strings.Count(bodies[2], "\n"))
+	assert.Empty(t, s.buffer)
+}
+
+// TestInfluxSinkPull checks that pull endpoint serves the latest results.
+func TestInfluxSinkPull(t *testing.T) {
+	s, err := NewInfluxSink(InfluxConfig{PullEnable: true}, promlog.New(&promlog.Config{}))
+	assert.NoError(t, err)
+	s.HandleResults(context.Background(), "node1", testResults())
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/influx", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, 1, strings.Count(rec.Body.String(), "\n"))
+	assert.Empty(t, s.buffer)
+}
@@ -0,0 +1,391 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	"golang.org/x/net/http2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	OTLPProtocolGRPC = "grpc"
+	OTLPProtocolHTTP = "http/protobuf"
+	otlpHTTPPath     = "/v1/metrics"
+	otlpGRPCPath     = "/opentelemetry.proto.collector.metrics.v1.MetricsService/Export"
+	// otlpMetricPrefix selects metrics produced by collectors, exporter self-monitoring metrics are not exported
+	otlpMetricPrefix = "network_latency"
+	// aggregationTemporalityCumulative is a value of AggregationTemporality enum for cumulative sums
+	aggregationTemporalityCumulative = 2
+)
+
+// gRPC status codes which mean the collector is temporarily unavailable.
+var retryableGRPCCodes = map[string]bool{
+	"1":  true, // CANCELLED
+	"4":  true, // DEADLINE_EXCEEDED
+	"8":  true, // RESOURCE_EXHAUSTED
+	"10": true, // ABORTED
+	"11": true, // OUT_OF_RANGE
+	"14": true, // UNAVAILABLE
+	"15": true, // DATA_LOSS
+}
+
+// OTLPConfig describes OpenTelemetry collector endpoint and export settings.
+type OTLPConfig struct {
+	// Endpoint of OpenTelemetry collector, e.g. http://otel-collector:4318 or http://otel-collector:4317 for gRPC.
+	// Empty endpoint disables export.
+	Endpoint string
+	// Protocol is either grpc or http/protobuf
+	Protocol string
+	Headers  map[string]string
+	// ResourceAttributes are added to the exported resource in addition to node, namespace and service attributes
+	ResourceAttributes map[string]string
+	Interval           time.Duration
+	Timeout            time.Duration
+	Retries            int
+	RetryBackoff       time.Duration
+	// InsecureSkipVerify disables verification of collector certificate
+	InsecureSkipVerify bool
+}
+
+// OTLPConfigFromEnv reads OTLP exporter configuration from environment.
+func OTLPConfigFromEnv() (OTLPConfig, error) {
+	cfg := OTLPConfig{
+		Endpoint:           utils.GetEnvWithDefaultValue("OTLP_ENDPOINT", ""),
+		Protocol:           utils.GetEnvWithDefaultValue("OTLP_PROTOCOL", OTLPProtocolHTTP),
+		InsecureSkipVerify: utils.GetEnvWithDefaultValue("OTLP_INSECURE_SKIP_VERIFY", "false") == "true",
+	}
+	if cfg.Protocol != OTLPProtocolGRPC && cfg.Protocol != OTLPProtocolHTTP {
+		return cfg, errors.Errorf("Unsupported OTLP protocol: %s", cfg.Protocol)
+	}
+	var err error
+	if cfg.Headers, err = ParseLabels(utils.GetEnvWithDefaultValue("OTLP_HEADERS", "")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_HEADERS has incorrect value")
+	}
+	if cfg.ResourceAttributes, err = ParseLabels(utils.GetEnvWithDefaultValue("OTLP_RESOURCE_ATTRIBUTES", "")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_RESOURCE_ATTRIBUTES has incorrect value")
+	}
+	if cluster := utils.GetEnvWithDefaultValue("CLUSTER_NAME", ""); cluster != "" {
+		if _, found := cfg.ResourceAttributes["k8s.cluster.name"]; !found {
+			cfg.ResourceAttributes["k8s.cluster.name"] = cluster
+		}
+	}
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("OTLP_INTERVAL", "30s")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_INTERVAL has incorrect value")
+	}
+	if cfg.Timeout, err = time.ParseDuration(utils.GetEnvWithDefaultValue("OTLP_TIMEOUT", "10s")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_TIMEOUT has incorrect value")
+	}
+	if cfg.RetryBackoff, err = time.ParseDuration(utils.GetEnvWithDefaultValue("OTLP_RETRY_BACKOFF", "1s")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_RETRY_BACKOFF has incorrect value")
+	}
+	if cfg.Retries, err = strconv.Atoi(utils.GetEnvWithDefaultValue("OTLP_RETRIES", "3")); err != nil {
+		return cfg, errors.Wrap(err, "OTLP_RETRIES has incorrect value")
+	}
+	return cfg, nil
+}
+
+// OTLPExporter periodically gathers latency metrics and exports them to OpenTelemetry collector
+// via OTLP over gRPC or HTTP. Gathering returns metrics of the last probe round and doesn't run probes.
+type OTLPExporter struct {
+	cfg      OTLPConfig
+	url      string
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	logger   log.Logger
+	resource []labelPair
+	start    time.Time
+}
+
+// NewOTLPExporter creates exporter with resource describing the node and namespace the exporter is running in.
+func NewOTLPExporter(cfg OTLPConfig, gatherer prometheus.Gatherer, nodeName string, namespace string, logger log.Logger) (*OTLPExporter, error) {
+	u, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, errors.Wrap(err, "Invalid OTLP endpoint")
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, errors.Errorf("Unsupported OTLP endpoint scheme: %s", u.Scheme)
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: cfg.InsecureSkipVerify} // #nosec G402 -- explicitly requested by configuration
+	client := &http.Client{Timeout: cfg.Timeout}
+	if cfg.Protocol == OTLPProtocolGRPC {
+		u.Path = otlpGRPCPath
+		transport := &http2.Transport{TLSClientConfig: tlsConfig}
+		if u.Scheme == "http" {
+			// gRPC without TLS requires HTTP/2 with prior knowledge (h2c)
+			transport.AllowHTTP = true
+			transport.DialTLSContext = func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			}
+		}
+		client.Transport = transport
+	} else {
+		if !strings.HasSuffix(u.Path, otlpHTTPPath) {
+			u.Path = strings.TrimSuffix(u.Path, "/") + otlpHTTPPath
+		}
+		client.Transport = &http.Transport{Proxy: http.ProxyFromEnvironment, TLSClientConfig: tlsConfig}
+	}
+
+	resource := []labelPair{
+		{"service.name", "network-latency-exporter"},
+		{"service.version", version.Version},
+		{"k8s.node.name", nodeName},
+		{"k8s.namespace.name", namespace},
+	}
+	for k, v := range cfg.ResourceAttributes {
+		resource = append(resource, labelPair{k, v})
+	}
+
+	return &OTLPExporter{
+		cfg:      cfg,
+		url:      u.String(),
+		gatherer: gatherer,
+		client:   client,
+		logger:   logger,
+		resource: resource,
+		start:    time.Now(),
+	}, nil
+}
+
+// Run gathers and exports metrics every interval until context is done.
+func (e *OTLPExporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := e.Export(ctx); err != nil {
+			_ = level.Error(e.logger).Log("msg", "Failed to export metrics via OTLP", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Export gathers metrics of the last probe round and sends them to the collector with retries.
+func (e *OTLPExporter) Export(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return err
+	}
+	if err != nil {
+		_ = level.Warn(e.logger).Log("msg", "Some metrics were not gathered for OTLP export", "err", err)
+	}
+
+	body := encodeExportMetricsRequest(families, e.resource, e.start, time.Now())
+	return withRetry(ctx, e.cfg.Retries, e.cfg.RetryBackoff, e.logger, "OTLP export", func() error {
+		if e.cfg.Protocol == OTLPProtocolGRPC {
+			return e.sendGRPC(ctx, body)
+		}
+		return e.sendHTTP(ctx, body)
+	})
+}
+
+func (e *OTLPExporter) newRequest(ctx context.Context, body []byte, contentType string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "network-latency-exporter/"+version.Version)
+	for k, v := range e.cfg.Headers {
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+func (e *OTLPExporter) sendHTTP(ctx context.Context, body []byte) error {
+	req, err := e.newRequest(ctx, body, "application/x-protobuf")
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("OTLP collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if isRecoverableStatus(resp.StatusCode) {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// sendGRPC makes unary gRPC call of MetricsService/Export. The message is sent uncompressed
+// with 5 bytes prefix: compression flag and big-endian message length.
+func (e *OTLPExporter) sendGRPC(ctx context.Context, body []byte) error {
+	framed := make([]byte, 5, 5+len(body))
+	binary.BigEndian.PutUint32(framed[1:], uint32(len(body)))
+	framed = append(framed, body...)
+
+	req, err := e.newRequest(ctx, framed, "application/grpc")
+	if err != nil {
+		return err
+	}
+	req.Header.Set("TE", "trailers")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	// Trailers are available only after the body is read
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		err = errors.Errorf("OTLP collector returned %s", resp.Status)
+		if isRecoverableStatus(resp.StatusCode) {
+			return recoverableError{err}
+		}
+		return err
+	}
+
+	status := resp.Trailer.Get("Grpc-Status")
+	if status == "" {
+		// Trailers-only response puts status into headers
+		status = resp.Header.Get("Grpc-Status")
+	}
+	if status == "" || status == "0" {
+		return nil
+	}
+	err = errors.Errorf("OTLP collector returned gRPC status %s: %s", status, resp.Trailer.Get("Grpc-Message"))
+	if retryableGRPCCodes[status] {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// encodeExportMetricsRequest encodes metric families with otlpMetricPrefix as ExportMetricsServiceRequest:
+//
+//	ExportMetricsServiceRequest { repeated ResourceMetrics resource_metrics = 1; }
+//	ResourceMetrics { Resource resource = 1; repeated ScopeMetrics scope_metrics = 2; }
+//	Resource { repeated KeyValue attributes = 1; }
+//	ScopeMetrics { InstrumentationScope scope = 1; repeated Metric metrics = 2; }
+//	InstrumentationScope { string name = 1; string version = 2; }
+//	Metric { string name = 1; string description = 2; Gauge gauge = 5; Sum sum = 7; }
+//	Gauge { repeated NumberDataPoint data_points = 1; }
+//	Sum { repeated NumberDataPoint data_points = 1; AggregationTemporality aggregation_temporality = 2; bool is_monotonic = 3; }
+//	NumberDataPoint { fixed64 start_time_unix_nano = 2; fixed64 time_unix_nano = 3; double as_double = 4; repeated KeyValue attributes = 7; }
+//	KeyValue { string key = 1; AnyValue value = 2; }
+//	AnyValue { string string_value = 1; }
+func encodeExportMetricsRequest(families []*dto.MetricFamily, resource []labelPair, start time.Time, now time.Time) []byte {
+	var resourceMsg []byte
+	for _, attr := range resource {
+		resourceMsg = appendMessage(resourceMsg, 1, encodeKeyValue(attr))
+	}
+
+	var scope []byte
+	scope = protowire.AppendTag(scope, 1, protowire.BytesType)
+	scope = protowire.AppendString(scope, "network-latency-exporter")
+	scope = protowire.AppendTag(scope, 2, protowire.BytesType)
+	scope = protowire.AppendString(scope, version.Version)
+
+	var scopeMetrics []byte
+	scopeMetrics = appendMessage(scopeMetrics, 1, scope)
+	for _, mf := range families {
+		if !strings.HasPrefix(mf.GetName(), otlpMetricPrefix) {
+			continue
+		}
+		var points []byte
+		for _, m := range mf.GetMetric() {
+			var value float64
+			switch mf.GetType() {
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+			points = appendMessage(points, 1, encodeNumberDataPoint(m.GetLabel(), value, start, now))
+		}
+		if len(points) == 0 {
+			continue
+		}
+
+		var metric []byte
+		metric = protowire.AppendTag(metric, 1, protowire.BytesType)
+		metric = protowire.AppendString(metric, mf.GetName())
+		metric = protowire.AppendTag(metric, 2, protowire.BytesType)
+		metric = protowire.AppendString(metric, mf.GetHelp())
+		if mf.GetType() == dto.MetricType_COUNTER {
+			sum := points
+			sum = protowire.AppendTag(sum, 2, protowire.VarintType)
+			sum = protowire.AppendVarint(sum, aggregationTemporalityCumulative)
+			sum = protowire.AppendTag(sum, 3, protowire.VarintType)
+			sum = protowire.AppendVarint(sum, 1)
+			metric = appendMessage(metric, 7, sum)
+		} else {
+			metric = appendMessage(metric, 5, points)
+		}
+		scopeMetrics = appendMessage(scopeMetrics, 2, metric)
+	}
+
+	var resourceMetrics []byte
+	resourceMetrics = appendMessage(resourceMetrics, 1, resourceMsg)
+	resourceMetrics = appendMessage(resourceMetrics, 2, scopeMetrics)
+	return appendMessage(nil, 1, resourceMetrics)
+}
+
+func encodeNumberDataPoint(labels []*dto.LabelPair, value float64, start time.Time, now time.Time) []byte {
+	var point []byte
+	point = protowire.AppendTag(point, 2, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, uint64(start.UnixNano()))
+	point = protowire.AppendTag(point, 3, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, uint64(now.UnixNano()))
+	point = protowire.AppendTag(point, 4, protowire.Fixed64Type)
+	point = protowire.AppendFixed64(point, math.Float64bits(value))
+	for _, l := range labels {
+		if l.GetValue() == "" {
+			continue
+		}
+		point = appendMessage(point, 7, encodeKeyValue(labelPair{l.GetName(), l.GetValue()}))
+	}
+	return point
+}
+
+func encodeKeyValue(attr labelPair) []byte {
+	var anyValue []byte
+	anyValue = protowire.AppendTag(anyValue, 1, protowire.BytesType)
+	anyValue = protowire.AppendString(anyValue, attr.value)
+
+	var kv []byte
+	kv = protowire.AppendTag(kv, 1, protowire.BytesType)
+	kv = protowire.AppendString(kv, attr.name)
+	return appendMessage(kv, 2, anyValue)
+}
+
+// appendMessage appends embedded message as a length-delimited field.
+func appendMessage(b []byte, num protowire.Number, msg []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, msg)
+}
+
+// String describes exporter for logs.
+func (e *OTLPExporter) String() string {
+	return fmt.Sprintf("OTLP %s exporter to %s", e.cfg.Protocol, e.url)
+}
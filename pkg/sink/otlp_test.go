@@ -0,0 +1,90 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promlog"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRegistry() *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "network_latency_rtt_mean", Help: "help"}, []string{"source", "destination"})
+	gauge.WithLabelValues("node1", "node2").Set(0.5)
+	counter := prometheus.NewCounter(prometheus.CounterOpts{Name: "monitoring_scrape_scrapes_total", Help: "help"})
+	registry.MustRegister(gauge, counter)
+	return registry
+}
+
+// TestOTLPExporterHTTP checks that latency metrics are exported with resource attributes via OTLP/HTTP.
+func TestOTLPExporterHTTP(t *testing.T) {
+	var body []byte
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/x-protobuf", r.Header.Get("Content-Type"))
+		assert.Equal(t, "value", r.Header.Get("X-Custom"))
+		path = r.URL.Path
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	e, err := NewOTLPExporter(OTLPConfig{
+		Endpoint:           server.URL,
+		Protocol:           OTLPProtocolHTTP,
+		Headers:            map[string]string{"X-Custom": "value"},
+		ResourceAttributes: map[string]string{"k8s.cluster.name": "edge"},
+		Timeout:            time.Second,
+	}, testRegistry(), "node1", "monitoring", promlog.New(&promlog.Config{}))
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(context.Background()))
+
+	assert.Equal(t, otlpHTTPPath, path)
+	for _, expected := range []string{"network_latency_rtt_mean", "k8s.node.name", "node1", "k8s.namespace.name", "monitoring", "edge", "destination", "node2"} {
+		assert.True(t, bytes.Contains(body, []byte(expected)), "body should contain %s", expected)
+	}
+	assert.False(t, bytes.Contains(body, []byte("monitoring_scrape_scrapes_total")))
+}
+
+// TestOTLPExporterGRPC checks gRPC framing and handling of gRPC status in trailers.
+func TestOTLPExporterGRPC(t *testing.T) {
+	var calls int
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		assert.Equal(t, otlpGRPCPath, r.URL.Path)
+		assert.Equal(t, "application/grpc", r.Header.Get("Content-Type"))
+		framed, _ := io.ReadAll(r.Body)
+		assert.Equal(t, byte(0), framed[0])
+		assert.Equal(t, len(framed)-5, int(binary.BigEndian.Uint32(framed[1:5])))
+
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "application/grpc")
+		w.WriteHeader(http.StatusOK)
+		if calls == 1 {
+			w.Header().Set("Grpc-Status", "14") // UNAVAILABLE
+		} else {
+			w.Header().Set("Grpc-Status", "0")
+		}
+	}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	e, err := NewOTLPExporter(OTLPConfig{
+		Endpoint:           server.URL,
+		Protocol:           OTLPProtocolGRPC,
+		Timeout:            time.Second,
+		Retries:            1,
+		InsecureSkipVerify: true,
+	}, testRegistry(), "node1", "monitoring", promlog.New(&promlog.Config{}))
+	assert.NoError(t, err)
+	assert.NoError(t, e.Export(context.Background()))
+	assert.Equal(t, 2, calls)
+}
@@ -0,0 +1,352 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/klauspost/compress/s2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	remoteWriteVersion = "0.1.0"
+	metricNameLabel    = "__name__"
+)
+
+// RemoteWriteConfig describes Prometheus remote_write endpoint and push settings.
+type RemoteWriteConfig struct {
+	// URL of remote_write receiver. Empty URL disables push.
+	URL               string
+	Username          string
+	Password          string
+	BearerToken       string
+	BearerTokenFile   string
+	ExternalLabels    map[string]string
+	Interval          time.Duration
+	Timeout           time.Duration
+	QueueSize         int
+	MaxSamplesPerSend int
+	Retries           int
+	RetryBackoff      time.Duration
+}
+
+// RemoteWriteConfigFromEnv reads remote_write configuration from environment.
+func RemoteWriteConfigFromEnv() (RemoteWriteConfig, error) {
+	cfg := RemoteWriteConfig{
+		URL:             utils.GetEnvWithDefaultValue("REMOTE_WRITE_URL", ""),
+		Username:        utils.GetEnvWithDefaultValue("REMOTE_WRITE_USERNAME", ""),
+		Password:        utils.GetEnvWithDefaultValue("REMOTE_WRITE_PASSWORD", ""),
+		BearerToken:     utils.GetEnvWithDefaultValue("REMOTE_WRITE_BEARER_TOKEN", ""),
+		BearerTokenFile: utils.GetEnvWithDefaultValue("REMOTE_WRITE_BEARER_TOKEN_FILE", ""),
+	}
+	var err error
+	if cfg.ExternalLabels, err = ParseLabels(utils.GetEnvWithDefaultValue("REMOTE_WRITE_EXTERNAL_LABELS", "")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_EXTERNAL_LABELS has incorrect value")
+	}
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("REMOTE_WRITE_INTERVAL", "30s")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_INTERVAL has incorrect value")
+	}
+	if cfg.Timeout, err = time.ParseDuration(utils.GetEnvWithDefaultValue("REMOTE_WRITE_TIMEOUT", "10s")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_TIMEOUT has incorrect value")
+	}
+	if cfg.RetryBackoff, err = time.ParseDuration(utils.GetEnvWithDefaultValue("REMOTE_WRITE_RETRY_BACKOFF", "1s")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_RETRY_BACKOFF has incorrect value")
+	}
+	if cfg.QueueSize, err = strconv.Atoi(utils.GetEnvWithDefaultValue("REMOTE_WRITE_QUEUE_SIZE", "100")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_QUEUE_SIZE has incorrect value")
+	}
+	if cfg.MaxSamplesPerSend, err = strconv.Atoi(utils.GetEnvWithDefaultValue("REMOTE_WRITE_MAX_SAMPLES_PER_SEND", "2000")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_MAX_SAMPLES_PER_SEND has incorrect value")
+	}
+	if cfg.Retries, err = strconv.Atoi(utils.GetEnvWithDefaultValue("REMOTE_WRITE_RETRIES", "3")); err != nil {
+		return cfg, errors.Wrap(err, "REMOTE_WRITE_RETRIES has incorrect value")
+	}
+	return cfg, nil
+}
+
+// ParseLabels parses comma-separated list of labels in format `name=value`.
+func ParseLabels(str string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, l := range strings.Split(strings.TrimSpace(str), ",") {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		nameAndValue := strings.SplitN(l, "=", 2)
+		if len(nameAndValue) != 2 || strings.TrimSpace(nameAndValue[0]) == "" {
+			return nil, errors.Errorf("Invalid label: %s", l)
+		}
+		labels[strings.TrimSpace(nameAndValue[0])] = strings.TrimSpace(nameAndValue[1])
+	}
+	return labels, nil
+}
+
+// timeSeries is a single series of remote_write request.
+type timeSeries struct {
+	labels    []labelPair
+	value     float64
+	timestamp int64
+}
+
+type labelPair struct {
+	name  string
+	value string
+}
+
+// RemoteWriter periodically gathers metrics and pushes them via Prometheus remote_write protocol.
+// Gathering returns metrics of the last probe round and doesn't run probes.
+type RemoteWriter struct {
+	cfg      RemoteWriteConfig
+	gatherer prometheus.Gatherer
+	client   *http.Client
+	logger   log.Logger
+	queue    chan []byte
+}
+
+// NewRemoteWriter creates remote writer which gathers metrics from the gatherer.
+func NewRemoteWriter(cfg RemoteWriteConfig, gatherer prometheus.Gatherer, logger log.Logger) *RemoteWriter {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = 1
+	}
+	return &RemoteWriter{
+		cfg:      cfg,
+		gatherer: gatherer,
+		client:   &http.Client{Timeout: cfg.Timeout},
+		logger:   logger,
+		queue:    make(chan []byte, cfg.QueueSize),
+	}
+}
+
+// Run gathers and sends metrics every interval until context is done.
+func (w *RemoteWriter) Run(ctx context.Context) {
+	go w.send(ctx)
+	ticker := time.NewTicker(w.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		if err := w.Collect(); err != nil {
+			_ = level.Error(w.logger).Log("msg", "Failed to gather metrics for remote write", "err", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Collect gathers metrics, splits them into requests and puts them into the send queue.
+// Requests which don't fit into the queue are dropped.
+func (w *RemoteWriter) Collect() error {
+	families, err := w.gatherer.Gather()
+	if err != nil && len(families) == 0 {
+		return err
+	}
+	if err != nil {
+		// Gather returns as many metrics as possible even in case of errors
+		_ = level.Warn(w.logger).Log("msg", "Some metrics were not gathered for remote write", "err", err)
+	}
+
+	series := toTimeSeries(families, w.cfg.ExternalLabels, time.Now().UnixMilli())
+	batchSize := w.cfg.MaxSamplesPerSend
+	if batchSize <= 0 {
+		batchSize = len(series)
+	}
+	for start := 0; start < len(series); start += batchSize {
+		end := start + batchSize
+		if end > len(series) {
+			end = len(series)
+		}
+		select {
+		case w.queue <- s2.EncodeSnappy(nil, encodeWriteRequest(series[start:end])):
+		default:
+			_ = level.Warn(w.logger).Log("msg", fmt.Sprintf("Remote write queue is full, %d samples are dropped", end-start))
+		}
+	}
+	return nil
+}
+
+func (w *RemoteWriter) send(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case body := <-w.queue:
+			if err := w.sendWithRetry(ctx, body); err != nil {
+				_ = level.Error(w.logger).Log("msg", "Failed to send metrics via remote write", "err", err)
+			}
+		}
+	}
+}
+
+func (w *RemoteWriter) sendWithRetry(ctx context.Context, body []byte) error {
+	return withRetry(ctx, w.cfg.Retries, w.cfg.RetryBackoff, w.logger, "Remote write", func() error {
+		return w.sendOnce(ctx, body)
+	})
+}
+
+func (w *RemoteWriter) sendOnce(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.cfg.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "network-latency-exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", remoteWriteVersion)
+	if err = w.authorize(req); err != nil {
+		return err
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = errors.Errorf("Remote write receiver returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if isRecoverableStatus(resp.StatusCode) {
+		return recoverableError{err}
+	}
+	return err
+}
+
+func (w *RemoteWriter) authorize(req *http.Request) error {
+	switch {
+	case w.cfg.BearerTokenFile != "":
+		token, err := os.ReadFile(w.cfg.BearerTokenFile)
+		if err != nil {
+			return errors.Wrap(err, "Can't read bearer token file")
+		}
+		req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	case w.cfg.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+w.cfg.BearerToken)
+	case w.cfg.Username != "":
+		req.SetBasicAuth(w.cfg.Username, w.cfg.Password)
+	}
+	return nil
+}
+
+// toTimeSeries converts gathered metric families into remote_write series.
+// External labels are added only if the metric doesn't have a label with the same name.
+func toTimeSeries(families []*dto.MetricFamily, externalLabels map[string]string, now int64) []timeSeries {
+	var series []timeSeries
+	for _, mf := range families {
+		for _, m := range mf.GetMetric() {
+			ts := now
+			if m.TimestampMs != nil {
+				ts = m.GetTimestampMs()
+			}
+			add := func(name string, value float64, extra ...labelPair) {
+				series = append(series, timeSeries{
+					labels:    buildLabels(name, m.GetLabel(), extra, externalLabels),
+					value:     value,
+					timestamp: ts,
+				})
+			}
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(mf.GetName(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(mf.GetName(), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(mf.GetName(), m.GetUntyped().GetValue())
+			case dto.MetricType_SUMMARY:
+				for _, q := range m.GetSummary().GetQuantile() {
+					add(mf.GetName(), q.GetValue(), labelPair{"quantile", formatFloat(q.GetQuantile())})
+				}
+				add(mf.GetName()+"_sum", m.GetSummary().GetSampleSum())
+				add(mf.GetName()+"_count", float64(m.GetSummary().GetSampleCount()))
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				for _, b := range m.GetHistogram().GetBucket() {
+					add(mf.GetName()+"_bucket", float64(b.GetCumulativeCount()), labelPair{"le", formatFloat(b.GetUpperBound())})
+				}
+				add(mf.GetName()+"_bucket", float64(m.GetHistogram().GetSampleCount()), labelPair{"le", "+Inf"})
+				add(mf.GetName()+"_sum", m.GetHistogram().GetSampleSum())
+				add(mf.GetName()+"_count", float64(m.GetHistogram().GetSampleCount()))
+			}
+		}
+	}
+	return series
+}
+
+func buildLabels(name string, pairs []*dto.LabelPair, extra []labelPair, externalLabels map[string]string) []labelPair {
+	labels := []labelPair{{metricNameLabel, name}}
+	present := map[string]bool{metricNameLabel: true}
+	for _, p := range pairs {
+		// Labels with empty values are the same as absent labels
+		if p.GetValue() == "" {
+			continue
+		}
+		labels = append(labels, labelPair{p.GetName(), p.GetValue()})
+		present[p.GetName()] = true
+	}
+	for _, l := range extra {
+		labels = append(labels, l)
+		present[l.name] = true
+	}
+	for n, v := range externalLabels {
+		if !present[n] {
+			labels = append(labels, labelPair{n, v})
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+	return labels
+}
+
+func formatFloat(f float64) string {
+	if math.IsInf(f, +1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// encodeWriteRequest encodes series as prometheus.WriteRequest protobuf message:
+//
+//	WriteRequest { repeated TimeSeries timeseries = 1; }
+//	TimeSeries { repeated Label labels = 1; repeated Sample samples = 2; }
+//	Label { string name = 1; string value = 2; }
+//	Sample { double value = 1; int64 timestamp = 2; }
+func encodeWriteRequest(series []timeSeries) []byte {
+	var req []byte
+	for _, s := range series {
+		var ts []byte
+		for _, l := range s.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.value)
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(s.timestamp))
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+	return req
+}
@@ -0,0 +1,104 @@
+package sink
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/promlog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// decodeWriteRequest parses WriteRequest into map of series label sets to sample values.
+func decodeWriteRequest(t *testing.T, b []byte) map[string]float64 {
+	res := make(map[string]float64)
+	forEach := func(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, u uint64)) {
+		for len(b) > 0 {
+			num, typ, n := protowire.ConsumeTag(b)
+			assert.GreaterOrEqual(t, n, 0)
+			b = b[n:]
+			switch typ {
+			case protowire.BytesType:
+				v, n := protowire.ConsumeBytes(b)
+				fn(num, typ, v, 0)
+				b = b[n:]
+			case protowire.Fixed64Type:
+				v, n := protowire.ConsumeFixed64(b)
+				fn(num, typ, nil, v)
+				b = b[n:]
+			case protowire.VarintType:
+				v, n := protowire.ConsumeVarint(b)
+				fn(num, typ, nil, v)
+				b = b[n:]
+			}
+		}
+	}
+	forEach(b, func(_ protowire.Number, _ protowire.Type, ts []byte, _ uint64) {
+		key, value := "", 0.0
+		forEach(ts, func(num protowire.Number, _ protowire.Type, v []byte, _ uint64) {
+			if num == 1 {
+				forEach(v, func(num protowire.Number, _ protowire.Type, s []byte, _ uint64) {
+					key += string(s)
+					if num == 1 {
+						key += "="
+					} else {
+						key += ";"
+					}
+				})
+			} else {
+				forEach(v, func(num protowire.Number, _ protowire.Type, _ []byte, u uint64) {
+					if num == 1 {
+						value = math.Float64frombits(u)
+					}
+				})
+			}
+		})
+		res[key] = value
+	})
+	return res
+}
+
+// TestRemoteWriter checks that gathered metrics are pushed with external labels, auth and retries.
+func TestRemoteWriter(t *testing.T) {
+	registry := prometheus.NewRegistry()
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "network_latency_rtt_mean", Help: "help"}, []string{"source", "cluster"})
+	gauge.WithLabelValues("node1", "").Set(0.5)
+	registry.MustRegister(gauge)
+
+	var bodies [][]byte
+	var auth []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "snappy", r.Header.Get("Content-Encoding"))
+		compressed, _ := io.ReadAll(r.Body)
+		body, err := s2.Decode(nil, compressed)
+		assert.NoError(t, err)
+		bodies = append(bodies, body)
+		auth = append(auth, r.Header.Get("Authorization"))
+		if len(bodies) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	w := NewRemoteWriter(RemoteWriteConfig{
+		URL:            server.URL,
+		BearerToken:    "secret",
+		ExternalLabels: map[string]string{"cluster": "edge", "source": "ignored"},
+		Timeout:        time.Second,
+		QueueSize:      1,
+		Retries:        1,
+	}, registry, promlog.New(&promlog.Config{}))
+	assert.NoError(t, w.Collect())
+	assert.NoError(t, w.sendWithRetry(context.Background(), <-w.queue))
+
+	assert.Len(t, bodies, 2)
+	assert.Equal(t, "Bearer secret", auth[1])
+	assert.Equal(t, map[string]float64{"__name__=network_latency_rtt_mean;cluster=edge;source=node1;": 0.5}, decodeWriteRequest(t, bodies[1]))
+}
@@ -0,0 +1,45 @@
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// recoverableError marks errors which are worth to retry: network errors, 5xx and 429 responses.
+type recoverableError struct {
+	error
+}
+
+// isRecoverableStatus returns true for HTTP statuses which mean the receiver is temporarily unavailable.
+func isRecoverableStatus(code int) bool {
+	return code/100 == 5 || code == http.StatusTooManyRequests
+}
+
+// withRetry calls fn until it succeeds, returns not recoverable error or all retries are exhausted.
+// The delay between attempts starts from backoff and doubles after each attempt.
+func withRetry(ctx context.Context, retries int, backoff time.Duration, logger log.Logger, name string, fn func() error) error {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+		}
+		err = fn()
+		var recoverable recoverableError
+		if err == nil || !errors.As(err, &recoverable) {
+			return err
+		}
+		_ = level.Debug(logger).Log("msg", fmt.Sprintf("%s attempt %d failed", name, attempt+1), "err", err)
+	}
+	return err
+}
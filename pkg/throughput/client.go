@@ -0,0 +1,143 @@
+package throughput
+
+import (
+	"context"
+	"encoding/binary"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// clientGrace is how long client waits for delayed UDP datagrams before asking for summary.
+const clientGrace = 200 * time.Millisecond
+
+// Result is a result of a single throughput test.
+type Result struct {
+	Destination   string    `json:"destination"`
+	DestinationIP string    `json:"destinationIp"`
+	Protocol      string    `json:"protocol"`
+	Timestamp     time.Time `json:"timestamp"`
+	Duration      float64   `json:"duration"`
+	Bytes         uint64    `json:"bytes"`
+	BitsPerSecond float64   `json:"bitsPerSecond"`
+	// Retransmits is the number of retransmitted TCP segments, it is collected only on Linux
+	Retransmits uint32 `json:"retransmits,omitempty"`
+	// Sent and Received are numbers of UDP datagrams, Loss is percent of lost datagrams
+	Sent     uint64  `json:"sent,omitempty"`
+	Received uint64  `json:"received,omitempty"`
+	Loss     float64 `json:"loss,omitempty"`
+	Error    string  `json:"error,omitempty"`
+}
+
+// pacer limits sending rate.
+type pacer struct {
+	rate  int64
+	start time.Time
+	sent  int64
+}
+
+// wait blocks until n more bytes can be sent.
+func (p *pacer) wait(ctx context.Context, n int) error {
+	p.sent += int64(n)
+	if p.rate == 0 {
+		return ctx.Err()
+	}
+	due := p.start.Add(time.Duration(float64(p.sent*8) / float64(p.rate) * float64(time.Second)))
+	if delay := time.Until(due); delay > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+	return nil
+}
+
+// Measure executes throughput test to server on address with protocol.
+func Measure(ctx context.Context, address string, protocol string, duration time.Duration, cfg Config) (Result, error) {
+	result := Result{Protocol: protocol, Timestamp: time.Now()}
+	dialer := net.Dialer{Timeout: 5 * time.Second}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return result, errors.Wrapf(err, "can't connect to throughput test server %s", address)
+	}
+	tcpConn := conn.(*net.TCPConn)
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(duration + serverGrace))
+
+	req := request{mode: modeTCP, duration: duration, id: rand.Uint64()}
+	if protocol == UDP {
+		req.mode = modeUDP
+	}
+	if _, err := conn.Write(req.marshal()); err != nil {
+		return result, errors.Wrap(err, "can't send throughput test request")
+	}
+	status := make([]byte, 1)
+	if _, err := conn.Read(status); err != nil {
+		return result, errors.Wrap(err, "can't read throughput test status")
+	}
+	switch status[0] {
+	case statusOk:
+	case statusBusy:
+		return result, errors.New("throughput test server is busy with another test")
+	default:
+		return result, errors.New("throughput test is rejected by server")
+	}
+
+	p := &pacer{rate: cfg.Rate, start: time.Now()}
+	end := p.start.Add(duration)
+	if protocol == TCP {
+		buf := make([]byte, 64*1024)
+		for time.Now().Before(end) {
+			if _, err := conn.Write(buf); err != nil {
+				return result, errors.Wrap(err, "can't send throughput test data")
+			}
+			if err := p.wait(ctx, len(buf)); err != nil {
+				return result, err
+			}
+		}
+		if r, err := retransmits(tcpConn); err == nil {
+			result.Retransmits = r
+		}
+	} else {
+		udpConn, err := net.Dial("udp", address)
+		if err != nil {
+			return result, errors.Wrapf(err, "can't connect to throughput test server %s", address)
+		}
+		defer func() { _ = udpConn.Close() }()
+		buf := make([]byte, cfg.PacketSize)
+		binary.BigEndian.PutUint64(buf, req.id)
+		for seq := uint32(0); time.Now().Before(end); seq++ {
+			binary.BigEndian.PutUint32(buf[8:], seq)
+			// datagrams dropped by the local socket buffer are counted as lost
+			_, _ = udpConn.Write(buf)
+			result.Sent++
+			if err := p.wait(ctx, len(buf)); err != nil {
+				return result, err
+			}
+		}
+		time.Sleep(clientGrace)
+	}
+	if err := tcpConn.CloseWrite(); err != nil {
+		return result, err
+	}
+	s, err := readSummary(conn)
+	if err != nil {
+		return result, errors.Wrap(err, "can't read throughput test summary")
+	}
+
+	result.Bytes = s.bytes
+	result.Duration = s.elapsed.Seconds()
+	if result.Duration > 0 {
+		result.BitsPerSecond = float64(s.bytes*8) / result.Duration
+	}
+	if protocol == UDP {
+		result.Received = s.packets
+		if result.Sent > 0 && result.Sent >= result.Received {
+			result.Loss = float64(result.Sent-result.Received) / float64(result.Sent) * 100
+		}
+	}
+	return result, nil
+}
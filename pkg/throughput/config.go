@@ -0,0 +1,102 @@
+package throughput
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+const (
+	TCP = "TCP"
+	UDP = "UDP"
+)
+
+// Config describes throughput tests between exporters.
+type Config struct {
+	Enabled bool
+	Port    string
+	// Duration is a default duration of a test
+	Duration time.Duration
+	// MaxDuration limits duration of tests requested via HTTP endpoint and accepted by the server
+	MaxDuration time.Duration
+	// Rate limits sending rate in bits per second, 0 means unlimited for TCP
+	Rate int64
+	// PacketSize is a size of UDP datagrams
+	PacketSize int
+	Protocols  []string
+	// Schedule is how often all nodes are tested, 0 disables scheduled tests
+	Schedule time.Duration
+	// Token authenticates requests of HTTP endpoint, empty token disables the endpoint
+	Token string
+}
+
+// ConfigFromEnv reads throughput tests configuration from environment.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Enabled: utils.GetEnvWithDefaultValue("THROUGHPUT_ENABLE", "false") == "true",
+		Port:    utils.GetEnvWithDefaultValue("THROUGHPUT_PORT", "9275"),
+		Token:   utils.GetEnvWithDefaultValue("THROUGHPUT_TOKEN", ""),
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		return cfg, errors.Errorf("THROUGHPUT_PORT has incorrect value %s", cfg.Port)
+	}
+	var err error
+	if cfg.Duration, err = time.ParseDuration(utils.GetEnvWithDefaultValue("THROUGHPUT_DURATION", "5s")); err != nil {
+		return cfg, errors.Wrap(err, "THROUGHPUT_DURATION has incorrect value")
+	}
+	if cfg.MaxDuration, err = time.ParseDuration(utils.GetEnvWithDefaultValue("THROUGHPUT_MAX_DURATION", "30s")); err != nil {
+		return cfg, errors.Wrap(err, "THROUGHPUT_MAX_DURATION has incorrect value")
+	}
+	if cfg.Duration <= 0 || cfg.Duration > cfg.MaxDuration {
+		return cfg, errors.Errorf("THROUGHPUT_DURATION %s must be positive and not greater than THROUGHPUT_MAX_DURATION %s", cfg.Duration, cfg.MaxDuration)
+	}
+	rate := utils.GetEnvWithDefaultValue("THROUGHPUT_RATE", "100M")
+	if cfg.Rate, err = ParseRate(rate); err != nil {
+		return cfg, errors.Wrap(err, "THROUGHPUT_RATE has incorrect value")
+	}
+	packetSize := utils.GetEnvWithDefaultValue("THROUGHPUT_PACKET_SIZE", "1400")
+	if cfg.PacketSize, err = strconv.Atoi(packetSize); err != nil || cfg.PacketSize < udpHeaderLen || cfg.PacketSize > 65507 {
+		return cfg, errors.Errorf("THROUGHPUT_PACKET_SIZE has incorrect value %s", packetSize)
+	}
+	for _, p := range strings.Split(utils.GetEnvWithDefaultValue("THROUGHPUT_PROTOCOLS", TCP), ",") {
+		p = strings.TrimSpace(p)
+		if p != TCP && p != UDP {
+			return cfg, errors.Errorf("Unsupported throughput test protocol %s", p)
+		}
+		if p == UDP && cfg.Rate == 0 {
+			return cfg, errors.New("THROUGHPUT_RATE must be set for UDP throughput tests")
+		}
+		cfg.Protocols = append(cfg.Protocols, p)
+	}
+	if cfg.Schedule, err = time.ParseDuration(utils.GetEnvWithDefaultValue("THROUGHPUT_SCHEDULE", "0s")); err != nil {
+		return cfg, errors.Wrap(err, "THROUGHPUT_SCHEDULE has incorrect value")
+	}
+	return cfg, nil
+}
+
+// ParseRate parses rate in bits per second with optional K, M or G suffix, e.g. 100M.
+func ParseRate(s string) (int64, error) {
+	multiplier := int64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1000
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1000 * 1000
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1000 * 1000 * 1000
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+	value, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if value < 0 {
+		return 0, errors.Errorf("rate %d is negative", value)
+	}
+	return value * multiplier, nil
+}
@@ -0,0 +1,105 @@
+package throughput
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Test is started with a request sent over TCP control connection. Server answers with a status.
+// In TCP mode the client sends data over the same connection, in UDP mode it sends datagrams to the same port
+// of the server marked with test ID. When the client half-closes control connection, server answers with a summary.
+const (
+	magic          = "NLTP"
+	requestLen     = 17
+	summaryLen     = 24
+	udpHeaderLen   = 12
+	statusOk       = byte(0)
+	statusBusy     = byte(1)
+	statusRejected = byte(2)
+	modeTCP        = byte('T')
+	modeUDP        = byte('U')
+)
+
+// request describes a test requested by client.
+type request struct {
+	mode     byte
+	duration time.Duration
+	id       uint64
+}
+
+func (r request) marshal() []byte {
+	b := make([]byte, requestLen)
+	copy(b, magic)
+	b[4] = r.mode
+	binary.BigEndian.PutUint32(b[5:], uint32(r.duration/time.Millisecond))
+	binary.BigEndian.PutUint64(b[9:], r.id)
+	return b
+}
+
+func readRequest(r io.Reader) (request, error) {
+	b := make([]byte, requestLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return request{}, err
+	}
+	if string(b[:4]) != magic {
+		return request{}, errors.New("incorrect throughput test request")
+	}
+	return request{
+		mode:     b[4],
+		duration: time.Duration(binary.BigEndian.Uint32(b[5:])) * time.Millisecond,
+		id:       binary.BigEndian.Uint64(b[9:]),
+	}, nil
+}
+
+// summary describes data received by server.
+type summary struct {
+	bytes   uint64
+	packets uint64
+	// elapsed is time between the first and the last received data
+	elapsed time.Duration
+}
+
+func (s summary) marshal() []byte {
+	b := make([]byte, summaryLen)
+	binary.BigEndian.PutUint64(b[0:], s.bytes)
+	binary.BigEndian.PutUint64(b[8:], s.packets)
+	binary.BigEndian.PutUint64(b[16:], uint64(s.elapsed))
+	return b
+}
+
+func readSummary(r io.Reader) (summary, error) {
+	b := make([]byte, summaryLen)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return summary{}, err
+	}
+	return summary{
+		bytes:   binary.BigEndian.Uint64(b[0:]),
+		packets: binary.BigEndian.Uint64(b[8:]),
+		elapsed: time.Duration(binary.BigEndian.Uint64(b[16:])),
+	}, nil
+}
+
+// counter counts received data.
+type counter struct {
+	summary
+	first time.Time
+	last  time.Time
+}
+
+func (c *counter) add(n int, now time.Time) {
+	if c.first.IsZero() {
+		c.first = now
+	}
+	c.last = now
+	c.bytes += uint64(n)
+	c.packets++
+}
+
+func (c *counter) result() summary {
+	s := c.summary
+	s.elapsed = c.last.Sub(c.first)
+	return s
+}
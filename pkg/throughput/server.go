@@ -0,0 +1,156 @@
+package throughput
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+)
+
+// serverGrace is added to test duration to wait for the end of test.
+const serverGrace = 5 * time.Second
+
+// Server receives test data of peer exporters. Only one test is served at a time,
+// so concurrent tests from several nodes don't distort each other.
+type Server struct {
+	tcp         net.Listener
+	udp         net.PacketConn
+	maxDuration time.Duration
+	logger      log.Logger
+	busy        chan struct{}
+	// udpTest counts datagrams of the running UDP test
+	udpTest *counter
+	udpID   uint64
+	mutex   sync.Mutex
+}
+
+// Listen opens TCP and UDP sockets of server on address.
+func Listen(address string, maxDuration time.Duration, logger log.Logger) (*Server, error) {
+	tcp, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't listen TCP on %s", address)
+	}
+	udp, err := net.ListenPacket("udp", tcp.Addr().String())
+	if err != nil {
+		_ = tcp.Close()
+		return nil, errors.Wrapf(err, "can't listen UDP on %s", address)
+	}
+	return &Server{tcp: tcp, udp: udp, maxDuration: maxDuration, logger: logger, busy: make(chan struct{}, 1)}, nil
+}
+
+// Addr returns the address server listens on.
+func (s *Server) Addr() string {
+	return s.tcp.Addr().String()
+}
+
+// Run serves tests until context is done.
+func (s *Server) Run(ctx context.Context) {
+	_ = level.Info(s.logger).Log("msg", fmt.Sprintf("Starting throughput test server on %s", s.Addr()))
+	go func() {
+		<-ctx.Done()
+		_ = s.tcp.Close()
+		_ = s.udp.Close()
+	}()
+	go s.serveUDP()
+	for {
+		conn, err := s.tcp.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			_ = level.Debug(s.logger).Log("msg", "Can't accept throughput test connection", "err", err)
+			continue
+		}
+		go s.serve(conn)
+	}
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(serverGrace))
+	req, err := readRequest(conn)
+	if err != nil {
+		_ = level.Debug(s.logger).Log("msg", fmt.Sprintf("Incorrect throughput test request from %s", conn.RemoteAddr()), "err", err)
+		return
+	}
+	if req.duration > s.maxDuration || (req.mode != modeTCP && req.mode != modeUDP) {
+		_, _ = conn.Write([]byte{statusRejected})
+		return
+	}
+	select {
+	case s.busy <- struct{}{}:
+		defer func() { <-s.busy }()
+	default:
+		_, _ = conn.Write([]byte{statusBusy})
+		return
+	}
+	_ = conn.SetDeadline(time.Now().Add(req.duration + serverGrace))
+	c := &counter{}
+	if req.mode == modeUDP {
+		// the counter is registered before the client is allowed to send, so the first datagrams are counted
+		s.mutex.Lock()
+		s.udpTest, s.udpID = c, req.id
+		s.mutex.Unlock()
+		defer func() {
+			s.mutex.Lock()
+			s.udpTest = nil
+			s.mutex.Unlock()
+		}()
+	}
+	if _, err := conn.Write([]byte{statusOk}); err != nil {
+		return
+	}
+	_ = level.Debug(s.logger).Log("msg", fmt.Sprintf("Serving %c throughput test from %s for %s", req.mode, conn.RemoteAddr(), req.duration))
+
+	var result summary
+	if req.mode == modeTCP {
+		buf := make([]byte, 128*1024)
+		for {
+			n, err := conn.Read(buf)
+			if n > 0 {
+				c.add(n, time.Now())
+			}
+			if err != nil {
+				break
+			}
+		}
+		result = c.result()
+	} else {
+		// client half-closes connection when it finishes sending
+		_, _ = io.Copy(io.Discard, conn)
+		s.mutex.Lock()
+		result = c.result()
+		s.mutex.Unlock()
+	}
+	_, _ = conn.Write(result.marshal())
+}
+
+func (s *Server) serveUDP() {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := s.udp.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		if n < udpHeaderLen {
+			continue
+		}
+		id := binary.BigEndian.Uint64(buf)
+		now := time.Now()
+		s.mutex.Lock()
+		if s.udpTest != nil && s.udpID == id {
+			s.udpTest.add(n, now)
+		}
+		s.mutex.Unlock()
+	}
+}
@@ -0,0 +1,27 @@
+package throughput
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// retransmits returns the total number of segments retransmitted by connection.
+func retransmits(conn *net.TCPConn) (uint32, error) {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var info *unix.TCPInfo
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		info, sockErr = unix.GetsockoptTCPInfo(int(fd), unix.IPPROTO_TCP, unix.TCP_INFO)
+	})
+	if err != nil {
+		return 0, err
+	}
+	if sockErr != nil {
+		return 0, sockErr
+	}
+	return info.Total_retrans, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package throughput
+
+import (
+	"net"
+
+	"github.com/pkg/errors"
+)
+
+// retransmits is not implemented for non-Linux platforms.
+func retransmits(conn *net.TCPConn) (uint32, error) {
+	return 0, errors.New("TCP retransmits are supported only on Linux")
+}
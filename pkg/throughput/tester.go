@@ -0,0 +1,190 @@
+package throughput
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Path is a path of HTTP endpoint which starts throughput test.
+const Path = "/throughput"
+
+var (
+	throughputLabels  = []string{"source", "destination", "destinationIp", "protocol"}
+	bitsPerSecondDesc = prometheus.NewDesc("network_latency_throughput_bits_per_second", "Bandwidth achieved by the last throughput test", throughputLabels, nil)
+	retransmitsDesc   = prometheus.NewDesc("network_latency_throughput_retransmits", "Number of TCP segments retransmitted during the last throughput test", throughputLabels, nil)
+	lossDesc          = prometheus.NewDesc("network_latency_throughput_loss", "Percent of UDP datagrams lost during the last throughput test", throughputLabels, nil)
+	statusDesc        = prometheus.NewDesc("network_latency_throughput_status", "Status of the last throughput test. 0 if successful, 1 if unsuccessful", throughputLabels, nil)
+	timestampDesc     = prometheus.NewDesc("network_latency_throughput_timestamp_seconds", "Unix time of the last throughput test", throughputLabels, nil)
+)
+
+// Tester executes throughput tests from the current node to servers of peer exporters
+// on schedule and on request via HTTP endpoint. Only one test is executed at a time.
+// It implements prometheus.Collector and http.Handler for Path.
+type Tester struct {
+	cfg     Config
+	source  string
+	targets func() []metrics.PingHost
+	logger  log.Logger
+	results map[string]Result
+	running sync.Mutex
+	mutex   sync.RWMutex
+}
+
+// NewTester creates tester of targets returned by the function.
+func NewTester(cfg Config, source string, targets func() []metrics.PingHost, logger log.Logger) *Tester {
+	return &Tester{cfg: cfg, source: source, targets: targets, logger: logger, results: make(map[string]Result)}
+}
+
+// Run tests all targets on schedule until context is done.
+func (t *Tester) Run(ctx context.Context) {
+	if t.cfg.Schedule <= 0 {
+		return
+	}
+	ticker := time.NewTicker(t.cfg.Schedule)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		for _, target := range t.targets() {
+			for _, protocol := range t.cfg.Protocols {
+				if ctx.Err() != nil {
+					return
+				}
+				t.running.Lock()
+				t.test(ctx, target, protocol, t.cfg.Duration)
+				t.running.Unlock()
+			}
+		}
+	}
+}
+
+// test executes a single test and stores result, caller must hold running lock.
+func (t *Tester) test(ctx context.Context, target metrics.PingHost, protocol string, duration time.Duration) Result {
+	result, err := Measure(ctx, net.JoinHostPort(target.IPAddress, t.cfg.Port), protocol, duration, t.cfg)
+	result.Destination, result.DestinationIP = target.Name, target.IPAddress
+	if err != nil {
+		result.Error = err.Error()
+		_ = level.Warn(t.logger).Log("msg", fmt.Sprintf("Throughput test %s to %s failed", protocol, target.IPAddress), "err", err)
+	} else {
+		_ = level.Info(t.logger).Log("msg", fmt.Sprintf("Throughput test %s to %s: %.0f bits/s", protocol, target.IPAddress, result.BitsPerSecond))
+	}
+	t.mutex.Lock()
+	t.results[target.IPAddress+"/"+protocol] = result
+	t.mutex.Unlock()
+	return result
+}
+
+// ServeHTTP starts throughput test to the target set by "target" parameter (node name or IP address)
+// with optional "protocol" and "duration" parameters and returns its result as JSON.
+func (t *Tester) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "Only POST method is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if t.cfg.Token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(t.cfg.Token)) != 1 {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	protocol := strings.ToUpper(query.Get("protocol"))
+	if protocol == "" {
+		protocol = TCP
+	}
+	if protocol != TCP && (protocol != UDP || t.cfg.Rate == 0) {
+		http.Error(w, fmt.Sprintf("Unsupported protocol %s", protocol), http.StatusBadRequest)
+		return
+	}
+	duration := t.cfg.Duration
+	if d := query.Get("duration"); d != "" {
+		var err error
+		if duration, err = time.ParseDuration(d); err != nil || duration <= 0 || duration > t.cfg.MaxDuration {
+			http.Error(w, fmt.Sprintf("Duration must be positive and not greater than %s", t.cfg.MaxDuration), http.StatusBadRequest)
+			return
+		}
+	}
+	var target *metrics.PingHost
+	for _, h := range t.targets() {
+		if h.Name == query.Get("target") || h.IPAddress == query.Get("target") {
+			target = &h
+			break
+		}
+	}
+	if target == nil {
+		http.Error(w, fmt.Sprintf("Unknown target %s", query.Get("target")), http.StatusNotFound)
+		return
+	}
+
+	if !t.running.TryLock() {
+		http.Error(w, "Another throughput test is running", http.StatusConflict)
+		return
+	}
+	result := t.test(r.Context(), *target, protocol, duration)
+	t.running.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(result)
+}
+
+// Describe implements prometheus.Collector.
+func (t *Tester) Describe(ch chan<- *prometheus.Desc) {
+	ch <- bitsPerSecondDesc
+	ch <- retransmitsDesc
+	ch <- lossDesc
+	ch <- statusDesc
+	ch <- timestampDesc
+}
+
+// Collect implements prometheus.Collector.
+func (t *Tester) Collect(ch chan<- prometheus.Metric) {
+	t.prune()
+	t.mutex.RLock()
+	defer t.mutex.RUnlock()
+	for _, res := range t.results {
+		labelValues := []string{t.source, res.Destination, res.DestinationIP, res.Protocol}
+		ch <- prometheus.MustNewConstMetric(timestampDesc, prometheus.GaugeValue, float64(res.Timestamp.Unix()), labelValues...)
+		if res.Error != "" {
+			ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, metrics.StatusUnreachable, labelValues...)
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(statusDesc, prometheus.GaugeValue, metrics.StatusOk, labelValues...)
+		ch <- prometheus.MustNewConstMetric(bitsPerSecondDesc, prometheus.GaugeValue, res.BitsPerSecond, labelValues...)
+		if res.Protocol == TCP {
+			ch <- prometheus.MustNewConstMetric(retransmitsDesc, prometheus.GaugeValue, float64(res.Retransmits), labelValues...)
+		} else {
+			ch <- prometheus.MustNewConstMetric(lossDesc, prometheus.GaugeValue, res.Loss, labelValues...)
+		}
+	}
+}
+
+// prune drops results of targets which are no longer present.
+func (t *Tester) prune() {
+	present := make(map[string]bool)
+	for _, h := range t.targets() {
+		present[h.IPAddress] = true
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for key, res := range t.results {
+		if !present[res.DestinationIP] {
+			delete(t.results, key)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package throughput
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/metrics"
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func startServer(t *testing.T) *Server {
+	s, err := Listen("127.0.0.1:0", time.Second, log.NewNopLogger())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go s.Run(ctx)
+	return s
+}
+
+func TestMeasure(t *testing.T) {
+	s := startServer(t)
+	cfg := Config{Rate: 8 * 1000 * 1000, PacketSize: 1000, MaxDuration: time.Second}
+
+	for _, protocol := range []string{TCP, UDP} {
+		result, err := Measure(context.Background(), s.Addr(), protocol, 200*time.Millisecond, cfg)
+		require.NoError(t, err, protocol)
+		assert.Greater(t, result.Bytes, uint64(0), protocol)
+		// rate is limited to 1 MB/s, so about 200 KB are sent
+		assert.Less(t, result.Bytes, uint64(400*1000), protocol)
+		assert.Greater(t, result.BitsPerSecond, 0.0, protocol)
+	}
+
+	_, err := Measure(context.Background(), s.Addr(), TCP, 2*time.Second, cfg)
+	assert.Error(t, err, "duration above server limit is rejected")
+}
+
+// TestServeUDPCountsFirstDatagram checks that datagrams sent right after the test is accepted are counted.
+func TestServeUDPCountsFirstDatagram(t *testing.T) {
+	s := startServer(t)
+	for i := 0; i < 20; i++ {
+		conn, err := net.Dial("tcp", s.Addr())
+		require.NoError(t, err)
+		req := request{mode: modeUDP, duration: 100 * time.Millisecond, id: uint64(i + 1)}
+		_, err = conn.Write(req.marshal())
+		require.NoError(t, err)
+		status := make([]byte, 1)
+		_, err = conn.Read(status)
+		require.NoError(t, err)
+		require.Equal(t, statusOk, status[0])
+
+		udpConn, err := net.Dial("udp", s.Addr())
+		require.NoError(t, err)
+		buf := make([]byte, udpHeaderLen)
+		binary.BigEndian.PutUint64(buf, req.id)
+		_, err = udpConn.Write(buf)
+		require.NoError(t, err)
+		_ = udpConn.Close()
+		time.Sleep(10 * time.Millisecond)
+
+		require.NoError(t, conn.(*net.TCPConn).CloseWrite())
+		summary, err := readSummary(conn)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(1), summary.packets)
+		_ = conn.Close()
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	s := startServer(t)
+	_, port, _ := net.SplitHostPort(s.Addr())
+	cfg := Config{Port: port, Rate: 8 * 1000 * 1000, Duration: 100 * time.Millisecond, MaxDuration: time.Second, Token: "secret"}
+	tester := NewTester(cfg, "node-1", func() []metrics.PingHost {
+		return []metrics.PingHost{{Name: "node-2", IPAddress: "127.0.0.1"}}
+	}, log.NewNopLogger())
+
+	rec := httptest.NewRecorder()
+	tester.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, Path+"?target=node-2", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, Path+"?target=node-3", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	tester.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, Path+"?target=node-2&duration=100ms", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	tester.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	var result Result
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&result))
+	assert.Equal(t, "node-2", result.Destination)
+	assert.Equal(t, TCP, result.Protocol)
+	assert.Empty(t, result.Error)
+	assert.Len(t, tester.results, 1)
+}
+
+// TestCollectDropsRemovedTargets checks that results of targets which are no longer present aren't exported.
+func TestCollectDropsRemovedTargets(t *testing.T) {
+	targets := []metrics.PingHost{{Name: "node-2", IPAddress: "10.0.0.2"}, {Name: "node-3", IPAddress: "10.0.0.3"}}
+	tester := NewTester(Config{}, "node-1", func() []metrics.PingHost { return targets }, log.NewNopLogger())
+	tester.results["10.0.0.2/TCP"] = Result{Destination: "node-2", DestinationIP: "10.0.0.2", Protocol: TCP}
+	tester.results["10.0.0.3/TCP"] = Result{Destination: "node-3", DestinationIP: "10.0.0.3", Protocol: TCP}
+
+	assert.Equal(t, 2*4, testutil.CollectAndCount(tester))
+	targets = targets[:1]
+	assert.Equal(t, 4, testutil.CollectAndCount(tester))
+	assert.Len(t, tester.results, 1)
+	assert.Contains(t, tester.results, "10.0.0.2/TCP")
+}
+
+func TestParseRate(t *testing.T) {
+	rate, err := ParseRate("100M")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100*1000*1000), rate)
+	rate, err = ParseRate("0")
+	require.NoError(t, err)
+	assert.Zero(t, rate)
+	_, err = ParseRate("fast")
+	assert.Error(t, err)
+}
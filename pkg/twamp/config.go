@@ -0,0 +1,61 @@
+package twamp
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/Netcracker/network-latency-exporter/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// Config describes TWAMP-light reflector and sessions to peer exporters.
+type Config struct {
+	Enabled bool
+	Port    string
+	// Interval is how often sessions to all peers are executed
+	Interval time.Duration
+	// Packets is the number of test packets in a session
+	Packets int
+	// PacketInterval is a delay between test packets
+	PacketInterval time.Duration
+	// Timeout is how long to wait for reflected packets after the last packet is sent
+	Timeout time.Duration
+	// ClockError and ClockSynchronized are sent in Error Estimate of test packets
+	ClockError        time.Duration
+	ClockSynchronized bool
+}
+
+// ConfigFromEnv reads TWAMP configuration from environment.
+func ConfigFromEnv() (Config, error) {
+	cfg := Config{
+		Enabled:           utils.GetEnvWithDefaultValue("TWAMP_ENABLE", "false") == "true",
+		Port:              utils.GetEnvWithDefaultValue("TWAMP_PORT", "862"),
+		ClockSynchronized: utils.GetEnvWithDefaultValue("TWAMP_CLOCK_SYNCHRONIZED", "false") == "true",
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		return cfg, errors.Errorf("TWAMP_PORT has incorrect value %s", cfg.Port)
+	}
+	var err error
+	if cfg.Interval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("TWAMP_INTERVAL", "30s")); err != nil {
+		return cfg, errors.Wrap(err, "TWAMP_INTERVAL has incorrect value")
+	}
+	packets := utils.GetEnvWithDefaultValue("TWAMP_PACKETS", "10")
+	if cfg.Packets, err = strconv.Atoi(packets); err != nil || cfg.Packets < 1 {
+		return cfg, errors.Errorf("TWAMP_PACKETS has incorrect value %s", packets)
+	}
+	if cfg.PacketInterval, err = time.ParseDuration(utils.GetEnvWithDefaultValue("TWAMP_PACKET_INTERVAL", "100ms")); err != nil {
+		return cfg, errors.Wrap(err, "TWAMP_PACKET_INTERVAL has incorrect value")
+	}
+	if cfg.Timeout, err = time.ParseDuration(utils.GetEnvWithDefaultValue("TWAMP_TIMEOUT", "2s")); err != nil {
+		return cfg, errors.Wrap(err, "TWAMP_TIMEOUT has incorrect value")
+	}
+	if cfg.ClockError, err = time.ParseDuration(utils.GetEnvWithDefaultValue("TWAMP_CLOCK_ERROR", "10ms")); err != nil {
+		return cfg, errors.Wrap(err, "TWAMP_CLOCK_ERROR has incorrect value")
+	}
+	return cfg, nil
+}
+
+// ErrorEstimate returns Error Estimate of the local clock.
+func (c Config) ErrorEstimate() ErrorEstimate {
+	return NewErrorEstimate(c.ClockError, c.ClockSynchronized)
+}
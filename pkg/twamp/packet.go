@@ -0,0 +1,146 @@
+package twamp
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// senderHeaderLen is the length of unauthenticated sender packet without padding, RFC 4656 section 4.1.2
+	senderHeaderLen = 14
+	// reflectorHeaderLen is the length of unauthenticated reflector packet without padding, RFC 5357 section 4.2.1
+	reflectorHeaderLen = 41
+	// ntpEpochOffset is the number of seconds between NTP epoch (1900) and Unix epoch (1970)
+	ntpEpochOffset = 2208988800
+)
+
+// Timestamp is a 64-bit NTP timestamp: 32 bits of seconds and 32 bits of fraction.
+type Timestamp uint64
+
+// NewTimestamp converts time to NTP timestamp.
+func NewTimestamp(t time.Time) Timestamp {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return Timestamp(seconds<<32 | fraction)
+}
+
+// Time converts NTP timestamp to time.
+func (t Timestamp) Time() time.Time {
+	seconds := int64(t>>32) - ntpEpochOffset
+	nanoseconds := (uint64(t) & 0xffffffff) * uint64(time.Second) >> 32
+	return time.Unix(seconds, int64(nanoseconds))
+}
+
+// ErrorEstimate describes accuracy of timestamps, RFC 4656 section 4.1.2:
+// S bit (clock is synchronized to UTC), Z bit (timestamp format, 0 for NTP), 6 bits of Scale and 8 bits of Multiplier.
+// The error is Multiplier*2^(-32)*2^Scale seconds.
+type ErrorEstimate uint16
+
+// NewErrorEstimate encodes clock error with the smallest scale which fits the multiplier.
+func NewErrorEstimate(clockError time.Duration, synchronized bool) ErrorEstimate {
+	var e ErrorEstimate
+	if synchronized {
+		e = 1 << 15
+	}
+	seconds := clockError.Seconds()
+	for scale := 0; scale < 64; scale++ {
+		multiplier := math.Ceil(seconds * math.Exp2(float64(32-scale)))
+		if multiplier <= 0xff {
+			// multiplier 0 is not allowed, the smallest error is 1*2^(-32) seconds
+			return e | ErrorEstimate(scale)<<8 | ErrorEstimate(math.Max(multiplier, 1))
+		}
+	}
+	return e | 0x3fff
+}
+
+// Synchronized returns true if the clock is synchronized to UTC.
+func (e ErrorEstimate) Synchronized() bool {
+	return e&(1<<15) != 0
+}
+
+// Duration returns the clock error.
+func (e ErrorEstimate) Duration() time.Duration {
+	scale := int(e>>8) & 0x3f
+	multiplier := float64(e & 0xff)
+	return time.Duration(multiplier * math.Exp2(float64(scale-32)) * float64(time.Second))
+}
+
+// SenderPacket is an unauthenticated TWAMP-Test packet sent by Session-Sender.
+type SenderPacket struct {
+	Sequence      uint32
+	Timestamp     Timestamp
+	ErrorEstimate ErrorEstimate
+}
+
+// Marshal encodes the packet padded with zeros to size.
+func (p SenderPacket) Marshal(size int) []byte {
+	if size < senderHeaderLen {
+		size = senderHeaderLen
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:], p.Sequence)
+	binary.BigEndian.PutUint64(b[4:], uint64(p.Timestamp))
+	binary.BigEndian.PutUint16(b[12:], uint16(p.ErrorEstimate))
+	return b
+}
+
+// UnmarshalSenderPacket decodes sender packet.
+func UnmarshalSenderPacket(b []byte) (SenderPacket, error) {
+	if len(b) < senderHeaderLen {
+		return SenderPacket{}, errors.Errorf("TWAMP sender packet is too short: %d bytes", len(b))
+	}
+	return SenderPacket{
+		Sequence:      binary.BigEndian.Uint32(b[0:]),
+		Timestamp:     Timestamp(binary.BigEndian.Uint64(b[4:])),
+		ErrorEstimate: ErrorEstimate(binary.BigEndian.Uint16(b[12:])),
+	}, nil
+}
+
+// ReflectorPacket is an unauthenticated TWAMP-Test packet sent back by Session-Reflector.
+type ReflectorPacket struct {
+	Sequence            uint32
+	Timestamp           Timestamp
+	ErrorEstimate       ErrorEstimate
+	ReceiveTimestamp    Timestamp
+	SenderSequence      uint32
+	SenderTimestamp     Timestamp
+	SenderErrorEstimate ErrorEstimate
+	SenderTTL           uint8
+}
+
+// Marshal encodes the packet padded with zeros to size.
+func (p ReflectorPacket) Marshal(size int) []byte {
+	if size < reflectorHeaderLen {
+		size = reflectorHeaderLen
+	}
+	b := make([]byte, size)
+	binary.BigEndian.PutUint32(b[0:], p.Sequence)
+	binary.BigEndian.PutUint64(b[4:], uint64(p.Timestamp))
+	binary.BigEndian.PutUint16(b[12:], uint16(p.ErrorEstimate))
+	binary.BigEndian.PutUint64(b[16:], uint64(p.ReceiveTimestamp))
+	binary.BigEndian.PutUint32(b[24:], p.SenderSequence)
+	binary.BigEndian.PutUint64(b[28:], uint64(p.SenderTimestamp))
+	binary.BigEndian.PutUint16(b[36:], uint16(p.SenderErrorEstimate))
+	b[40] = p.SenderTTL
+	return b
+}
+
+// UnmarshalReflectorPacket decodes reflector packet.
+func UnmarshalReflectorPacket(b []byte) (ReflectorPacket, error) {
+	if len(b) < reflectorHeaderLen {
+		return ReflectorPacket{}, errors.Errorf("TWAMP reflector packet is too short: %d bytes", len(b))
+	}
+	return ReflectorPacket{
+		Sequence:            binary.BigEndian.Uint32(b[0:]),
+		Timestamp:           Timestamp(binary.BigEndian.Uint64(b[4:])),
+		ErrorEstimate:       ErrorEstimate(binary.BigEndian.Uint16(b[12:])),
+		ReceiveTimestamp:    Timestamp(binary.BigEndian.Uint64(b[16:])),
+		SenderSequence:      binary.BigEndian.Uint32(b[24:]),
+		SenderTimestamp:     Timestamp(binary.BigEndian.Uint64(b[28:])),
+		SenderErrorEstimate: ErrorEstimate(binary.BigEndian.Uint16(b[36:])),
+		SenderTTL:           b[40],
+	}, nil
+}
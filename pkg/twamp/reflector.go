@@ -0,0 +1,122 @@
+package twamp
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/pkg/errors"
+	"golang.org/x/net/ipv4"
+)
+
+// sessionIdleTimeout is how long reflector keeps sequence number of a sender which doesn't send packets
+const sessionIdleTimeout = 5 * time.Minute
+
+// reflectorSession counts packets reflected to a single sender address.
+type reflectorSession struct {
+	sequence uint32
+	lastSeen time.Time
+}
+
+// Reflector is a TWAMP-light Session-Reflector: it answers test packets of any sender without TWAMP-Control.
+// Sequence numbers are counted per sender address, so senders can tell forward loss from reverse loss.
+type Reflector struct {
+	conn          *ipv4.PacketConn
+	errorEstimate ErrorEstimate
+	logger        log.Logger
+	sessions      map[string]*reflectorSession
+	lastPrune     time.Time
+	mutex         sync.Mutex
+}
+
+// Listen opens UDP socket of reflector on address.
+func Listen(address string, errorEstimate ErrorEstimate, logger log.Logger) (*Reflector, error) {
+	conn, err := net.ListenPacket("udp4", address)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't listen UDP on %s", address)
+	}
+	pc := ipv4.NewPacketConn(conn)
+	// TTL of received packets is reflected back, it is not available on all platforms
+	_ = pc.SetControlMessage(ipv4.FlagTTL, true)
+	return &Reflector{
+		conn:          pc,
+		errorEstimate: errorEstimate,
+		logger:        logger,
+		sessions:      make(map[string]*reflectorSession),
+		lastPrune:     time.Now(),
+	}, nil
+}
+
+// Addr returns the address reflector listens on.
+func (r *Reflector) Addr() string {
+	return r.conn.LocalAddr().String()
+}
+
+// Run reflects test packets until context is done.
+func (r *Reflector) Run(ctx context.Context) {
+	_ = level.Info(r.logger).Log("msg", fmt.Sprintf("Starting TWAMP-light reflector on %s", r.Addr()))
+	go func() {
+		<-ctx.Done()
+		_ = r.conn.Close()
+	}()
+	buf := make([]byte, 65535)
+	for {
+		n, cm, addr, err := r.conn.ReadFrom(buf)
+		received := time.Now()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			_ = level.Debug(r.logger).Log("msg", "Can't read TWAMP packet", "err", err)
+			continue
+		}
+		request, err := UnmarshalSenderPacket(buf[:n])
+		if err != nil {
+			_ = level.Debug(r.logger).Log("msg", fmt.Sprintf("Skip incorrect TWAMP packet from %s", addr), "err", err)
+			continue
+		}
+		reply := ReflectorPacket{
+			Sequence:            r.nextSequence(addr.String(), received),
+			ErrorEstimate:       r.errorEstimate,
+			ReceiveTimestamp:    NewTimestamp(received),
+			SenderSequence:      request.Sequence,
+			SenderTimestamp:     request.Timestamp,
+			SenderErrorEstimate: request.ErrorEstimate,
+		}
+		if cm != nil {
+			reply.SenderTTL = uint8(cm.TTL)
+		}
+		reply.Timestamp = NewTimestamp(time.Now())
+		// reply has the same size as request if request is padded enough, so both directions carry equal packets
+		if _, err := r.conn.WriteTo(reply.Marshal(n), nil, addr); err != nil {
+			_ = level.Debug(r.logger).Log("msg", fmt.Sprintf("Can't reflect TWAMP packet to %s", addr), "err", err)
+		}
+	}
+}
+
+// nextSequence returns reflector sequence number of the next packet sent to address.
+func (r *Reflector) nextSequence(address string, now time.Time) uint32 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if now.Sub(r.lastPrune) > sessionIdleTimeout {
+		for a, s := range r.sessions {
+			if now.Sub(s.lastSeen) > sessionIdleTimeout {
+				delete(r.sessions, a)
+			}
+		}
+		r.lastPrune = now
+	}
+	s, ok := r.sessions[address]
+	if !ok {
+		s = &reflectorSession{}
+		r.sessions[address] = s
+	}
+	s.lastSeen = now
+	sequence := s.sequence
+	s.sequence++
+	return sequence
+}
@@ -0,0 +1,156 @@
+package twamp
+
+import (
+	"context"
+	"math"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Delay is a statistic of delays in milliseconds.
+type Delay struct {
+	Min  float64
+	Mean float64
+	Max  float64
+}
+
+func newDelay(values []time.Duration) Delay {
+	if len(values) == 0 {
+		return Delay{}
+	}
+	d := Delay{Min: math.MaxFloat64, Max: -math.MaxFloat64}
+	sum := 0.0
+	for _, v := range values {
+		ms := float64(v) / float64(time.Millisecond)
+		d.Min = math.Min(d.Min, ms)
+		d.Max = math.Max(d.Max, ms)
+		sum += ms
+	}
+	d.Mean = sum / float64(len(values))
+	return d
+}
+
+// Result is a result of a single TWAMP-light session.
+type Result struct {
+	Sent int
+	// Reflected is the number of packets which reached reflector, it is known from reflector sequence numbers
+	Reflected int
+	Received  int
+	// ForwardLoss and ReverseLoss are percents of packets lost from sender to reflector and back
+	ForwardLoss float64
+	ReverseLoss float64
+	// Forward and Reverse are one-way delays, they are accurate only if clocks of both nodes are synchronized
+	Forward Delay
+	Reverse Delay
+	// Rtt is round trip time without processing time of reflector
+	Rtt Delay
+	// ClockError is the sum of error estimates of sender and reflector clocks, i.e. accuracy of one-way delays
+	ClockError time.Duration
+	// Synchronized is true if clocks of both sender and reflector are synchronized to UTC
+	Synchronized bool
+}
+
+// reply is a reflected packet with time when it is received.
+type reply struct {
+	packet   ReflectorPacket
+	received time.Time
+}
+
+// Measure executes TWAMP-light session to reflector on address.
+func Measure(ctx context.Context, address string, cfg Config) (Result, error) {
+	conn, err := net.Dial("udp4", address)
+	if err != nil {
+		return Result{}, errors.Wrapf(err, "can't connect to TWAMP reflector %s", address)
+	}
+	defer func() { _ = conn.Close() }()
+
+	errorEstimate := cfg.ErrorEstimate()
+	sent := make(map[uint32]time.Time, cfg.Packets)
+	replies := make(chan reply, cfg.Packets)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 65535)
+		seen := make(map[uint32]bool, cfg.Packets)
+		// session is complete when all packets are reflected, so don't wait for timeout
+		for len(seen) < cfg.Packets {
+			n, err := conn.Read(buf)
+			now := time.Now()
+			if err != nil {
+				// deadline is set after the last packet is sent
+				return
+			}
+			packet, err := UnmarshalReflectorPacket(buf[:n])
+			if err != nil || packet.SenderSequence >= uint32(cfg.Packets) || seen[packet.SenderSequence] {
+				continue
+			}
+			seen[packet.SenderSequence] = true
+			replies <- reply{packet: packet, received: now}
+		}
+	}()
+
+	for i := 0; i < cfg.Packets; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				_ = conn.SetReadDeadline(time.Now())
+				<-done
+				return Result{}, ctx.Err()
+			case <-time.After(cfg.PacketInterval):
+			}
+		}
+		now := time.Now()
+		seq := uint32(i)
+		sent[seq] = now
+		packet := SenderPacket{Sequence: seq, Timestamp: NewTimestamp(now), ErrorEstimate: errorEstimate}
+		// sender packet is padded to the size of reflector packet, so both directions carry equal packets
+		if _, err := conn.Write(packet.Marshal(reflectorHeaderLen)); err != nil {
+			_ = conn.SetReadDeadline(time.Now())
+			<-done
+			return Result{}, errors.Wrapf(err, "can't send TWAMP packet to %s", address)
+		}
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(cfg.Timeout))
+	<-done
+	close(replies)
+
+	result := Result{Sent: cfg.Packets, ClockError: errorEstimate.Duration(), Synchronized: errorEstimate.Synchronized()}
+	var forward, reverse, rtt []time.Duration
+	reflected := uint32(0)
+	first := true
+	for r := range replies {
+		t1 := sent[r.packet.SenderSequence]
+		// packets reflected after the last received one are counted as lost in forward direction
+		if r.packet.Sequence+1 > reflected {
+			reflected = r.packet.Sequence + 1
+		}
+		if first {
+			result.ClockError += r.packet.ErrorEstimate.Duration()
+			result.Synchronized = result.Synchronized && r.packet.ErrorEstimate.Synchronized()
+			first = false
+		}
+		t2, t3, t4 := r.packet.ReceiveTimestamp.Time(), r.packet.Timestamp.Time(), r.received
+		forward = append(forward, t2.Sub(t1))
+		reverse = append(reverse, t4.Sub(t3))
+		rtt = append(rtt, t4.Sub(t1)-t3.Sub(t2))
+	}
+	result.Received = len(forward)
+	// reflector counts packets of the session, but can't count more packets than were sent
+	result.Reflected = int(math.Min(float64(reflected), float64(result.Sent)))
+	if result.Reflected < result.Received {
+		result.Reflected = result.Received
+	}
+	if first {
+		result.Synchronized = false
+	}
+	result.ForwardLoss = float64(result.Sent-result.Reflected) / float64(result.Sent) * 100
+	if result.Reflected > 0 {
+		result.ReverseLoss = float64(result.Reflected-result.Received) / float64(result.Reflected) * 100
+	}
+	result.Forward = newDelay(forward)
+	result.Reverse = newDelay(reverse)
+	result.Rtt = newDelay(rtt)
+	return result, nil
+}
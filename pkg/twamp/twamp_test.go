@@ -0,0 +1,65 @@
+package twamp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestamp(t *testing.T) {
+	now := time.Unix(1700000000, 123456789)
+	assert.WithinDuration(t, now, NewTimestamp(now).Time(), time.Nanosecond)
+}
+
+func TestErrorEstimate(t *testing.T) {
+	e := NewErrorEstimate(10*time.Millisecond, true)
+	assert.True(t, e.Synchronized())
+	assert.InDelta(t, float64(10*time.Millisecond), float64(e.Duration()), float64(100*time.Microsecond))
+
+	e = NewErrorEstimate(0, false)
+	assert.False(t, e.Synchronized())
+	assert.Equal(t, ErrorEstimate(1), e)
+}
+
+func TestPackets(t *testing.T) {
+	sender := SenderPacket{Sequence: 7, Timestamp: 42, ErrorEstimate: 0x8001}
+	b := sender.Marshal(reflectorHeaderLen)
+	assert.Len(t, b, reflectorHeaderLen)
+	decoded, err := UnmarshalSenderPacket(b)
+	require.NoError(t, err)
+	assert.Equal(t, sender, decoded)
+
+	reflector := ReflectorPacket{Sequence: 1, Timestamp: 2, ErrorEstimate: 3, ReceiveTimestamp: 4,
+		SenderSequence: 5, SenderTimestamp: 6, SenderErrorEstimate: 7, SenderTTL: 255}
+	decodedReflector, err := UnmarshalReflectorPacket(reflector.Marshal(0))
+	require.NoError(t, err)
+	assert.Equal(t, reflector, decodedReflector)
+
+	_, err = UnmarshalReflectorPacket(b[:senderHeaderLen])
+	assert.Error(t, err)
+}
+
+func TestMeasure(t *testing.T) {
+	cfg := Config{Packets: 5, PacketInterval: time.Millisecond, Timeout: time.Second, ClockError: time.Millisecond}
+	r, err := Listen("127.0.0.1:0", cfg.ErrorEstimate(), log.NewNopLogger())
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Run(ctx)
+
+	result, err := Measure(ctx, r.Addr(), cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 5, result.Sent)
+	assert.Equal(t, 5, result.Reflected)
+	assert.Equal(t, 5, result.Received)
+	assert.Zero(t, result.ForwardLoss)
+	assert.Zero(t, result.ReverseLoss)
+	assert.GreaterOrEqual(t, result.Rtt.Min, 0.0)
+	assert.LessOrEqual(t, result.Rtt.Min, result.Rtt.Max)
+	assert.False(t, result.Synchronized)
+	assert.InDelta(t, float64(2*time.Millisecond), float64(result.ClockError), float64(50*time.Microsecond))
+}
@@ -0,0 +1,15 @@
+package utils
+
+import (
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+)
+
+// NewLogr returns logr.Logger which writes to go-kit logger, e.g. for controller-runtime.
+func NewLogr(logger log.Logger) logr.Logger {
+	return funcr.New(func(prefix, args string) {
+		_ = level.Info(logger).Log("msg", args, "logger", prefix)
+	}, funcr.Options{})
+}
@@ -11,8 +11,8 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
-	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
 )
 
 func GetEnvWithDefaultValue(key string, defaultValue string) string {
@@ -26,16 +26,13 @@ func GetEnvWithDefaultValue(key string, defaultValue string) string {
 	return value
 }
 
-func GetClientset() (*kubernetes.Clientset, error) {
-	config, err := rest.InClusterConfig()
-	if err != nil {
-		return nil, err
-	}
-	client, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		return nil, err
-	}
-	return client, nil
+// GetRestConfig returns configuration of connection to Kubernetes from kubeconfig file and context.
+// If kubeconfig is empty, KUBECONFIG environment variable, ~/.kube/config and in-cluster configuration are tried.
+func GetRestConfig(kubeconfig string, kubeContext string) (*rest.Config, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: kubeContext}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, overrides).ClientConfig()
 }
 
 func ValidateTargets(logger log.Logger, targets *metrics.PingHostList) *metrics.PingHostList {